@@ -0,0 +1,56 @@
+package ip2country
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// prefixMask returns the netmask for an IPv4 prefix length, clamped to 0..32.
+func prefixMask(bits int) uint32 {
+	switch {
+	case bits <= 0:
+		return 0
+	case bits >= 32:
+		return ^uint32(0)
+	}
+	return ^uint32(0) << (32 - bits)
+}
+
+// AnonymizeIP truncates an IPv4 address to its first bits bits, zeroing the rest, e.g.
+// AnonymizeIP("8.8.8.8", 24) returns "8.8.8.0". It accepts the same input forms as the
+// lookup methods and returns the result in dot-decimal notation.
+func AnonymizeIP(ipStr string, bits int) (string, error) {
+	if bits < 0 || bits > 32 {
+		return "", fmt.Errorf("invalid prefix length %d", bits)
+	}
+	ipNum, err := parseIP(ipStr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidIP, err)
+	}
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], ipNum&prefixMask(bits))
+	return netip.AddrFrom4(b).String(), nil
+}
+
+// anonymizedBlock returns the first and last address of the block of size /bits that
+// ipNum belongs to. A non-positive bits value means lookups are not anonymized.
+func anonymizedBlock(ipNum uint32, bits int) (first, last uint32) {
+	if bits <= 0 {
+		return ipNum, ipNum
+	}
+	mask := prefixMask(bits)
+	return ipNum & mask, ipNum | ^mask
+}
+
+// checkAnonymizedMatch verifies that the matched range in res covers the whole
+// anonymized block, so every address the caller may have truncated resolves to the
+// same country.
+func checkAnonymizedMatch(res *Result, first, last uint32, bits int) error {
+	if res.StartIP <= first && res.EndIP >= last {
+		return nil
+	}
+	*res = Result{}
+	return newLookupError(CodeAmbiguous, fmt.Errorf("ambiguous anonymized address: /%d block spans more than one range", bits))
+}
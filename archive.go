@@ -0,0 +1,184 @@
+package ip2country
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// defaultArchivePattern selects the data file inside an archive when
+// Config.ArchivePattern is empty.
+const defaultArchivePattern = "*.csv"
+
+// multiCloser closes a stack of readers in order after the data reader is consumed.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes every underlying closer and returns the first error.
+func (m *multiCloser) Close() error {
+	var errs []error
+	for _, c := range m.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// isArchive reports whether filePath names an archive supported by openDataFile.
+func isArchive(filePath string) bool {
+	lower := strings.ToLower(filePath)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// Decompressor wraps a compressed stream in a reader for the decompressed data.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[string]Decompressor{
+		".gz": func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	}
+)
+
+// RegisterDecompressor makes datasets whose file name ends in suffix (e.g. ".zst") load
+// through the given decompressor. Gzip (".gz") is built in; other codecs such as zstd
+// can be registered without adding dependencies to this package:
+//
+//	ip2country.RegisterDecompressor(".zst", func(r io.Reader) (io.ReadCloser, error) {
+//		d, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return d.IOReadCloser(), nil
+//	})
+func RegisterDecompressor(suffix string, d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[strings.ToLower(suffix)] = d
+}
+
+// decompressorFor returns the registered decompressor for filePath, if any.
+func decompressorFor(filePath string) Decompressor {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+	lower := strings.ToLower(filePath)
+	for suffix, d := range decompressors {
+		if strings.HasSuffix(lower, suffix) {
+			return d
+		}
+	}
+	return nil
+}
+
+// openDataFile opens the dataset at filePath and returns a reader for the data and its
+// uncompressed size, or -1 if it is not known up front. Plain files are read as-is and
+// compressed files (.gz or a registered suffix) are decompressed on the fly. For .zip,
+// .tar.gz and .tgz archives, the first regular entry whose base name matches pattern
+// (see path.Match) is used, so downloads can be loaded without unpacking them first.
+func openDataFile(filePath, pattern string) (io.ReadCloser, int64, error) {
+	if pattern == "" {
+		pattern = defaultArchivePattern
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, 0, fmt.Errorf("invalid archive pattern %q: %w", pattern, err)
+	}
+
+	lower := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return openZipEntry(filePath, pattern)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return openTarGzEntry(filePath, pattern)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	adviseReadahead(file)
+	if decompress := decompressorFor(filePath); decompress != nil {
+		rc, err := decompress(file)
+		if err != nil {
+			file.Close()
+			return nil, 0, fmt.Errorf("failed to open compressed stream: %w", err)
+		}
+		return &multiCloser{Reader: rc, closers: []io.Closer{rc, file}}, -1, nil
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to get file stats: %w", err)
+	}
+	return file, stat.Size(), nil
+}
+
+// openZipEntry opens the first entry of a zip archive matching pattern.
+func openZipEntry(filePath, pattern string) (io.ReadCloser, int64, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if ok, _ := path.Match(pattern, path.Base(f.Name)); !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			archive.Close()
+			return nil, 0, fmt.Errorf("failed to open archive entry %s: %w", f.Name, err)
+		}
+		return &multiCloser{Reader: rc, closers: []io.Closer{rc, archive}}, int64(f.UncompressedSize64), nil
+	}
+
+	archive.Close()
+	return nil, 0, fmt.Errorf("no entry matching %q in archive %s", pattern, filePath)
+}
+
+// openTarGzEntry opens the first regular entry of a gzip-compressed tar archive matching pattern.
+func openTarGzEntry(filePath, pattern string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	adviseReadahead(file)
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			gz.Close()
+			file.Close()
+			return nil, 0, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if ok, _ := path.Match(pattern, path.Base(hdr.Name)); ok {
+			return &multiCloser{Reader: tr, closers: []io.Closer{gz, file}}, hdr.Size, nil
+		}
+	}
+
+	gz.Close()
+	file.Close()
+	return nil, 0, fmt.Errorf("no entry matching %q in archive %s", pattern, filePath)
+}
@@ -0,0 +1,233 @@
+package ip2country
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Priority orders lookups submitted to an AsyncLookup.
+type Priority int
+
+const (
+	// PriorityInteractive is for lookups on a request path. They are always served before
+	// queued batch lookups.
+	PriorityInteractive Priority = iota
+	// PriorityBatch is for background work such as log enrichment, which only uses
+	// capacity left over by interactive lookups.
+	PriorityBatch
+)
+
+// ErrQueueFull is returned by AsyncLookup.Submit when the queue for the requested
+// priority has no room left.
+var ErrQueueFull = errors.New("async lookup queue is full")
+
+// AsyncConfig holds configuration for an AsyncLookup.
+// Fields are ordered for optimal memory alignment.
+type AsyncConfig struct {
+	// Workers is the number of goroutines performing lookups. Defaults to GOMAXPROCS.
+	Workers int
+	// BatchWorkers caps how many workers may serve batch lookups at the same time, so the
+	// remaining workers stay available for interactive ones. Defaults to Workers-1, but
+	// at least 1.
+	BatchWorkers int
+	// QueueSize is the capacity of each priority queue. Defaults to 1024.
+	QueueSize int
+}
+
+// AsyncResult is the outcome of a lookup submitted to an AsyncLookup.
+// Fields are ordered for optimal memory alignment.
+type AsyncResult struct {
+	// Err is the lookup error, if any.
+	Err error
+	// IP is the address as submitted.
+	IP string
+	// Code is the resolved country code.
+	Code string
+	// Priority is the priority the lookup was submitted with.
+	Priority Priority
+}
+
+// AsyncStats reports the queue depths and throughput of an AsyncLookup.
+type AsyncStats struct {
+	// InteractiveQueued is the number of interactive lookups waiting for a worker.
+	InteractiveQueued int `json:"interactive_queued"`
+	// BatchQueued is the number of batch lookups waiting for a worker.
+	BatchQueued int `json:"batch_queued"`
+	// Completed is the number of lookups performed.
+	Completed int64 `json:"completed"`
+	// Rejected is the number of submissions refused with ErrQueueFull.
+	Rejected int64 `json:"rejected"`
+}
+
+// asyncRequest is a queued lookup.
+type asyncRequest struct {
+	ctx      context.Context
+	results  chan<- AsyncResult
+	ip       string
+	priority Priority
+}
+
+// AsyncLookup performs lookups on a worker pool with two priority levels, so background
+// enrichment jobs can use spare capacity without delaying request-path lookups.
+type AsyncLookup struct {
+	reader      CountryReader
+	interactive chan asyncRequest
+	batch       chan asyncRequest
+	batchSlots  chan struct{}
+	stop        chan struct{}
+	wg          sync.WaitGroup
+	mu          sync.RWMutex
+	closed      bool
+	completed   atomic.Int64
+	rejected    atomic.Int64
+}
+
+// NewAsyncLookup starts the workers of an AsyncLookup that resolves addresses with reader.
+// It accepts an optional AsyncConfig. Call Close to stop the workers.
+func NewAsyncLookup(reader CountryReader, config ...AsyncConfig) *AsyncLookup {
+	var cfg AsyncConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.BatchWorkers <= 0 {
+		cfg.BatchWorkers = max(1, cfg.Workers-1)
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+
+	a := &AsyncLookup{
+		reader:      reader,
+		interactive: make(chan asyncRequest, cfg.QueueSize),
+		batch:       make(chan asyncRequest, cfg.QueueSize),
+		batchSlots:  make(chan struct{}, cfg.BatchWorkers),
+		stop:        make(chan struct{}),
+	}
+	a.wg.Add(cfg.Workers)
+	for range cfg.Workers {
+		go a.worker()
+	}
+	return a
+}
+
+// Submit queues a lookup of ipStr and returns immediately. The result is sent to results
+// unless ctx is canceled first; results should be buffered or drained promptly, since a
+// blocked receiver occupies a worker. A nil results channel discards the result, e.g.
+// to warm the cache. Submit returns ErrQueueFull if the queue for the priority is full
+// and ErrClosed after Close.
+func (a *AsyncLookup) Submit(ctx context.Context, ipStr string, priority Priority, results chan<- AsyncResult) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return ErrClosed
+	}
+	queue := a.interactive
+	if priority == PriorityBatch {
+		queue = a.batch
+	}
+
+	select {
+	case queue <- asyncRequest{ctx: ctx, results: results, ip: ipStr, priority: priority}:
+		return nil
+	default:
+		a.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Stats returns the current queue depths and counters.
+func (a *AsyncLookup) Stats() AsyncStats {
+	return AsyncStats{
+		InteractiveQueued: len(a.interactive),
+		BatchQueued:       len(a.batch),
+		Completed:         a.completed.Load(),
+		Rejected:          a.rejected.Load(),
+	}
+}
+
+// Close stops the workers after their current lookup. Lookups still queued are answered
+// with ErrClosed if their results channel has room. It is safe to call more than once.
+func (a *AsyncLookup) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.stop)
+	a.mu.Unlock()
+
+	a.wg.Wait()
+	for _, queue := range []chan asyncRequest{a.interactive, a.batch} {
+		for len(queue) > 0 {
+			req := <-queue
+			select {
+			case req.results <- AsyncResult{IP: req.ip, Priority: req.priority, Err: ErrClosed}:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// worker serves queued lookups until Close, taking interactive lookups first and batch
+// lookups only while fewer than BatchWorkers workers are busy with them.
+func (a *AsyncLookup) worker() {
+	defer a.wg.Done()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case req := <-a.interactive:
+			a.run(req)
+			continue
+		default:
+		}
+
+		select {
+		case <-a.stop:
+			return
+		case req := <-a.interactive:
+			a.run(req)
+		case a.batchSlots <- struct{}{}:
+			select {
+			case <-a.stop:
+				<-a.batchSlots
+				return
+			case req := <-a.interactive:
+				<-a.batchSlots
+				a.run(req)
+			case req := <-a.batch:
+				a.run(req)
+				<-a.batchSlots
+			}
+		}
+	}
+}
+
+// run performs a single lookup and delivers its result.
+func (a *AsyncLookup) run(req asyncRequest) {
+	res := AsyncResult{IP: req.ip, Priority: req.priority}
+	if err := req.ctx.Err(); err != nil {
+		res.Err = err
+	} else {
+		res.Code, res.Err = a.reader.GetCountryCodeWithContext(req.ctx, req.ip)
+		a.completed.Add(1)
+	}
+
+	if req.results == nil {
+		return
+	}
+	select {
+	case req.results <- res:
+	case <-req.ctx.Done():
+	}
+}
@@ -0,0 +1,120 @@
+package ip2country
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Attribution describes the provider and license of a dataset, so applications that
+// must credit the provider (e.g. "IP Geolocation by DB-IP") can do so programmatically.
+// Fields are ordered for optimal memory alignment.
+type Attribution struct {
+	// Provider is the name of the data provider, e.g. "DB-IP" or "MaxMind".
+	Provider string `json:"provider,omitempty"`
+	// License is the name of the license the data is distributed under.
+	License string `json:"license,omitempty"`
+	// LicenseURL points to the full license text.
+	LicenseURL string `json:"license_url,omitempty"`
+	// Notice is the credit line the license requires to be displayed.
+	Notice string `json:"notice,omitempty"`
+	// Copyright is the copyright line shipped with the dataset, if any.
+	Copyright string `json:"copyright,omitempty"`
+}
+
+// IsZero reports whether no attribution is known.
+func (a Attribution) IsZero() bool {
+	return a == Attribution{}
+}
+
+var (
+	// dbipAttribution is the attribution required by the DB-IP lite datasets.
+	dbipAttribution = Attribution{
+		Provider:   "DB-IP",
+		License:    "CC BY 4.0",
+		LicenseURL: "https://creativecommons.org/licenses/by/4.0/",
+		Notice:     "IP Geolocation by DB-IP (https://db-ip.com)",
+	}
+	// maxMindAttribution is the attribution required by the GeoLite2 databases.
+	maxMindAttribution = Attribution{
+		Provider:   "MaxMind",
+		License:    "GeoLite2 End User License Agreement",
+		LicenseURL: "https://www.maxmind.com/en/geolite2/eula",
+		Notice:     "This product includes GeoLite2 data created by MaxMind, available from https://www.maxmind.com.",
+	}
+)
+
+// licenseTextLimit caps how much of a license or copyright file is inspected.
+const licenseTextLimit = 64 << 10
+
+// detectAttribution determines the attribution of the dataset provided by src. An
+// explicit Config.Attribution wins; otherwise the provider is recognized from the
+// dataset name, the configured format and, for local files, the LICENSE/COPYRIGHT files
+// bundled in the same archive or directory.
+func detectAttribution(src Source, meta Metadata, cfg Config) Attribution {
+	if !cfg.Attribution.IsZero() {
+		return cfg.Attribution
+	}
+
+	var attr Attribution
+	name := strings.ToLower(path.Base(filepath.ToSlash(meta.Name)))
+	switch {
+	case cfg.Format == FormatGeoLite2 || strings.Contains(name, "geolite2"):
+		attr = maxMindAttribution
+	case strings.HasPrefix(name, "dbip"):
+		attr = dbipAttribution
+	}
+
+	file, ok := src.(*FileSource)
+	if !ok {
+		return attr
+	}
+
+	license := readBundled(file.Path, "LICENSE*")
+	if attr.IsZero() {
+		switch lower := strings.ToLower(license); {
+		case strings.Contains(lower, "maxmind"):
+			attr = maxMindAttribution
+		case strings.Contains(lower, "db-ip"):
+			attr = dbipAttribution
+		}
+	}
+	attr.Copyright = firstLine(readBundled(file.Path, "COPYRIGHT*"))
+	return attr
+}
+
+// readBundled returns the beginning of the file matching pattern that ships with the
+// dataset, either inside the same archive or in the same directory. It returns "" if
+// there is none.
+func readBundled(filePath, pattern string) string {
+	source := filePath
+	if !isArchive(filePath) {
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(filePath), pattern))
+		if len(matches) == 0 {
+			return ""
+		}
+		source = matches[0]
+	}
+
+	file, _, err := openDataFile(source, pattern)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	text, _ := io.ReadAll(io.LimitReader(file, licenseTextLimit))
+	return string(text)
+}
+
+// firstLine returns the first non-empty line of text.
+func firstLine(text string) string {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package ip2country
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// sniffLines is the number of data lines NewAuto inspects to detect the file format.
+const sniffLines = 5
+
+// NewAuto inspects the first data lines of the file at filePath and returns the
+// matching IPCountryLookup implementation: an IPCountryDB for three-column
+// start_ip,end_ip,country_code files and two-column network,country_code files of
+// CIDR blocks, and an ExactIPCountryMap for two-column ip,country_code files.
+// MaxMind GeoLite2-Country blocks files are loaded with FormatGeoLite2. It accepts an
+// optional Config, as the other constructors do.
+func NewAuto(filePath string, config ...Config) (IPCountryLookup, error) {
+	cfg := DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Delimiter == "" {
+		cfg.Delimiter = ","
+	}
+
+	file, _, err := openDataFile(filePath, cfg.ArchivePattern)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	columns, cidr := 0, false
+	reader, layout := detectLayout(file, &cfg)
+	scanner := bufio.NewScanner(reader)
+	lineNum, sampled := 0, 0
+	for sampled < sniffLines && scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || (layout.skipHeader && lineNum == 1) || isComment(line, cfg.CommentPrefixes) {
+			continue
+		}
+
+		parts, err := splitFields(line, layout.delimiter)
+		if err != nil {
+			continue
+		}
+		if _, err := parseIP(strings.TrimSpace(strings.SplitN(parts[0], "/", 2)[0])); err != nil {
+			// Not a data line (e.g. an unannounced header); ignore it.
+			continue
+		}
+		if columns != 0 && len(parts) != columns {
+			return nil, fmt.Errorf("inconsistent column count: line %d has %d fields, expected %d", lineNum, len(parts), columns)
+		}
+		columns = len(parts)
+		cidr = cidr || strings.Contains(parts[0], "/")
+		sampled++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+
+	switch {
+	case sampled == 0:
+		return nil, fmt.Errorf("no data lines found in %s", filePath)
+	case columns == 3 && !cidr:
+		return NewIPCountryDB(filePath, cfg), nil
+	case columns >= 6 && cidr:
+		cfg.Format = FormatGeoLite2
+		return NewIPCountryDB(filePath, cfg), nil
+	case columns == 2 && cidr:
+		cfg.Format = FormatCIDR
+		return NewIPCountryDB(filePath, cfg), nil
+	case columns == 2:
+		return NewExactIPCountryMap(filePath, cfg), nil
+	default:
+		return nil, fmt.Errorf("unrecognized format: %d fields per line", columns)
+	}
+}
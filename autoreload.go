@@ -0,0 +1,206 @@
+package ip2country
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+// statter is implemented by sources that can report the current state of the dataset
+// cheaply, without reading it.
+type statter interface {
+	stat() (Metadata, error)
+}
+
+// stat reports the size and modification time of the file itself (for compressed files
+// and archives, of the compressed file).
+func (s *FileSource) stat() (Metadata, error) {
+	stat, err := os.Stat(s.Path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{Name: s.Path, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// stat reports the size and modification time of the file in the file system.
+func (s *FSSource) stat() (Metadata, error) {
+	file, err := s.FS.Open(s.Path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{Name: s.Path, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// lifecycle ties the background goroutines started by a lookup to its Close.
+// The zero value is never closed.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newLifecycle() lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// bind returns a context that is canceled when ctx is canceled or the lookup is closed.
+func (l lifecycle) bind(ctx context.Context) context.Context {
+	if l.ctx == nil {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(l.ctx, cancel)
+	context.AfterFunc(ctx, func() { stop() })
+	return ctx
+}
+
+// close cancels every context returned by bind.
+func (l lifecycle) close() {
+	if l.cancel != nil {
+		l.cancel()
+	}
+}
+
+// closed reports whether close was called.
+func (l lifecycle) closed() bool {
+	return l.ctx != nil && l.ctx.Err() != nil
+}
+
+// defaultWatchInterval is the polling interval of Watch when Config.RefreshInterval is unset.
+const defaultWatchInterval = time.Second
+
+// ReloadEvent reports the outcome of a reload triggered by Watch.
+// Fields are ordered for optimal memory alignment.
+type ReloadEvent struct {
+	// Time is when the reload finished.
+	Time time.Time
+	// Err is the reload error, or nil if the new dataset was swapped in.
+	Err error
+	// Source identifies the dataset that was reloaded.
+	Source string
+}
+
+// ErrReloadDeferred is returned by an automatic reload that Config.BeforeReload declined,
+// e.g. because another instance of the fleet holds the reload lock. The reload is
+// retried at the next interval.
+var ErrReloadDeferred = errors.New("reload deferred by coordination hook")
+
+// coordinatedReload wraps reload with the fleet coordination of cfg: a random delay of
+// up to Config.ReloadJitter, then Config.BeforeReload, whose release function is called
+// once the reload finished.
+func coordinatedReload(cfg Config, reload func(context.Context) error) func(context.Context) error {
+	if cfg.BeforeReload == nil && cfg.ReloadJitter <= 0 {
+		return reload
+	}
+	return func(ctx context.Context) error {
+		if cfg.ReloadJitter > 0 {
+			timer := time.NewTimer(rand.N(cfg.ReloadJitter))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if cfg.BeforeReload != nil {
+			release, err := cfg.BeforeReload(ctx)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrReloadDeferred, err)
+			}
+			if release != nil {
+				defer release()
+			}
+		}
+		return reload(ctx)
+	}
+}
+
+// startAutoReload validates the interval and runs watchSource in the background,
+// logging the outcome of each reload.
+func startAutoReload(ctx context.Context, interval time.Duration, src Source, logger *slog.Logger, reload func(context.Context) error) error {
+	if interval <= 0 {
+		return fmt.Errorf("RefreshInterval must be positive to start auto reload")
+	}
+	go watchSource(ctx, interval, src, reload, func(err error) {
+		if err != nil {
+			logger.Error("auto reload failed", "source", sourceName(src), "error", err)
+		}
+	})
+	return nil
+}
+
+// watch runs watchSource in the background and reports every reload on the returned
+// channel, which is closed when ctx is canceled. Events are dropped if the receiver
+// falls behind by more than the channel's buffer.
+func watch(ctx context.Context, interval time.Duration, src Source, reload func(context.Context) error) <-chan ReloadEvent {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	events := make(chan ReloadEvent, 16)
+	go func() {
+		defer close(events)
+		watchSource(ctx, interval, src, reload, func(err error) {
+			select {
+			case events <- ReloadEvent{Time: time.Now(), Err: err, Source: sourceName(src)}:
+			default:
+			}
+		})
+	}()
+	return events
+}
+
+// watchSource polls src every interval until ctx is canceled and calls reload when the
+// dataset has changed, passing the result to notify. For sources that implement
+// statter, a change is a different size or modification time; other sources, such as
+// an HTTPSource, are reloaded on every tick and report ErrNotModified when unchanged,
+// which is not passed to notify. Neither is ErrReloadDeferred: the change is picked up
+// again at the next tick.
+func watchSource(ctx context.Context, interval time.Duration, src Source, reload func(context.Context) error, notify func(error)) {
+	st, canStat := src.(statter)
+	var last Metadata
+	if canStat {
+		last, _ = st.stat()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var current Metadata
+		if canStat {
+			var err error
+			current, err = st.stat()
+			if err != nil {
+				// The file may be in the middle of being replaced; check again next tick.
+				continue
+			}
+			if current.Size == last.Size && current.ModTime.Equal(last.ModTime) {
+				continue
+			}
+		}
+
+		err := reload(ctx)
+		if errors.Is(err, ErrNotModified) || errors.Is(err, ErrReloadDeferred) {
+			continue
+		}
+		notify(err)
+		if err == nil {
+			last = current
+		}
+	}
+}
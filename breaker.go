@@ -0,0 +1,91 @@
+package ip2country
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInitCircuitOpen is returned by lookups while the initialization circuit breaker
+// is open, see Config.InitFailureThreshold.
+var ErrInitCircuitOpen = errors.New("initialization circuit open")
+
+// Initialization circuit states, as reported in Stats.InitCircuit.
+const (
+	initCircuitClosed   = "closed"
+	initCircuitOpen     = "open"
+	initCircuitHalfOpen = "half_open"
+)
+
+// initBreaker stops lookups from retrying a failing initial load on every call. After
+// threshold consecutive failures it opens, failing lookups immediately; once cooldown
+// has passed it lets a single attempt through (half-open), which closes it on success
+// and opens it again on failure. A zero threshold disables it.
+type initBreaker struct {
+	mu        sync.Mutex
+	lastErr   error
+	openUntil time.Time
+	cooldown  time.Duration
+	threshold int
+	failures  int
+}
+
+// newInitBreaker returns the breaker configured by cfg.
+func newInitBreaker(cfg *Config) *initBreaker {
+	cooldown := cfg.InitCooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &initBreaker{threshold: cfg.InitFailureThreshold, cooldown: cooldown}
+}
+
+// allow returns an error wrapping ErrInitCircuitOpen if an initialization attempt must
+// not be made now.
+func (b *initBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= b.threshold && time.Now().Before(b.openUntil) {
+		return fmt.Errorf("%w after %d failures: %w", ErrInitCircuitOpen, b.failures, b.lastErr)
+	}
+	return nil
+}
+
+// record records the outcome of a load; any successful load closes the breaker.
+func (b *initBreaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures, b.lastErr = 0, nil
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// state returns the state of the breaker and the number of consecutive failures, or ""
+// if it is disabled.
+func (b *initBreaker) state() (string, int) {
+	if b.threshold <= 0 {
+		return "", 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.failures < b.threshold:
+		return initCircuitClosed, b.failures
+	case time.Now().Before(b.openUntil):
+		return initCircuitOpen, b.failures
+	default:
+		return initCircuitHalfOpen, b.failures
+	}
+}
@@ -1,103 +1,579 @@
 package ip2country
 
 import (
-	"container/list"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // cacheEntry holds the data for a single cached lookup result.
 // Fields are ordered for optimal memory alignment.
 type cacheEntry struct {
-	country string
-	code    string
-	ip      uint32
-	found   bool // Used to cache misses as well.
+	country    string
+	code       string
+	alternates []string // Shared with the dataset.
+	expires    int64    // Unix nanoseconds after which the entry is stale; 0 if it never expires.
+	ip         uint32
+	startIP    uint32
+	endIP      uint32
+	found      bool // Used to cache misses as well.
 }
 
-// cacheItem is the object stored in the LRU list.
+// fill copies a cached hit into a caller-provided Result.
+func (e cacheEntry) fill(res *Result) {
+	res.Country = e.country
+	res.Code = e.code
+	res.AlternateCodes = e.alternates
+	res.StartIP = e.startIP
+	res.EndIP = e.endIP
+}
+
+// covers reports whether the cached range or gap contains ipNum.
+func (e cacheEntry) covers(ipNum uint32) bool {
+	return e.startIP <= ipNum && ipNum <= e.endIP
+}
+
+// Cache stores lookup results keyed by IPv4 address in host byte order, or by the first
+// address of the prefix with Config.CachePrefixLen. Set Config.Cache
+// to replace the built-in cache, e.g. with a TTL cache, an adapter for a third-party
+// cache or a no-op implementation. Implementations must be safe for concurrent use.
+//
+// Config.CacheTTL does not apply to a custom cache; it is responsible for its own expiry.
+// A custom cache cannot be invalidated selectively: Reload and Config.CacheInvalidation
+// call Clear. If the cache also implements Delete(ip uint32), it is used to drop single
+// entries after ExactIPCountryMap.Set and Delete; otherwise those clear the cache too.
+type Cache interface {
+	// Get returns the cached result for ip, if any.
+	Get(ip uint32) (CacheEntry, bool)
+	// Put stores the result for ip.
+	Put(ip uint32, entry CacheEntry)
+	// Clear removes all entries.
+	Clear()
+	// Stats reports cache usage for Stats.
+	Stats() CacheStats
+}
+
+// CacheEntry is a cached lookup result. Misses are cached with Found set to false and
+// StartIP and EndIP bounding the span that matches no range: the address itself, or
+// the gap between ranges with Config.CachePrefixLen. An entry only answers lookups of
+// addresses between its StartIP and EndIP.
 // Fields are ordered for optimal memory alignment.
-type cacheItem struct {
-	value cacheEntry
-	key   uint32
+type CacheEntry struct {
+	// Country is the country of the matched range.
+	Country string
+	// Code is the country code of the matched range.
+	Code string
+	// AlternateCodes lists the other codes of a range tagged with several codes.
+	AlternateCodes []string
+	// StartIP is the first address of the matched range.
+	StartIP uint32
+	// EndIP is the last address of the matched range.
+	EndIP uint32
+	// Found reports whether the lookup matched a range.
+	Found bool
+}
+
+// CacheStats reports the usage of a Cache.
+type CacheStats struct {
+	// Hits is the number of lookups served from the cache.
+	Hits int64
+	// Misses is the number of lookups not found in the cache.
+	Misses int64
+	// Bytes is the approximate memory held by cached entries, or 0 if unknown.
+	Bytes int64
+}
+
+// resultCache is the cache used by the lookup implementations: the built-in
+// lookupCache or a customCache wrapping Config.Cache.
+type resultCache interface {
+	get(key, ipNum uint32) (cacheEntry, bool)
+	put(key uint32, value cacheEntry, generation uint64)
+	setGeneration(generation uint64)
+	clear()
+	remove(key uint32)
+	removeIf(pred func(key uint32, value cacheEntry) bool)
+	getStats() (hits, misses int64)
+	usage() int64
+}
+
+// newResultCache returns Config.Cache, if set, or the built-in cache sized by cfg,
+// combined with the negative cache selected by Config.NegativeCaching and monitored
+// by the hit-ratio alarm of Config.OnCacheDegraded.
+func newResultCache(cfg Config) resultCache {
+	var hits resultCache
+	if cfg.Cache != nil {
+		hits = &customCache{cache: cfg.Cache}
+	} else {
+		hits = newShardedCache(cfg.CacheSize, cfg.CacheMemoryBudgetBytes, cfg.CacheEviction, cfg.CacheTTL)
+	}
+
+	switch cfg.NegativeCaching {
+	case NegativeCacheDisabled:
+		return newMonitoredCache(&splitCache{hits: hits}, cfg)
+	case NegativeCacheSeparate:
+		size := cfg.NegativeCacheSize
+		if size <= 0 {
+			size = max(1, cfg.CacheSize/10)
+		}
+		return newMonitoredCache(&splitCache{hits: hits, misses: newShardedCache(size, 0, CacheEvictLRU, cfg.CacheTTL)}, cfg)
+	default:
+		return newMonitoredCache(hits, cfg)
+	}
+}
+
+// cacheEntryOverhead approximates the fixed memory cost of one cached entry: the entry
+// itself, its map slot and the bookkeeping of the eviction policy.
+const cacheEntryOverhead = 128
+
+// size returns the approximate number of bytes an item occupies in the cache. The
+// alternate codes are shared with the dataset, but are counted as the entry keeps them
+// alive after a reload.
+func (e cacheEntry) size() int64 {
+	n := cacheEntryOverhead + int64(len(e.country)+len(e.code))
+	if e.alternates != nil {
+		// A slice header takes 24 bytes and each string header in its array 16.
+		n += 24 + 16*int64(len(e.alternates))
+		for _, code := range e.alternates {
+			n += int64(len(code))
+		}
+	}
+	return n
 }
 
-// lruCache is a thread-safe, in-memory LRU (Least Recently Used) cache.
-type lruCache struct {
-	mu        sync.Mutex
-	capacity  int
-	items     map[uint32]*list.Element
-	evictList *list.List
-	hits      int64
-	misses    int64
+// lookupCache is a thread-safe, in-memory cache of lookup results.
+// It is bounded by a memory budget in approximate bytes, if set, and by an entry count;
+// which entries are evicted first is decided by the configured eviction policy. With a
+// TTL, entries expire that long after they were stored and are dropped when next read.
+// A single mutex guards the cache; shardedCache splits large caches to spread lookups
+// over several of them.
+type lookupCache struct {
+	mu         sync.Mutex
+	capacity   int
+	budget     int64
+	bytes      int64
+	ttl        time.Duration
+	generation uint64
+	items      map[uint32]cacheEntry
+	policy     evictionPolicy
+	hits       int64
+	misses     int64
 }
 
-// newLRUCache creates a new LRU cache with the given capacity.
-func newLRUCache(capacity int) *lruCache {
-	return &lruCache{
-		capacity:  capacity,
-		items:     make(map[uint32]*list.Element),
-		evictList: list.New(),
+// newLookupCache creates a new cache with the given capacity, memory budget, eviction
+// policy and TTL. A budget of 0 or less leaves the cache bounded by capacity only, and a
+// TTL of 0 or less keeps entries until they are evicted or invalidated.
+func newLookupCache(capacity int, budget int64, eviction CacheEviction, ttl time.Duration) *lookupCache {
+	return &lookupCache{
+		capacity: capacity,
+		budget:   budget,
+		ttl:      ttl,
+		items:    make(map[uint32]cacheEntry),
+		policy:   newEvictionPolicy(eviction, capacity),
 	}
 }
 
-// get retrieves a value from the cache.
-func (c *lruCache) get(key uint32) (cacheEntry, bool) {
+// get retrieves the value for key if it covers ipNum.
+func (c *lookupCache) get(key, ipNum uint32) (cacheEntry, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if elem, ok := c.items[key]; ok {
-		c.evictList.MoveToFront(elem)
-		atomic.AddInt64(&c.hits, 1)
-		return elem.Value.(*cacheItem).value, true
+	if value, ok := c.items[key]; ok && value.covers(ipNum) {
+		if value.expires == 0 || time.Now().UnixNano() <= value.expires {
+			c.policy.access(key)
+			atomic.AddInt64(&c.hits, 1)
+			return value, true
+		}
+		c.removeKey(key)
 	}
 
 	atomic.AddInt64(&c.misses, 1)
 	return cacheEntry{}, false
 }
 
-// put adds or updates a key-value pair in the cache.
-func (c *lruCache) put(key uint32, value cacheEntry) {
+// put adds or updates a key-value pair computed from the dataset snapshot of the given
+// generation. Values from a snapshot that has since been replaced are dropped.
+func (c *lookupCache) put(key uint32, value cacheEntry, generation uint64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if elem, ok := c.items[key]; ok {
-		c.evictList.MoveToFront(elem)
-		elem.Value.(*cacheItem).value = value
+	if generation != c.generation {
 		return
 	}
+	size := value.size()
+	if c.budget > 0 && size > c.budget {
+		return
+	}
+	if c.ttl > 0 {
+		value.expires = time.Now().Add(c.ttl).UnixNano()
+	}
 
-	if c.evictList.Len() >= c.capacity {
-		c.removeOldest()
+	if old, ok := c.items[key]; ok {
+		c.policy.access(key)
+		c.items[key] = value
+		c.bytes += size - old.size()
+		c.evict()
+		return
 	}
 
-	item := &cacheItem{key: key, value: value}
-	elem := c.evictList.PushFront(item)
-	c.items[key] = elem
+	c.items[key] = value
+	c.bytes += size
+	c.policy.add(key)
+	c.evict()
+}
+
+// evict removes the victims chosen by the eviction policy until the cache is within its
+// entry count and memory budget.
+func (c *lookupCache) evict() {
+	for len(c.items) > c.capacity || (c.budget > 0 && c.bytes > c.budget) {
+		key, ok := c.policy.victim()
+		if !ok {
+			return
+		}
+		c.removeKey(key)
+	}
 }
 
-// removeOldest removes the least recently used item from the cache.
-func (c *lruCache) removeOldest() {
-	elem := c.evictList.Back()
-	if elem != nil {
-		c.evictList.Remove(elem)
-		item := elem.Value.(*cacheItem)
-		delete(c.items, item.key)
+// removeKey unlinks key from the cache and releases its accounted bytes.
+func (c *lookupCache) removeKey(key uint32) {
+	if value, ok := c.items[key]; ok {
+		delete(c.items, key)
+		c.policy.remove(key)
+		c.bytes -= value.size()
 	}
 }
 
+// setGeneration records the generation of the live dataset snapshot. It must be
+// called after the snapshot is published and before stale entries are invalidated.
+func (c *lookupCache) setGeneration(generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation = generation
+}
+
 // clear removes all items from the cache.
-func (c *lruCache) clear() {
+func (c *lookupCache) clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.items = make(map[uint32]*list.Element)
-	c.evictList.Init()
+	c.items = make(map[uint32]cacheEntry)
+	c.policy.clear()
+	c.bytes = 0
 	atomic.StoreInt64(&c.hits, 0)
 	atomic.StoreInt64(&c.misses, 0)
 }
 
+// remove deletes the item for key, if present.
+func (c *lookupCache) remove(key uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeKey(key)
+}
+
+// removeIf removes every item for which the predicate returns true.
+// Hit and miss counters are left untouched.
+func (c *lookupCache) removeIf(pred func(key uint32, value cacheEntry) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, value := range c.items {
+		if pred(key, value) {
+			c.removeKey(key)
+		}
+	}
+}
+
 // getStats returns the current number of cache hits and misses.
-func (c *lruCache) getStats() (hits, misses int64) {
+func (c *lookupCache) getStats() (hits, misses int64) {
 	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
 }
+
+// usage returns the approximate number of bytes held by cached entries.
+func (c *lookupCache) usage() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// cacheShardBits is the log2 of the largest number of shards of a shardedCache.
+const cacheShardBits = 4
+
+// minCacheShardSize is the smallest number of entries per shard of a shardedCache, so
+// small caches are not split and keep a single eviction order.
+const minCacheShardSize = 1024
+
+// shardedCache splits the built-in cache into lookupCache shards, each evicting its own
+// entries within an even share of the capacity and memory budget. Lookups lock only the
+// shard of their key and wait for it, so a busy cache is slower but never skipped.
+type shardedCache struct {
+	shards []*lookupCache
+	bits   int
+}
+
+// newShardedCache creates a cache like newLookupCache, split into as many shards as
+// the capacity allows.
+func newShardedCache(capacity int, budget int64, eviction CacheEviction, ttl time.Duration) resultCache {
+	bits := cacheShardBits
+	for bits > 0 && capacity>>bits < minCacheShardSize {
+		bits--
+	}
+	if bits == 0 {
+		return newLookupCache(capacity, budget, eviction, ttl)
+	}
+	n := 1 << bits
+	c := &shardedCache{shards: make([]*lookupCache, n), bits: bits}
+	for i := range c.shards {
+		shardBudget := budget / int64(n)
+		if budget > 0 {
+			shardBudget = max(shardBudget, 1)
+		}
+		c.shards[i] = newLookupCache(capacity/n, shardBudget, eviction, ttl)
+	}
+	return c
+}
+
+// shard returns the shard for key. Fibonacci hashing spreads adjacent addresses and
+// prefixes, which often arrive together, across different shards.
+func (c *shardedCache) shard(key uint32) *lookupCache {
+	return c.shards[(key*2654435769)>>(32-c.bits)]
+}
+
+func (c *shardedCache) get(key, ipNum uint32) (cacheEntry, bool) {
+	return c.shard(key).get(key, ipNum)
+}
+
+func (c *shardedCache) put(key uint32, value cacheEntry, generation uint64) {
+	c.shard(key).put(key, value, generation)
+}
+
+func (c *shardedCache) setGeneration(generation uint64) {
+	for _, s := range c.shards {
+		s.setGeneration(generation)
+	}
+}
+
+func (c *shardedCache) clear() {
+	for _, s := range c.shards {
+		s.clear()
+	}
+}
+
+func (c *shardedCache) remove(key uint32) { c.shard(key).remove(key) }
+
+func (c *shardedCache) removeIf(pred func(key uint32, value cacheEntry) bool) {
+	for _, s := range c.shards {
+		s.removeIf(pred)
+	}
+}
+
+func (c *shardedCache) getStats() (hits, misses int64) {
+	for _, s := range c.shards {
+		h, m := s.getStats()
+		hits += h
+		misses += m
+	}
+	return hits, misses
+}
+
+func (c *shardedCache) usage() int64 {
+	var n int64
+	for _, s := range c.shards {
+		n += s.usage()
+	}
+	return n
+}
+
+// customCache adapts a user-supplied Cache to resultCache.
+type customCache struct {
+	cache      Cache
+	generation atomic.Uint64
+}
+
+func (c *customCache) get(key, ipNum uint32) (cacheEntry, bool) {
+	e, ok := c.cache.Get(key)
+	if !ok {
+		return cacheEntry{}, false
+	}
+	value := cacheEntry{
+		country: e.Country, code: e.Code, alternates: e.AlternateCodes, ip: key,
+		startIP: e.StartIP, endIP: e.EndIP, found: e.Found,
+	}
+	return value, value.covers(ipNum)
+}
+
+// put stores the value unless it was computed from a replaced snapshot.
+func (c *customCache) put(key uint32, value cacheEntry, generation uint64) {
+	if generation != c.generation.Load() {
+		return
+	}
+	c.cache.Put(key, CacheEntry{
+		Country: value.country, Code: value.code, AlternateCodes: value.alternates,
+		StartIP: value.startIP, EndIP: value.endIP, Found: value.found,
+	})
+}
+
+func (c *customCache) setGeneration(generation uint64) { c.generation.Store(generation) }
+
+func (c *customCache) clear() { c.cache.Clear() }
+
+// remove deletes a single entry if the cache supports it and clears it otherwise.
+func (c *customCache) remove(key uint32) {
+	if d, ok := c.cache.(interface{ Delete(ip uint32) }); ok {
+		d.Delete(key)
+		return
+	}
+	c.cache.Clear()
+}
+
+// removeIf clears the cache, since a Cache cannot be enumerated.
+func (c *customCache) removeIf(func(key uint32, value cacheEntry) bool) { c.cache.Clear() }
+
+func (c *customCache) getStats() (hits, misses int64) {
+	s := c.cache.Stats()
+	return s.Hits, s.Misses
+}
+
+func (c *customCache) usage() int64 { return c.cache.Stats().Bytes }
+
+// splitCache stores hits and misses in separate caches, so misses cannot evict hits.
+// A nil misses cache drops misses instead of caching them.
+type splitCache struct {
+	hits   resultCache
+	misses resultCache
+}
+
+func (c *splitCache) get(key, ipNum uint32) (cacheEntry, bool) {
+	if value, ok := c.hits.get(key, ipNum); ok || c.misses == nil {
+		return value, ok
+	}
+	return c.misses.get(key, ipNum)
+}
+
+func (c *splitCache) put(key uint32, value cacheEntry, generation uint64) {
+	switch {
+	case value.found:
+		c.hits.put(key, value, generation)
+	case c.misses != nil:
+		c.misses.put(key, value, generation)
+	}
+}
+
+func (c *splitCache) setGeneration(generation uint64) {
+	c.hits.setGeneration(generation)
+	if c.misses != nil {
+		c.misses.setGeneration(generation)
+	}
+}
+
+func (c *splitCache) clear() {
+	c.hits.clear()
+	if c.misses != nil {
+		c.misses.clear()
+	}
+}
+
+func (c *splitCache) remove(key uint32) {
+	c.hits.remove(key)
+	if c.misses != nil {
+		c.misses.remove(key)
+	}
+}
+
+func (c *splitCache) removeIf(pred func(key uint32, value cacheEntry) bool) {
+	c.hits.removeIf(pred)
+	if c.misses != nil {
+		c.misses.removeIf(pred)
+	}
+}
+
+// getStats counts a lookup found in neither cache once: every miss in the hits cache
+// falls through to the misses cache, whose misses are therefore the total.
+func (c *splitCache) getStats() (hits, misses int64) {
+	hits, misses = c.hits.getStats()
+	if c.misses == nil {
+		return hits, misses
+	}
+	negHits, negMisses := c.misses.getStats()
+	return hits + negHits, negMisses
+}
+
+func (c *splitCache) usage() int64 {
+	if c.misses == nil {
+		return c.hits.usage()
+	}
+	return c.hits.usage() + c.misses.usage()
+}
+
+// CacheDegradation describes a window of lookups whose cache hit ratio fell below
+// Config.CacheDegradedRatio, as reported to Config.OnCacheDegraded.
+// Fields are ordered for optimal memory alignment.
+type CacheDegradation struct {
+	// Name is the Config.Name of the instance.
+	Name string
+	// HitRatio is the share of lookups in the window answered from the cache.
+	HitRatio float64
+	// Threshold is the configured Config.CacheDegradedRatio.
+	Threshold float64
+	// Lookups is the number of lookups in the window.
+	Lookups int64
+}
+
+// defaultCacheDegradedWindow is the window used when Config.CacheDegradedWindow is unset.
+const defaultCacheDegradedWindow = 10000
+
+// monitoredCache measures the hit ratio of the wrapped cache over consecutive windows
+// of lookups and calls Config.OnCacheDegraded when it falls below the threshold. The
+// callback fires once per degradation: after an alarm, it fires again only after a
+// window at or above the threshold.
+type monitoredCache struct {
+	resultCache
+	onDegraded func(CacheDegradation)
+	name       string
+	threshold  float64
+	window     int64
+	hits       atomic.Int64
+	lookups    atomic.Int64
+	degraded   atomic.Bool
+}
+
+// newMonitoredCache wraps c with the hit-ratio alarm configured in cfg, or returns c if
+// the alarm is not configured.
+func newMonitoredCache(c resultCache, cfg Config) resultCache {
+	if cfg.OnCacheDegraded == nil || cfg.CacheDegradedRatio <= 0 {
+		return c
+	}
+	window := int64(cfg.CacheDegradedWindow)
+	if window <= 0 {
+		window = defaultCacheDegradedWindow
+	}
+	return &monitoredCache{
+		resultCache: c,
+		onDegraded:  cfg.OnCacheDegraded,
+		name:        cfg.Name,
+		threshold:   cfg.CacheDegradedRatio,
+		window:      window,
+	}
+}
+
+// get counts the lookup towards the current window. Exactly one caller completes each
+// window and evaluates it; the callback runs on its own goroutine so that lookups never
+// wait for it.
+func (c *monitoredCache) get(key, ipNum uint32) (cacheEntry, bool) {
+	value, ok := c.resultCache.get(key, ipNum)
+	if ok {
+		c.hits.Add(1)
+	}
+	if c.lookups.Add(1) == c.window {
+		// Subtracting the window keeps lookups counted by other goroutines meanwhile,
+		// whose hits also carry over into the next window.
+		c.lookups.Add(-c.window)
+		hits := min(c.hits.Swap(0), c.window)
+		ratio := float64(hits) / float64(c.window)
+		switch {
+		case ratio >= c.threshold:
+			c.degraded.Store(false)
+		case c.degraded.CompareAndSwap(false, true):
+			go c.onDegraded(CacheDegradation{Name: c.name, HitRatio: ratio, Threshold: c.threshold, Lookups: c.window})
+		}
+	}
+	return value, ok
+}
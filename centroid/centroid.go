@@ -0,0 +1,331 @@
+// Package centroid provides approximate geographic centers of countries for coarse,
+// country-level distance heuristics, such as flagging a login that moved thousands of
+// kilometers since the last one, without a city database.
+//
+// Coordinates are the centers of each country's territory and are only as precise as
+// a country-level answer allows: for large countries the distance between two
+// addresses in the same country is reported as 0 and between neighbors it may be off
+// by several hundred kilometers. The data lives in its own package so that programs
+// not using it do not carry it.
+package centroid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// ErrNoCentroid is returned when a country code has no known centroid.
+var ErrNoCentroid = errors.New("no centroid for country")
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// Point is a position in decimal degrees.
+type Point struct {
+	// Lat is the latitude, positive north of the equator.
+	Lat float64 `json:"lat"`
+	// Lon is the longitude, positive east of Greenwich.
+	Lon float64 `json:"lon"`
+}
+
+// centroids maps ISO 3166-1 alpha-2 codes to the approximate centers of the countries.
+var centroids = map[string]Point{
+	ip2country.AD: {42.546245, 1.601554},
+	ip2country.AE: {23.424076, 53.847818},
+	ip2country.AF: {33.93911, 67.709953},
+	ip2country.AG: {17.060816, -61.796428},
+	ip2country.AI: {18.220554, -63.068615},
+	ip2country.AL: {41.153332, 20.168331},
+	ip2country.AM: {40.069099, 45.038189},
+	ip2country.AO: {-11.202692, 17.873887},
+	ip2country.AQ: {-75.250973, -0.071389},
+	ip2country.AR: {-38.416097, -63.616672},
+	ip2country.AS: {-14.270972, -170.132217},
+	ip2country.AT: {47.516231, 14.550072},
+	ip2country.AU: {-25.274398, 133.775136},
+	ip2country.AW: {12.52111, -69.968338},
+	ip2country.AX: {60.178525, 19.91561},
+	ip2country.AZ: {40.143105, 47.576927},
+	ip2country.BA: {43.915886, 17.679076},
+	ip2country.BB: {13.193887, -59.543198},
+	ip2country.BD: {23.684994, 90.356331},
+	ip2country.BE: {50.503887, 4.469936},
+	ip2country.BF: {12.238333, -1.561593},
+	ip2country.BG: {42.733883, 25.48583},
+	ip2country.BH: {25.930414, 50.637772},
+	ip2country.BI: {-3.373056, 29.918886},
+	ip2country.BJ: {9.30769, 2.315834},
+	ip2country.BL: {17.9, -62.833333},
+	ip2country.BM: {32.321384, -64.75737},
+	ip2country.BN: {4.535277, 114.727669},
+	ip2country.BO: {-16.290154, -63.588653},
+	ip2country.BQ: {12.178361, -68.238534},
+	ip2country.BR: {-14.235004, -51.92528},
+	ip2country.BS: {25.03428, -77.39628},
+	ip2country.BT: {27.514162, 90.433601},
+	ip2country.BV: {-54.423199, 3.413194},
+	ip2country.BW: {-22.328474, 24.684866},
+	ip2country.BY: {53.709807, 27.953389},
+	ip2country.BZ: {17.189877, -88.49765},
+	ip2country.CA: {56.130366, -106.346771},
+	ip2country.CC: {-12.164165, 96.870956},
+	ip2country.CD: {-4.038333, 21.758664},
+	ip2country.CF: {6.611111, 20.939444},
+	ip2country.CG: {-0.228021, 15.827659},
+	ip2country.CH: {46.818188, 8.227512},
+	ip2country.CI: {7.539989, -5.54708},
+	ip2country.CK: {-21.236736, -159.777671},
+	ip2country.CL: {-35.675147, -71.542969},
+	ip2country.CM: {7.369722, 12.354722},
+	ip2country.CN: {35.86166, 104.195397},
+	ip2country.CO: {4.570868, -74.297333},
+	ip2country.CR: {9.748917, -83.753428},
+	ip2country.CU: {21.521757, -77.781167},
+	ip2country.CV: {16.002082, -24.013197},
+	ip2country.CW: {12.16957, -68.990021},
+	ip2country.CX: {-10.447525, 105.690449},
+	ip2country.CY: {35.126413, 33.429859},
+	ip2country.CZ: {49.817492, 15.472962},
+	ip2country.DE: {51.165691, 10.451526},
+	ip2country.DJ: {11.825138, 42.590275},
+	ip2country.DK: {56.26392, 9.501785},
+	ip2country.DM: {15.414999, -61.370976},
+	ip2country.DO: {18.735693, -70.162651},
+	ip2country.DZ: {28.033886, 1.659626},
+	ip2country.EC: {-1.831239, -78.183406},
+	ip2country.EE: {58.595272, 25.013607},
+	ip2country.EG: {26.820553, 30.802498},
+	ip2country.EH: {24.215527, -12.885834},
+	ip2country.ER: {15.179384, 39.782334},
+	ip2country.ES: {40.463667, -3.74922},
+	ip2country.ET: {9.145, 40.489673},
+	ip2country.FI: {61.92411, 25.748151},
+	ip2country.FJ: {-16.578193, 179.414413},
+	ip2country.FK: {-51.796253, -59.523613},
+	ip2country.FM: {7.425554, 150.550812},
+	ip2country.FO: {61.892635, -6.911806},
+	ip2country.FR: {46.227638, 2.213749},
+	ip2country.GA: {-0.803689, 11.609444},
+	ip2country.GB: {55.378051, -3.435973},
+	ip2country.GD: {12.262776, -61.604171},
+	ip2country.GE: {42.315407, 43.356892},
+	ip2country.GF: {3.933889, -53.125782},
+	ip2country.GG: {49.465691, -2.585278},
+	ip2country.GH: {7.946527, -1.023194},
+	ip2country.GI: {36.137741, -5.345374},
+	ip2country.GL: {71.706936, -42.604303},
+	ip2country.GM: {13.443182, -15.310139},
+	ip2country.GN: {9.945587, -9.696645},
+	ip2country.GP: {16.995971, -62.067641},
+	ip2country.GQ: {1.650801, 10.267895},
+	ip2country.GR: {39.074208, 21.824312},
+	ip2country.GS: {-54.429579, -36.587909},
+	ip2country.GT: {15.783471, -90.230759},
+	ip2country.GU: {13.444304, 144.793731},
+	ip2country.GW: {11.803749, -15.180413},
+	ip2country.GY: {4.860416, -58.93018},
+	ip2country.HK: {22.396428, 114.109497},
+	ip2country.HM: {-53.08181, 73.504158},
+	ip2country.HN: {15.199999, -86.241905},
+	ip2country.HR: {45.1, 15.2},
+	ip2country.HT: {18.971187, -72.285215},
+	ip2country.HU: {47.162494, 19.503304},
+	ip2country.ID: {-0.789275, 113.921327},
+	ip2country.IE: {53.41291, -8.24389},
+	ip2country.IL: {31.046051, 34.851612},
+	ip2country.IM: {54.236107, -4.548056},
+	ip2country.IN: {20.593684, 78.96288},
+	ip2country.IO: {-6.343194, 71.876519},
+	ip2country.IQ: {33.223191, 43.679291},
+	ip2country.IR: {32.427908, 53.688046},
+	ip2country.IS: {64.963051, -19.020835},
+	ip2country.IT: {41.87194, 12.56738},
+	ip2country.JE: {49.214439, -2.13125},
+	ip2country.JM: {18.109581, -77.297508},
+	ip2country.JO: {30.585164, 36.238414},
+	ip2country.JP: {36.204824, 138.252924},
+	ip2country.KE: {-0.023559, 37.906193},
+	ip2country.KG: {41.20438, 74.766098},
+	ip2country.KH: {12.565679, 104.990963},
+	ip2country.KI: {-3.370417, -168.734039},
+	ip2country.KM: {-11.875001, 43.872219},
+	ip2country.KN: {17.357822, -62.782998},
+	ip2country.KP: {40.339852, 127.510093},
+	ip2country.KR: {35.907757, 127.766922},
+	ip2country.KW: {29.31166, 47.481766},
+	ip2country.KY: {19.513469, -80.566956},
+	ip2country.KZ: {48.019573, 66.923684},
+	ip2country.LA: {19.85627, 102.495496},
+	ip2country.LB: {33.854721, 35.862285},
+	ip2country.LC: {13.909444, -60.978893},
+	ip2country.LI: {47.166, 9.555373},
+	ip2country.LK: {7.873054, 80.771797},
+	ip2country.LR: {6.428055, -9.429499},
+	ip2country.LS: {-29.609988, 28.233608},
+	ip2country.LT: {55.169438, 23.881275},
+	ip2country.LU: {49.815273, 6.129583},
+	ip2country.LV: {56.879635, 24.603189},
+	ip2country.LY: {26.3351, 17.228331},
+	ip2country.MA: {31.791702, -7.09262},
+	ip2country.MC: {43.750298, 7.412841},
+	ip2country.MD: {47.411631, 28.369885},
+	ip2country.ME: {42.708678, 19.37439},
+	ip2country.MF: {18.075277, -63.060001},
+	ip2country.MG: {-18.766947, 46.869107},
+	ip2country.MH: {7.131474, 171.184478},
+	ip2country.MK: {41.608635, 21.745275},
+	ip2country.ML: {17.570692, -3.996166},
+	ip2country.MM: {21.913965, 95.956223},
+	ip2country.MN: {46.862496, 103.846656},
+	ip2country.MO: {22.198745, 113.543873},
+	ip2country.MP: {17.33083, 145.38469},
+	ip2country.MQ: {14.641528, -61.024174},
+	ip2country.MR: {21.00789, -10.940835},
+	ip2country.MS: {16.742498, -62.187366},
+	ip2country.MT: {35.937496, 14.375416},
+	ip2country.MU: {-20.348404, 57.552152},
+	ip2country.MV: {3.202778, 73.22068},
+	ip2country.MW: {-13.254308, 34.301525},
+	ip2country.MX: {23.634501, -102.552784},
+	ip2country.MY: {4.210484, 101.975766},
+	ip2country.MZ: {-18.665695, 35.529562},
+	ip2country.NA: {-22.95764, 18.49041},
+	ip2country.NC: {-20.904305, 165.618042},
+	ip2country.NE: {17.607789, 8.081666},
+	ip2country.NF: {-29.040835, 167.954712},
+	ip2country.NG: {9.081999, 8.675277},
+	ip2country.NI: {12.865416, -85.207229},
+	ip2country.NL: {52.132633, 5.291266},
+	ip2country.NO: {60.472024, 8.468946},
+	ip2country.NP: {28.394857, 84.124008},
+	ip2country.NR: {-0.522778, 166.931503},
+	ip2country.NU: {-19.054445, -169.867233},
+	ip2country.NZ: {-40.900557, 174.885971},
+	ip2country.OM: {21.512583, 55.923255},
+	ip2country.PA: {8.537981, -80.782127},
+	ip2country.PE: {-9.189967, -75.015152},
+	ip2country.PF: {-17.679742, -149.406843},
+	ip2country.PG: {-6.314993, 143.95555},
+	ip2country.PH: {12.879721, 121.774017},
+	ip2country.PK: {30.375321, 69.345116},
+	ip2country.PL: {51.919438, 19.145136},
+	ip2country.PM: {46.941936, -56.27111},
+	ip2country.PN: {-24.703615, -127.439308},
+	ip2country.PR: {18.220833, -66.590149},
+	ip2country.PS: {31.952162, 35.233154},
+	ip2country.PT: {39.399872, -8.224454},
+	ip2country.PW: {7.51498, 134.58252},
+	ip2country.PY: {-23.442503, -58.443832},
+	ip2country.QA: {25.354826, 51.183884},
+	ip2country.RE: {-21.115141, 55.536384},
+	ip2country.RO: {45.943161, 24.96676},
+	ip2country.RS: {44.016521, 21.005859},
+	ip2country.RU: {61.52401, 105.318756},
+	ip2country.RW: {-1.940278, 29.873888},
+	ip2country.SA: {23.885942, 45.079162},
+	ip2country.SB: {-9.64571, 160.156194},
+	ip2country.SC: {-4.679574, 55.491977},
+	ip2country.SD: {15.454166, 30.217636},
+	ip2country.SE: {60.128161, 18.643501},
+	ip2country.SG: {1.352083, 103.819836},
+	ip2country.SH: {-15.965, -5.708889},
+	ip2country.SI: {46.151241, 14.995463},
+	ip2country.SJ: {77.553604, 23.670272},
+	ip2country.SK: {48.669026, 19.699024},
+	ip2country.SL: {8.460555, -11.779889},
+	ip2country.SM: {43.94236, 12.457777},
+	ip2country.SN: {14.497401, -14.452362},
+	ip2country.SO: {5.152149, 46.199616},
+	ip2country.SR: {3.919305, -56.027783},
+	ip2country.SS: {7.862685, 29.694923},
+	ip2country.ST: {0.18636, 6.613081},
+	ip2country.SV: {13.794185, -88.89653},
+	ip2country.SX: {18.04248, -63.05483},
+	ip2country.SY: {34.802075, 38.996815},
+	ip2country.SZ: {-26.522503, 31.465866},
+	ip2country.TC: {21.694025, -71.797928},
+	ip2country.TD: {15.454166, 18.732207},
+	ip2country.TF: {-49.280366, 69.348557},
+	ip2country.TG: {8.619543, 0.824782},
+	ip2country.TH: {15.870032, 100.992541},
+	ip2country.TJ: {38.861034, 71.276093},
+	ip2country.TK: {-8.967363, -171.855881},
+	ip2country.TL: {-8.874217, 125.727539},
+	ip2country.TM: {38.969719, 59.556278},
+	ip2country.TN: {33.886917, 9.537499},
+	ip2country.TO: {-21.178986, -175.198242},
+	ip2country.TR: {38.963745, 35.243322},
+	ip2country.TT: {10.691803, -61.222503},
+	ip2country.TV: {-7.109535, 177.64933},
+	ip2country.TW: {23.69781, 120.960515},
+	ip2country.TZ: {-6.369028, 34.888822},
+	ip2country.UA: {48.379433, 31.16558},
+	ip2country.UG: {1.373333, 32.290275},
+	ip2country.UM: {19.2823, 166.647},
+	ip2country.US: {37.09024, -95.712891},
+	ip2country.UY: {-32.522779, -55.765835},
+	ip2country.UZ: {41.377491, 64.585262},
+	ip2country.VA: {41.902916, 12.453389},
+	ip2country.VC: {12.984305, -61.287228},
+	ip2country.VE: {6.42375, -66.58973},
+	ip2country.VG: {18.420695, -64.639968},
+	ip2country.VI: {18.335765, -64.896335},
+	ip2country.VN: {14.058324, 108.277199},
+	ip2country.VU: {-15.376706, 166.959158},
+	ip2country.WF: {-13.768752, -177.156097},
+	ip2country.WS: {-13.759029, -172.104629},
+	ip2country.YE: {15.552727, 48.516388},
+	ip2country.YT: {-12.8275, 45.166244},
+	ip2country.ZA: {-30.559482, 22.937506},
+	ip2country.ZM: {-13.133897, 27.849332},
+	ip2country.ZW: {-19.015438, 29.154857},
+}
+
+// ForCountry returns the centroid of the country with the given alpha-2 code. The
+// comparison is case-insensitive.
+func ForCountry(code string) (Point, bool) {
+	p, ok := centroids[strings.ToUpper(code)]
+	return p, ok
+}
+
+// Distance returns the great-circle distance between a and b in kilometers.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DistanceBetweenCountries returns the distance between the centroids of two countries
+// in kilometers.
+func DistanceBetweenCountries(code1, code2 string) (float64, error) {
+	p1, ok := ForCountry(code1)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrNoCentroid, code1)
+	}
+	p2, ok := ForCountry(code2)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrNoCentroid, code2)
+	}
+	return Distance(p1, p2), nil
+}
+
+// DistanceBetweenIPs resolves both addresses through r and returns the distance between
+// the centroids of their countries in kilometers, e.g. as a coarse fraud signal when a
+// session moves between two addresses. Addresses in the same country are 0 km apart.
+func DistanceBetweenIPs(r ip2country.CountryReader, ip1, ip2 string) (float64, error) {
+	code1, err := r.GetCountryCode(ip1)
+	if err != nil {
+		return 0, err
+	}
+	code2, err := r.GetCountryCode(ip2)
+	if err != nil {
+		return 0, err
+	}
+	return DistanceBetweenCountries(code1, code2)
+}
@@ -0,0 +1,137 @@
+package ip2country
+
+import (
+	"context"
+	"errors"
+)
+
+// ChainLookup queries several lookups in order and returns the first hit, e.g. a local
+// ExactIPCountryMap of overrides, then the range database, then a RemoteLookup. A
+// backend that does not know the address passes the lookup on to the next one.
+// ChainLookup implements IPCountryLookup and is safe for concurrent use if its
+// backends are.
+type ChainLookup struct {
+	backends []IPCountryLookup
+}
+
+// NewChainLookup returns a ChainLookup that queries backends in the given order.
+func NewChainLookup(backends ...IPCountryLookup) *ChainLookup {
+	return &ChainLookup{backends: backends}
+}
+
+// Backends returns the backends in query order.
+func (c *ChainLookup) Backends() []IPCountryLookup {
+	return append([]IPCountryLookup(nil), c.backends...)
+}
+
+// GetCountry retrieves the country code for a given IP address string.
+func (c *ChainLookup) GetCountry(ipStr string) (string, error) {
+	return c.GetCountryWithContext(context.Background(), ipStr)
+}
+
+// GetCountryWithContext retrieves the country code from the first backend that knows
+// the address.
+func (c *ChainLookup) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	return c.first(ctx, func(l IPCountryLookup) (string, error) {
+		return l.GetCountryWithContext(ctx, ipStr)
+	})
+}
+
+// GetCountryCode retrieves the country code for a given IP address string.
+func (c *ChainLookup) GetCountryCode(ipStr string) (string, error) {
+	return c.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryCodeWithContext retrieves the country code from the first backend that
+// knows the address.
+func (c *ChainLookup) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
+	return c.first(ctx, func(l IPCountryLookup) (string, error) {
+		return l.GetCountryCodeWithContext(ctx, ipStr)
+	})
+}
+
+// first returns the first successful answer of lookup across the backends. Misses and
+// failures move on to the next backend; invalid input and cancellation end the chain,
+// since no other backend can answer either. If no backend answered, the first failure
+// is returned in preference to a miss, because a failed backend might have known the
+// address.
+func (c *ChainLookup) first(ctx context.Context, lookup func(IPCountryLookup) (string, error)) (string, error) {
+	var miss, failure error
+	for _, l := range c.backends {
+		code, err := lookup(l)
+		if err == nil {
+			return code, nil
+		}
+		switch ErrorCodeOf(err) {
+		case CodeInvalidIP, CodeCanceled:
+			return "", err
+		case CodeNotFound:
+			miss = err
+		default:
+			if failure == nil {
+				failure = err
+			}
+		}
+		if ctx.Err() != nil {
+			return "", newLookupError(CodeCanceled, ctx.Err())
+		}
+	}
+	switch {
+	case failure != nil:
+		return "", failure
+	case miss != nil:
+		return "", miss
+	default:
+		return "", newLookupError(CodeNotFound, ErrNotFound)
+	}
+}
+
+// Stats returns the counters of all backends added up, with the most recent
+// LastUpdate. Per-dataset fields such as Source and Checksum are left empty; query the
+// backends for them.
+func (c *ChainLookup) Stats() Stats {
+	var s Stats
+	for _, l := range c.backends {
+		b := l.Stats()
+		if b.LastUpdate.After(s.LastUpdate) {
+			s.LastUpdate = b.LastUpdate
+		}
+		s.LoadTime += b.LoadTime
+		s.FileSize += b.FileSize
+		s.CacheHits += b.CacheHits
+		s.CacheMisses += b.CacheMisses
+		s.CacheBytes += b.CacheBytes
+		s.ParseErrors += b.ParseErrors
+		s.RecoveredPanics += b.RecoveredPanics
+		s.TotalRanges += b.TotalRanges
+	}
+	return s
+}
+
+// Reload reloads every backend.
+func (c *ChainLookup) Reload() error {
+	return c.ReloadWithContext(context.Background())
+}
+
+// ReloadWithContext reloads every backend that supports reloading, returning the
+// errors of those that failed joined together.
+func (c *ChainLookup) ReloadWithContext(ctx context.Context) error {
+	var errs []error
+	for _, l := range c.backends {
+		if err := l.ReloadWithContext(ctx); err != nil && ErrorCodeOf(err) != CodeUnsupported {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every backend, returning their errors joined together.
+func (c *ChainLookup) Close() error {
+	var errs []error
+	for _, l := range c.backends {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
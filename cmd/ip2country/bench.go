@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// runBench loads a dataset and measures lookup throughput and latency percentiles.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to the range CSV file (required)")
+	ipsSpec := fs.String("ips", "random:1000000", "lookup workload: random:N, web:N, batch:N or a file with one IP per line")
+	parallel := fs.Int("parallel", runtime.GOMAXPROCS(0), "number of concurrent lookup goroutines")
+	cacheSize := fs.Int("cache-size", ip2country.DefaultConfig().CacheSize, "lookup cache size")
+	eviction := fs.String("cache-eviction", "lru", "cache eviction policy: lru, slru or tinylfu")
+	prefixLen := fs.Int("cache-prefix-len", 0, "cache lookups per prefix of this length instead of per address")
+	index := fs.String("index", "auto", "search index: auto, linear, binary, jump_table, jump_table_16, eytzinger, branchless or interpolation")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of the CSV file")
+	fs.Parse(args)
+
+	if *dbPath == "" || *parallel < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	policy, ok := evictionPolicies[*eviction]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "bench: unknown cache eviction policy %q\n", *eviction)
+		return 2
+	}
+	strategy, ok := indexStrategies[*index]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "bench: unknown search index %q\n", *index)
+		return 2
+	}
+
+	ips, err := loadWorkload(*ipsSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		return 1
+	}
+
+	cfg := ip2country.DefaultConfig()
+	cfg.CacheSize = *cacheSize
+	cfg.CacheEviction = policy
+	cfg.CachePrefixLen = *prefixLen
+	cfg.IndexStrategy = strategy
+	cfg.SkipHeader = *skipHeader
+	db := ip2country.NewIPCountryDB(*dbPath, cfg)
+	if err := db.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		return 1
+	}
+	stats := db.Stats()
+	fmt.Printf("dataset:     %s (%d ranges, loaded in %s)\n", *dbPath, stats.TotalRanges, stats.LoadTime)
+	fmt.Printf("index:       %s\n", stats.SearchStrategy)
+	fmt.Printf("workload:    %d lookups, %d goroutines\n", len(ips), *parallel)
+
+	latencies := make([]time.Duration, len(ips))
+	var misses int64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	chunk := (len(ips) + *parallel - 1) / *parallel
+	began := time.Now()
+	for w := 0; w < *parallel; w++ {
+		lo := w * chunk
+		hi := min(lo+chunk, len(ips))
+		if lo >= hi {
+			break
+		}
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			var localMisses int64
+			for i := lo; i < hi; i++ {
+				t := time.Now()
+				if _, err := db.GetCountryCode(ips[i]); err != nil {
+					localMisses++
+				}
+				latencies[i] = time.Since(t)
+			}
+			mu.Lock()
+			misses += localMisses
+			mu.Unlock()
+		}(lo, hi)
+	}
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats = db.Stats()
+
+	fmt.Printf("elapsed:     %s\n", elapsed)
+	fmt.Printf("throughput:  %.0f lookups/s\n", float64(len(ips))/elapsed.Seconds())
+	fmt.Printf("misses:      %d (%.2f%%)\n", misses, 100*float64(misses)/float64(len(ips)))
+	fmt.Printf("cache:       %d hits, %d misses (%s)\n", stats.CacheHits, stats.CacheMisses, *eviction)
+	fmt.Printf("latency:     p50=%s p90=%s p99=%s p99.9=%s max=%s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99),
+		percentile(latencies, 0.999), latencies[len(latencies)-1])
+	return 0
+}
+
+// evictionPolicies maps the -cache-eviction flag values to cache eviction policies.
+var evictionPolicies = map[string]ip2country.CacheEviction{
+	"lru":     ip2country.CacheEvictLRU,
+	"slru":    ip2country.CacheEvictSLRU,
+	"tinylfu": ip2country.CacheEvictTinyLFU,
+}
+
+// indexStrategies maps the -index flag values to search index strategies.
+var indexStrategies = map[string]ip2country.IndexStrategy{
+	"auto":          ip2country.IndexAuto,
+	"linear":        ip2country.IndexLinear,
+	"binary":        ip2country.IndexBinary,
+	"jump_table":    ip2country.IndexJumpTable,
+	"jump_table_16": ip2country.IndexJumpTable16,
+	"eytzinger":     ip2country.IndexEytzinger,
+	"branchless":    ip2country.IndexBranchless,
+	"interpolation": ip2country.IndexInterpolation,
+}
+
+// webClients is the number of distinct addresses in the synthetic web workloads.
+const webClients = 100000
+
+// workloads generates synthetic lookup sequences of a given length:
+//   - random: uniformly random addresses, so the cache rarely hits.
+//   - web: Zipf-distributed requests from a fixed client population, like web traffic.
+//   - batch: web traffic interleaved with a scan over unique addresses, like a log
+//     replay, which flushes the hot set out of a plain LRU cache.
+var workloads = map[string]func(count int) []uint32{
+	"random": func(count int) []uint32 {
+		ips := make([]uint32, count)
+		for i := range ips {
+			ips[i] = rand.Uint32()
+		}
+		return ips
+	},
+	"web": func(count int) []uint32 {
+		clients, zipf := webTraffic()
+		ips := make([]uint32, count)
+		for i := range ips {
+			ips[i] = clients[zipf.Uint64()]
+		}
+		return ips
+	},
+	"batch": func(count int) []uint32 {
+		clients, zipf := webTraffic()
+		scan := rand.Uint32()
+		ips := make([]uint32, count)
+		for i := range ips {
+			if i%2 == 0 {
+				ips[i] = clients[zipf.Uint64()]
+			} else {
+				ips[i] = scan
+				scan++
+			}
+		}
+		return ips
+	},
+}
+
+// webTraffic returns a random client population and a Zipf distribution over it.
+func webTraffic() ([]uint32, *rand.Zipf) {
+	clients := make([]uint32, webClients)
+	for i := range clients {
+		clients[i] = rand.Uint32()
+	}
+	zipf := rand.NewZipf(rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())), 1.1, 1, webClients-1)
+	return clients, zipf
+}
+
+// loadWorkload builds the list of IPs to look up from a "<workload>:N" spec or a file path.
+func loadWorkload(spec string) ([]string, error) {
+	if name, n, ok := strings.Cut(spec, ":"); ok && workloads[name] != nil {
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid %s workload %q", name, spec)
+		}
+		ips := make([]string, count)
+		for i, v := range workloads[name](count) {
+			b := [4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+			ips[i] = netip.AddrFrom4(b).String()
+		}
+		return ips, nil
+	}
+
+	file, err := os.Open(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workload: %w", err)
+	}
+	defer file.Close()
+
+	var ips []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			ips = append(ips, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workload: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("workload %s is empty", spec)
+	}
+	return ips, nil
+}
+
+// percentile returns the q-th quantile of an ascending slice of durations.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	idx := int(q * float64(len(sorted)-1))
+	return sorted[idx]
+}
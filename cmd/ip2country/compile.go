@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// runCompile loads a dataset and writes it as an index file.
+func runCompile(args []string) int {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to the range CSV file (required)")
+	out := fs.String("out", "", "path of the index file to write (required)")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of the CSV file")
+	fs.Parse(args)
+
+	if *dbPath == "" || *out == "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := ip2country.DefaultConfig()
+	cfg.SkipHeader = *skipHeader
+	db := ip2country.NewIPCountryDB(*dbPath, cfg)
+	if err := db.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "compile: %v\n", err)
+		return 1
+	}
+
+	// Written next to the target and renamed, so processes never map a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(*out), ".ip2country-*.idx")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "compile: %v\n", err)
+		return 1
+	}
+	defer os.Remove(tmp.Name())
+	if err := db.WriteIndex(tmp); err != nil {
+		tmp.Close()
+		fmt.Fprintf(os.Stderr, "compile: %v\n", err)
+		return 1
+	}
+	// CreateTemp creates the file private to this user.
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		fmt.Fprintf(os.Stderr, "compile: %v\n", err)
+		return 1
+	}
+	if err := tmp.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "compile: %v\n", err)
+		return 1
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		fmt.Fprintf(os.Stderr, "compile: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "compile: wrote %d ranges to %s\n", db.Stats().TotalRanges, *out)
+	return 0
+}
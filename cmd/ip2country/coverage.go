@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// coverageFormats maps --format values to coverage output formats.
+var coverageFormats = map[string]ip2country.CoverageFormat{
+	"csv":  ip2country.CoverageCSV,
+	"json": ip2country.CoverageJSON,
+}
+
+// runCoverage loads a dataset and writes the share of each /8 or /16 block it covers.
+func runCoverage(args []string) int {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to the range CSV file (required)")
+	format := fs.String("format", "csv", "output format: csv or json")
+	bits := fs.Int("bits", 8, "prefix length of the blocks, between 1 and 16")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of the CSV file")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	coverageFormat, ok := coverageFormats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "coverage: unknown format %q\n", *format)
+		return 2
+	}
+
+	cfg := ip2country.DefaultConfig()
+	cfg.SkipHeader = *skipHeader
+	db := ip2country.NewIPCountryDB(*dbPath, cfg)
+	if err := db.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "coverage: %v\n", err)
+		return 1
+	}
+	if err := db.ExportCoverage(os.Stdout, *bits, coverageFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "coverage: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// Exit codes of the diff command, so pipelines can tell a failed gate from a broken run.
+const (
+	diffExitOK       = 0
+	diffExitError    = 1
+	diffExitUsage    = 2
+	diffExitExceeded = 3
+)
+
+// runDiff compares two range datasets and fails when the new one changes more address
+// space than allowed.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	maxChangedPct := fs.Float64("max-changed-pct", 0, "exit with status 3 if more than this percentage of covered address space changed (0 disables the gate)")
+	show := fs.Int("show", 20, "number of changed intervals to print")
+	asJSON := fs.Bool("json", false, "print the full diff as JSON")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of both CSV files")
+	maxRanges := fs.Int("max-ranges", 0, "fail if a dataset has more than this many ranges (0 for no limit)")
+	maxFileSize := fs.Int64("max-file-size", 0, "fail if a dataset is larger than this many bytes (0 for no limit)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ip2country diff [flags] old.csv new.csv")
+		fmt.Fprintln(os.Stderr, "\nExit status: 0 within threshold, 1 on errors, 2 on usage errors, 3 if the threshold is exceeded.")
+		fs.PrintDefaults()
+	}
+	paths := parseInterspersed(fs, args)
+
+	if len(paths) != 2 || *maxChangedPct < 0 {
+		fs.Usage()
+		return diffExitUsage
+	}
+
+	// Datasets are compared whole: the library defaults would cut large releases short,
+	// and the missing tail would count as removed address space.
+	cfg := ip2country.DefaultConfig()
+	cfg.SkipHeader = *skipHeader
+	cfg.MaxRanges = *maxRanges
+	cfg.MaxFileSize = *maxFileSize
+	oldRanges, err := loadRanges(paths[0], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return diffExitError
+	}
+	newRanges, err := loadRanges(paths[1], cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return diffExitError
+	}
+
+	d := ip2country.DiffRanges(oldRanges, newRanges)
+	exceeded := *maxChangedPct > 0 && d.ChangedPercent() > *maxChangedPct
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			ip2country.DatasetDiff
+			ChangedPercent float64 `json:"changed_percent"`
+			Exceeded       bool    `json:"exceeded"`
+		}{d, d.ChangedPercent(), exceeded})
+	} else {
+		printDiff(d, *show)
+	}
+
+	if exceeded {
+		fmt.Fprintf(os.Stderr, "diff: %.3f%% of covered address space changed, above the limit of %.3f%%\n",
+			d.ChangedPercent(), *maxChangedPct)
+		return diffExitExceeded
+	}
+	return diffExitOK
+}
+
+// parseInterspersed parses flags that may appear before, between or after the
+// positional arguments, e.g. "old.csv new.csv --max-changed-pct 5", and returns the
+// positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// loadRanges parses and validates a range dataset. Parsing stops at cfg.MaxRanges, so
+// reaching it is an error rather than a diff of partial data.
+func loadRanges(path string, cfg ip2country.Config) ([]ip2country.IPRange, error) {
+	if cfg.MaxRanges > 0 {
+		// Read one range past the limit to tell a full dataset from a truncated one.
+		cfg.MaxRanges++
+	}
+	result, err := ip2country.ParseCSVRanges(path, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.MaxRanges > 0 && len(result.Ranges) >= cfg.MaxRanges {
+		return nil, fmt.Errorf("%s: more than %d ranges, raise --max-ranges", path, cfg.MaxRanges-1)
+	}
+	if err := ip2country.ValidateIPRanges(result.Ranges); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if result.ErrorSummary.Total > 0 {
+		fmt.Fprintf(os.Stderr, "diff: %s: %s\n", path, result.ErrorSummary)
+	}
+	return result.Ranges, nil
+}
+
+// printDiff writes a human-readable summary and the first show changed intervals.
+func printDiff(d ip2country.DatasetDiff, show int) {
+	fmt.Printf("covered:      %d addresses\n", d.CoveredAddresses)
+	fmt.Printf("changed:      %d addresses (%.3f%%) in %d intervals\n", d.ChangedAddresses(), d.ChangedPercent(), len(d.Changes))
+	fmt.Printf("  added:      %d\n", d.AddedAddresses)
+	fmt.Printf("  removed:    %d\n", d.RemovedAddresses)
+	fmt.Printf("  reassigned: %d\n", d.ReassignedAddresses)
+
+	for i, c := range d.Changes {
+		if i == show {
+			fmt.Printf("... %d more\n", len(d.Changes)-show)
+			break
+		}
+		fmt.Printf("%s-%s  %s -> %s\n", formatIP(c.StartIP), formatIP(c.EndIP), codeOrDash(c.OldCode), codeOrDash(c.NewCode))
+	}
+}
+
+// formatIP renders an address in dot-decimal notation.
+func formatIP(ip uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], ip)
+	return netip.AddrFrom4(b).String()
+}
+
+// codeOrDash renders an uncovered interval's empty code as "-".
+func codeOrDash(code string) string {
+	if code == "" {
+		return "-"
+	}
+	return code
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// runEnrich loads a dataset and adds the country code to JSON events, read from stdin
+// or posted over HTTP.
+func runEnrich(args []string) int {
+	fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to the range CSV file (required)")
+	field := fs.String("field", "ip", "dot-separated path of the address in each event")
+	target := fs.String("target", "country_code", "dot-separated path to write the country code to")
+	unknown := fs.String("unknown", "", "code to write for unresolved addresses (default leave unset)")
+	listen := fs.String("listen", "", "serve POST requests on this address instead of reading stdin, e.g. :8080")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of the CSV file")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := ip2country.DefaultConfig()
+	cfg.SkipHeader = *skipHeader
+	db := ip2country.NewIPCountryDB(*dbPath, cfg)
+	if err := db.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "enrich: %v\n", err)
+		return 1
+	}
+	opts := ip2country.EnrichOptions{Field: *field, Target: *target, UnknownCode: *unknown}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *listen != "" {
+		srv := &http.Server{Addr: *listen, Handler: ip2country.EnrichHandler(db, opts)}
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "enrich: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if err := ip2country.EnrichJSONLines(ctx, os.Stdin, os.Stdout, db, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "enrich: %v\n", err)
+		return 1
+	}
+	return 0
+}
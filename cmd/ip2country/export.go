@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// exportFormats maps --format values to export formats.
+var exportFormats = map[string]ip2country.ExportFormat{
+	"fastly":     ip2country.ExportFastlyVCL,
+	"cloudflare": ip2country.ExportCloudflare,
+	"aws-waf":    ip2country.ExportAWSWAF,
+	"csv":        ip2country.ExportCSV,
+	"cidr":       ip2country.ExportCIDR,
+	"index":      ip2country.ExportIndex,
+	"mmdb":       ip2country.ExportMMDB,
+}
+
+// runExport loads a dataset and writes it in another format, e.g. per-country prefix
+// lists for CDN or firewall rules.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to the range CSV file (required)")
+	format := fs.String("format", "fastly", "output format: fastly, cloudflare, aws-waf, csv, cidr, index or mmdb")
+	countries := fs.String("countries", "", "comma-separated country codes to export (default all)")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of the CSV file")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	exportFormat, ok := exportFormats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "export: unknown format %q\n", *format)
+		return 2
+	}
+
+	var codes []string
+	if *countries != "" {
+		codes = strings.Split(*countries, ",")
+	}
+
+	cfg := ip2country.DefaultConfig()
+	cfg.SkipHeader = *skipHeader
+	db := ip2country.NewIPCountryDB(*dbPath, cfg)
+	if err := db.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if err := db.Export(out, exportFormat, ip2country.ExportOptions{Codes: codes}); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	return 0
+}
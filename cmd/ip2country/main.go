@@ -0,0 +1,68 @@
+// Command ip2country provides operational tooling for ip2country datasets.
+//
+// Usage:
+//
+//	ip2country <command> [flags]
+//
+// Commands:
+//
+//	bench     measure lookup throughput and latency of a dataset on this machine
+//	compile   compile a dataset into an index file that is memory-mapped when loaded
+//	coverage  write the share of each /8 or /16 block covered by a dataset as CSV or JSON
+//	diff      compare two datasets and gate releases on the share of changed address space
+//	enrich    add country codes to JSON events from stdin or HTTP, for stream processors
+//	export    write a dataset as CSV, a CIDR list, an index or MMDB file, or as Fastly,
+//	          Cloudflare or AWS WAF configuration
+//	validate  check the syntax, order and overlaps of a dataset in one pass without loading it
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a CLI subcommand. run returns the process exit code.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{name: "bench", summary: "measure lookup throughput and latency of a dataset", run: runBench},
+	{name: "compile", summary: "compile a dataset into a memory-mappable index file", run: runCompile},
+	{name: "coverage", summary: "write per-block address coverage of a dataset", run: runCoverage},
+	{name: "diff", summary: "compare two datasets, failing above a change threshold", run: runDiff},
+	{name: "enrich", summary: "add country codes to JSON events for stream processors", run: runEnrich},
+	{name: "export", summary: "write a dataset in another format or as CDN and firewall rules", run: runExport},
+	{name: "validate", summary: "check a dataset in one pass without loading it", run: runValidate},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "ip2country: unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: ip2country <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'ip2country <command> -h' for command flags.")
+}
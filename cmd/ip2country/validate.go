@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// runValidate checks the syntax, order and overlaps of a dataset in a single pass,
+// without loading it.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	dbPath := fs.String("db", "", "path to the range CSV file, or - for stdin (required)")
+	skipHeader := fs.Bool("skip-header", false, "skip the first line of the CSV file")
+	strict := fs.Bool("strict", false, "stop at the first invalid line")
+	show := fs.Int("show", 20, "number of invalid lines to print")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	var r io.Reader = os.Stdin
+	if *dbPath != "-" {
+		f, err := os.Open(*dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "validate: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg := ip2country.DefaultConfig()
+	cfg.SkipHeader = *skipHeader
+	cfg.StrictParsing = *strict
+	cfg.MaxStoredParseErrors = max(*show, 1)
+	result, err := ip2country.ValidateCSVStream(ctx, r, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate: %v\n", err)
+		return 1
+	}
+
+	for _, perr := range result.Errors[:min(*show, len(result.Errors))] {
+		fmt.Println(perr)
+	}
+	fmt.Printf("%d ranges, %s\n", result.Stats.TotalRanges, result.ErrorSummary)
+	if result.ErrorSummary.Total > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,135 @@
+// Command libip2country builds ip2country as a C shared library, so the lookup core can
+// be embedded into C, C++ or Rust services through a plain C ABI:
+//
+//	go build -buildmode=c-shared -o libip2country.so ./cmd/libip2country
+//
+// The build also writes libip2country.h with the declarations below. A minimal C program:
+//
+//	#include "libip2country.h"
+//
+//	char code[IP2COUNTRY_CODE_SIZE];
+//	if (ip2country_open("ip_to_country.csv") != IP2COUNTRY_OK) { ... }
+//	if (ip2country_lookup("8.8.8.8", code) == IP2COUNTRY_OK) printf("%s\n", code);
+//	ip2country_close();
+//
+// compiled with: cc main.c -L. -lip2country -o main
+//
+// All functions are safe to call from multiple threads.
+package main
+
+/*
+#define IP2COUNTRY_OK               0
+#define IP2COUNTRY_ERR_NOT_FOUND   -1
+#define IP2COUNTRY_ERR_INVALID_IP  -2
+#define IP2COUNTRY_ERR_NOT_LOADED  -3
+#define IP2COUNTRY_ERR_INTERNAL    -4
+
+// IP2COUNTRY_CODE_SIZE is the minimum size of the out buffer passed to
+// ip2country_lookup, including the terminating NUL.
+#define IP2COUNTRY_CODE_SIZE        8
+
+typedef const char ip2country_cchar;
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+var (
+	mu sync.RWMutex
+	db *ip2country.IPCountryDB
+)
+
+// ip2country_open loads the DB-IP range CSV file at path, replacing any previously
+// opened dataset. It returns IP2COUNTRY_OK or a negative error code.
+//
+//export ip2country_open
+func ip2country_open(path *C.ip2country_cchar) C.int {
+	next := ip2country.NewIPCountryDB(C.GoString((*C.char)(path)))
+	if err := next.Reload(); err != nil {
+		return errorCode(err)
+	}
+
+	mu.Lock()
+	prev := db
+	db = next
+	mu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+	return C.IP2COUNTRY_OK
+}
+
+// ip2country_lookup writes the NUL-terminated country code for ip into out, which must
+// hold at least IP2COUNTRY_CODE_SIZE bytes. It returns IP2COUNTRY_OK or a negative
+// error code, in which case out is set to the empty string.
+//
+//export ip2country_lookup
+func ip2country_lookup(ip *C.ip2country_cchar, out *C.char) C.int {
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(out)), C.IP2COUNTRY_CODE_SIZE)
+	buf[0] = 0
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if db == nil {
+		return C.IP2COUNTRY_ERR_NOT_LOADED
+	}
+	code, err := db.GetCountryCode(C.GoString((*C.char)(ip)))
+	if err != nil {
+		return errorCode(err)
+	}
+	n := copy(buf[:len(buf)-1], code)
+	buf[n] = 0
+	return C.IP2COUNTRY_OK
+}
+
+// ip2country_reload reloads the opened dataset from its file. On failure the previous
+// dataset stays in service.
+//
+//export ip2country_reload
+func ip2country_reload() C.int {
+	mu.RLock()
+	defer mu.RUnlock()
+	if db == nil {
+		return C.IP2COUNTRY_ERR_NOT_LOADED
+	}
+	if err := db.Reload(); err != nil {
+		return errorCode(err)
+	}
+	return C.IP2COUNTRY_OK
+}
+
+// ip2country_close releases the opened dataset. Lookups then fail with
+// IP2COUNTRY_ERR_NOT_LOADED until ip2country_open is called again.
+//
+//export ip2country_close
+func ip2country_close() {
+	mu.Lock()
+	prev := db
+	db = nil
+	mu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+}
+
+// errorCode maps an error to the C error code of its ip2country.ErrorCode.
+func errorCode(err error) C.int {
+	switch ip2country.ErrorCodeOf(err) {
+	case ip2country.CodeNotFound, ip2country.CodeAmbiguous:
+		return C.IP2COUNTRY_ERR_NOT_FOUND
+	case ip2country.CodeInvalidIP:
+		return C.IP2COUNTRY_ERR_INVALID_IP
+	case ip2country.CodeNotLoaded, ip2country.CodeStaleData, ip2country.CodeClosed:
+		return C.IP2COUNTRY_ERR_NOT_LOADED
+	default:
+		return C.IP2COUNTRY_ERR_INTERNAL
+	}
+}
+
+// main is required by -buildmode=c-shared and is never called.
+func main() {}
@@ -0,0 +1,98 @@
+package ip2country
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// rangeColumns is a sorted range dataset held as parallel arrays, with the country,
+// code and alternate codes stored once per distinct combination. A range takes 10 bytes
+// instead of the 64 of an IPRange, and the start addresses searched by lookups are
+// contiguous in memory.
+type rangeColumns struct {
+	starts   []uint32
+	ends     []uint32
+	labelIDs []uint16
+	labels   []rangeLabel
+	index    map[labelKey]uint16 // Label IDs by label; dropped by finish.
+	sorted   bool
+}
+
+// newRangeColumns returns empty columns with room for capacity ranges.
+func newRangeColumns(capacity int) *rangeColumns {
+	return &rangeColumns{
+		starts:   make([]uint32, 0, capacity),
+		ends:     make([]uint32, 0, capacity),
+		labelIDs: make([]uint16, 0, capacity),
+		index:    make(map[labelKey]uint16),
+		sorted:   true,
+	}
+}
+
+// add appends r to the columns.
+func (c *rangeColumns) add(r IPRange) error {
+	if c.index == nil {
+		// Dropped by finish; rebuilt for stores filled after loading.
+		c.index = make(map[labelKey]uint16, len(c.labels))
+		for id, l := range c.labels {
+			c.index[labelKey{country: l.country, code: l.code, alternates: strings.Join(l.alternates, codeSeparator)}] = uint16(id)
+		}
+	}
+	key := labelKey{country: r.Country, code: r.Code, alternates: strings.Join(r.AlternateCodes, codeSeparator)}
+	id, ok := c.index[key]
+	if !ok {
+		if len(c.labels) > math.MaxUint16 {
+			return fmt.Errorf("dataset has more than %d distinct country labels", math.MaxUint16+1)
+		}
+		id = uint16(len(c.labels))
+		c.labels = append(c.labels, rangeLabel{country: r.Country, code: r.Code, alternates: r.AlternateCodes})
+		c.index[key] = id
+	}
+	if n := len(c.starts); n > 0 && r.StartIP < c.starts[n-1] {
+		c.sorted = false
+	}
+	c.starts = append(c.starts, r.StartIP)
+	c.ends = append(c.ends, r.EndIP)
+	c.labelIDs = append(c.labelIDs, id)
+	return nil
+}
+
+// finish sorts the ranges by start address unless they were added in order.
+func (c *rangeColumns) finish() {
+	c.index = nil
+	if !c.sorted {
+		sort.Sort(c)
+		c.sorted = true
+	}
+}
+
+func (c *rangeColumns) Len() int { return len(c.starts) }
+
+func (c *rangeColumns) Less(i, j int) bool { return c.starts[i] < c.starts[j] }
+
+func (c *rangeColumns) Swap(i, j int) {
+	c.starts[i], c.starts[j] = c.starts[j], c.starts[i]
+	c.ends[i], c.ends[j] = c.ends[j], c.ends[i]
+	c.labelIDs[i], c.labelIDs[j] = c.labelIDs[j], c.labelIDs[i]
+}
+
+func (c *rangeColumns) len() int {
+	return len(c.starts)
+}
+
+func (c *rangeColumns) startAt(i int) uint32 {
+	return c.starts[i]
+}
+
+func (c *rangeColumns) at(i int) IPRange {
+	label := &c.labels[c.labelIDs[i]]
+	return IPRange{
+		Country:        label.country,
+		Code:           label.code,
+		AlternateCodes: label.alternates,
+		StartIP:        c.starts[i],
+		EndIP:          c.ends[i],
+	}
+}
@@ -0,0 +1,110 @@
+package ip2country
+
+import "strings"
+
+// Continent codes, as returned by GetContinentCode. They follow the two-letter codes
+// used by GeoNames and MaxMind.
+const (
+	ContinentAfrica       = "AF" // Africa
+	ContinentAntarctica   = "AN" // Antarctica
+	ContinentAsia         = "AS" // Asia
+	ContinentEurope       = "EU" // Europe
+	ContinentNorthAmerica = "NA" // North America
+	ContinentOceania      = "OC" // Oceania
+	ContinentSouthAmerica = "SA" // South America
+)
+
+// countryContinents maps ISO 3166-1 alpha-2 country codes to continent codes. Countries
+// spanning two continents are assigned the one GeoNames uses, e.g. Russia to Europe
+// and Turkey to Asia.
+var countryContinents = map[string]string{
+	// Africa
+	AO: ContinentAfrica, BF: ContinentAfrica, BI: ContinentAfrica, BJ: ContinentAfrica,
+	BW: ContinentAfrica, CD: ContinentAfrica, CF: ContinentAfrica, CG: ContinentAfrica,
+	CI: ContinentAfrica, CM: ContinentAfrica, CV: ContinentAfrica, DJ: ContinentAfrica,
+	DZ: ContinentAfrica, EG: ContinentAfrica, EH: ContinentAfrica, ER: ContinentAfrica,
+	ET: ContinentAfrica, GA: ContinentAfrica, GH: ContinentAfrica, GM: ContinentAfrica,
+	GN: ContinentAfrica, GQ: ContinentAfrica, GW: ContinentAfrica, KE: ContinentAfrica,
+	KM: ContinentAfrica, LR: ContinentAfrica, LS: ContinentAfrica, LY: ContinentAfrica,
+	MA: ContinentAfrica, MG: ContinentAfrica, ML: ContinentAfrica, MR: ContinentAfrica,
+	MU: ContinentAfrica, MW: ContinentAfrica, MZ: ContinentAfrica, NA: ContinentAfrica,
+	NE: ContinentAfrica, NG: ContinentAfrica, RE: ContinentAfrica, RW: ContinentAfrica,
+	SC: ContinentAfrica, SD: ContinentAfrica, SH: ContinentAfrica, SL: ContinentAfrica,
+	SN: ContinentAfrica, SO: ContinentAfrica, SS: ContinentAfrica, ST: ContinentAfrica,
+	SZ: ContinentAfrica, TD: ContinentAfrica, TG: ContinentAfrica, TN: ContinentAfrica,
+	TZ: ContinentAfrica, UG: ContinentAfrica, YT: ContinentAfrica, ZA: ContinentAfrica,
+	ZM: ContinentAfrica, ZW: ContinentAfrica,
+	// Antarctica
+	AQ: ContinentAntarctica, BV: ContinentAntarctica, GS: ContinentAntarctica, HM: ContinentAntarctica,
+	TF: ContinentAntarctica,
+	// Asia
+	AE: ContinentAsia, AF: ContinentAsia, AM: ContinentAsia, AZ: ContinentAsia,
+	BD: ContinentAsia, BH: ContinentAsia, BN: ContinentAsia, BT: ContinentAsia,
+	CC: ContinentAsia, CN: ContinentAsia, CX: ContinentAsia, GE: ContinentAsia,
+	HK: ContinentAsia, ID: ContinentAsia, IL: ContinentAsia, IN: ContinentAsia,
+	IO: ContinentAsia, IQ: ContinentAsia, IR: ContinentAsia, JO: ContinentAsia,
+	JP: ContinentAsia, KG: ContinentAsia, KH: ContinentAsia, KP: ContinentAsia,
+	KR: ContinentAsia, KW: ContinentAsia, KZ: ContinentAsia, LA: ContinentAsia,
+	LB: ContinentAsia, LK: ContinentAsia, MM: ContinentAsia, MN: ContinentAsia,
+	MO: ContinentAsia, MV: ContinentAsia, MY: ContinentAsia, NP: ContinentAsia,
+	OM: ContinentAsia, PH: ContinentAsia, PK: ContinentAsia, PS: ContinentAsia,
+	QA: ContinentAsia, SA: ContinentAsia, SG: ContinentAsia, SY: ContinentAsia,
+	TH: ContinentAsia, TJ: ContinentAsia, TL: ContinentAsia, TM: ContinentAsia,
+	TR: ContinentAsia, TW: ContinentAsia, UZ: ContinentAsia, VN: ContinentAsia,
+	YE: ContinentAsia,
+	// Europe
+	AD: ContinentEurope, AL: ContinentEurope, AT: ContinentEurope, AX: ContinentEurope,
+	BA: ContinentEurope, BE: ContinentEurope, BG: ContinentEurope, BY: ContinentEurope,
+	CH: ContinentEurope, CY: ContinentEurope, CZ: ContinentEurope, DE: ContinentEurope,
+	DK: ContinentEurope, EE: ContinentEurope, ES: ContinentEurope, FI: ContinentEurope,
+	FO: ContinentEurope, FR: ContinentEurope, GB: ContinentEurope, GG: ContinentEurope,
+	GI: ContinentEurope, GR: ContinentEurope, HR: ContinentEurope, HU: ContinentEurope,
+	IE: ContinentEurope, IM: ContinentEurope, IS: ContinentEurope, IT: ContinentEurope,
+	JE: ContinentEurope, LI: ContinentEurope, LT: ContinentEurope, LU: ContinentEurope,
+	LV: ContinentEurope, MC: ContinentEurope, MD: ContinentEurope, ME: ContinentEurope,
+	MK: ContinentEurope, MT: ContinentEurope, NL: ContinentEurope, NO: ContinentEurope,
+	PL: ContinentEurope, PT: ContinentEurope, RO: ContinentEurope, RS: ContinentEurope,
+	RU: ContinentEurope, SE: ContinentEurope, SI: ContinentEurope, SJ: ContinentEurope,
+	SK: ContinentEurope, SM: ContinentEurope, UA: ContinentEurope, VA: ContinentEurope,
+	// North America
+	AG: ContinentNorthAmerica, AI: ContinentNorthAmerica, AW: ContinentNorthAmerica, BB: ContinentNorthAmerica,
+	BL: ContinentNorthAmerica, BM: ContinentNorthAmerica, BQ: ContinentNorthAmerica, BS: ContinentNorthAmerica,
+	BZ: ContinentNorthAmerica, CA: ContinentNorthAmerica, CR: ContinentNorthAmerica, CU: ContinentNorthAmerica,
+	CW: ContinentNorthAmerica, DM: ContinentNorthAmerica, DO: ContinentNorthAmerica, GD: ContinentNorthAmerica,
+	GL: ContinentNorthAmerica, GP: ContinentNorthAmerica, GT: ContinentNorthAmerica, HN: ContinentNorthAmerica,
+	HT: ContinentNorthAmerica, JM: ContinentNorthAmerica, KN: ContinentNorthAmerica, KY: ContinentNorthAmerica,
+	LC: ContinentNorthAmerica, MF: ContinentNorthAmerica, MQ: ContinentNorthAmerica, MS: ContinentNorthAmerica,
+	MX: ContinentNorthAmerica, NI: ContinentNorthAmerica, PA: ContinentNorthAmerica, PM: ContinentNorthAmerica,
+	PR: ContinentNorthAmerica, SV: ContinentNorthAmerica, SX: ContinentNorthAmerica, TC: ContinentNorthAmerica,
+	TT: ContinentNorthAmerica, US: ContinentNorthAmerica, VC: ContinentNorthAmerica, VG: ContinentNorthAmerica,
+	VI: ContinentNorthAmerica,
+	// Oceania
+	AS: ContinentOceania, AU: ContinentOceania, CK: ContinentOceania, FJ: ContinentOceania,
+	FM: ContinentOceania, GU: ContinentOceania, KI: ContinentOceania, MH: ContinentOceania,
+	MP: ContinentOceania, NC: ContinentOceania, NF: ContinentOceania, NR: ContinentOceania,
+	NU: ContinentOceania, NZ: ContinentOceania, PF: ContinentOceania, PG: ContinentOceania,
+	PN: ContinentOceania, PW: ContinentOceania, SB: ContinentOceania, TK: ContinentOceania,
+	TO: ContinentOceania, TV: ContinentOceania, UM: ContinentOceania, VU: ContinentOceania,
+	WF: ContinentOceania, WS: ContinentOceania,
+	// South America
+	AR: ContinentSouthAmerica, BO: ContinentSouthAmerica, BR: ContinentSouthAmerica, CL: ContinentSouthAmerica,
+	CO: ContinentSouthAmerica, EC: ContinentSouthAmerica, FK: ContinentSouthAmerica, GF: ContinentSouthAmerica,
+	GY: ContinentSouthAmerica, PE: ContinentSouthAmerica, PY: ContinentSouthAmerica, SR: ContinentSouthAmerica,
+	UY: ContinentSouthAmerica, VE: ContinentSouthAmerica,
+}
+
+// ContinentForCountry returns the continent code (e.g., "EU") for a country code. It
+// returns an empty string if the country is not in the embedded mapping.
+func ContinentForCountry(code string) string {
+	return countryContinents[strings.ToUpper(code)]
+}
+
+// getContinentCode resolves an IP address through the given lookup and maps the result
+// to a continent code.
+func getContinentCode(l CountryReader, ipStr string) (string, error) {
+	code, err := l.GetCountryCode(ipStr)
+	if err != nil {
+		return "", err
+	}
+	return ContinentForCountry(code), nil
+}
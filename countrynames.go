@@ -0,0 +1,147 @@
+package ip2country
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// countryAliases maps common names that differ from the ISO 3166-1 short names, in the
+// form produced by normalizeName, to alpha-2 codes.
+var countryAliases = map[string]string{
+	"britain":                          GB,
+	"brunei":                           BN,
+	"burma":                            MM,
+	"cape verde":                       CV,
+	"czech republic":                   CZ,
+	"democratic republic of the congo": CD,
+	"dr congo":                         CD,
+	"east timor":                       TL,
+	"england":                          GB,
+	"great britain":                    GB,
+	"holland":                          NL,
+	"ivory coast":                      CI,
+	"laos":                             LA,
+	"macau":                            MO,
+	"macedonia":                        MK,
+	"north korea":                      KP,
+	"palestine":                        PS,
+	"republic of the congo":            CG,
+	"russia":                           RU,
+	"south korea":                      KR,
+	"swaziland":                        SZ,
+	"syria":                            SY,
+	"turkey":                           TR,
+	"uk":                               GB,
+	"united states of america":         US,
+	"usa":                              US,
+	"vatican":                          VA,
+	"vatican city":                     VA,
+	"vietnam":                          VN,
+}
+
+// nameFolds replaces the accented letters found in the embedded country names.
+var nameFolds = strings.NewReplacer("å", "a", "ç", "c", "é", "e", "ô", "o", "ü", "u")
+
+// normalizeName lowercases name, folds accents and reduces punctuation and repeated
+// spaces to single spaces, so "Côte d'Ivoire" and "cote d ivoire" compare equal.
+func normalizeName(name string) string {
+	name = nameFolds.Replace(strings.ToLower(name))
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	}), " ")
+}
+
+// nameEntry is a searchable name of a country: its ISO short name or an alias.
+type nameEntry struct {
+	key  string // normalized name
+	code string
+}
+
+// nameIndex holds the names and aliases of all countries sorted by normalized name.
+var nameIndex = sync.OnceValue(func() []nameEntry {
+	entries := make([]nameEntry, 0, len(countryInfos)+len(countryAliases))
+	for code, info := range countryInfos {
+		entries = append(entries, nameEntry{key: normalizeName(info.Name), code: code})
+	}
+	for alias, code := range countryAliases {
+		entries = append(entries, nameEntry{key: alias, code: code})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries
+})
+
+// CodeForName returns the alpha-2 code of the country with the given name, e.g. "DE"
+// for "Germany". Names are compared case-insensitively, ignoring accents, punctuation
+// and a leading "the", and common alternatives such as "Russia" or "Ivory Coast" are
+// recognized. Alpha-2 and alpha-3 codes are accepted as well.
+func CodeForName(name string) (string, bool) {
+	key := strings.TrimPrefix(normalizeName(name), "the ")
+	if key == "" {
+		return "", false
+	}
+	if code, ok := countryAliases[key]; ok {
+		return code, true
+	}
+	entries := nameIndex()
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].key >= key })
+	if i < len(entries) && entries[i].key == key {
+		return entries[i].code, true
+	}
+
+	upper := strings.ToUpper(key)
+	if _, ok := countryInfos[upper]; ok {
+		return upper, true
+	}
+	for code, info := range countryInfos {
+		if info.Alpha3 == upper {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// SearchCountries returns the countries whose name, or a common alternative name,
+// starts with query or has a word starting with it, e.g. "kor" finds both Koreas and
+// "ger" finds Germany. It is meant for autocompletion in admin interfaces: names that
+// start with query come first, then those matched by a later word, each in alphabetical
+// order. At most limit countries are returned; limit 0 or less means no limit.
+func SearchCountries(query string, limit int) []CountryInfo {
+	query = normalizeName(query)
+	if query == "" {
+		return nil
+	}
+
+	// A country can match through both its name and an alias; it is listed once, in
+	// the better of the two groups.
+	var leading, inner []string
+	rank := make(map[string]int)
+	for _, e := range nameIndex() {
+		switch {
+		case strings.HasPrefix(e.key, query):
+			if rank[e.code] < 2 {
+				rank[e.code] = 2
+				leading = append(leading, e.code)
+			}
+		case strings.Contains(e.key, " "+query):
+			if rank[e.code] < 1 {
+				rank[e.code] = 1
+				inner = append(inner, e.code)
+			}
+		}
+	}
+
+	var results []CountryInfo
+	for _, code := range leading {
+		results = append(results, countryInfos[code])
+	}
+	for _, code := range inner {
+		if rank[code] == 1 {
+			results = append(results, countryInfos[code])
+		}
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
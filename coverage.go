@@ -0,0 +1,90 @@
+package ip2country
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// CoverageFormat selects the output format of ExportCoverage.
+type CoverageFormat int
+
+const (
+	// CoverageCSV writes a prefix,covered,ratio header followed by one line per block.
+	CoverageCSV CoverageFormat = iota
+	// CoverageJSON writes a JSON array of CoverageBucket values.
+	CoverageJSON
+)
+
+// CoverageBucket reports how much of an address block the dataset covers.
+// Fields are ordered for optimal memory alignment.
+type CoverageBucket struct {
+	// Prefix is the block, e.g. 1.0.0.0/8.
+	Prefix netip.Prefix `json:"prefix"`
+	// Covered is the number of addresses of the block that fall into a range.
+	Covered uint64 `json:"covered"`
+	// Ratio is Covered divided by the size of the block.
+	Ratio float64 `json:"ratio"`
+}
+
+// Coverage returns, for every /bits block of the IPv4 address space in order, the
+// number of addresses covered by the loaded dataset, e.g. 256 buckets for bits 8 or
+// 65536 for bits 16. Plotted as a heatmap, it shows the coverage of a dataset at a
+// glance and makes vendor truncation or regional gaps easy to spot. bits must be
+// between 1 and 16.
+func (db *IPCountryDB) Coverage(bits int) ([]CoverageBucket, error) {
+	if bits < 1 || bits > 16 {
+		return nil, newLookupError(CodeInvalidArgument, fmt.Errorf("invalid coverage prefix length %d", bits))
+	}
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return nil, newLookupError(CodeNotLoaded, fmt.Errorf("coverage failed: %w", ErrNotInitialized))
+	}
+
+	shift := 32 - bits
+	covered := make([]uint64, 1<<bits)
+	for _, r := range snap.all() {
+		for block := r.StartIP >> shift; block <= r.EndIP>>shift; block++ {
+			first := max(r.StartIP, block<<shift)
+			last := min(r.EndIP, block<<shift|^prefixMask(bits))
+			covered[block] += uint64(last-first) + 1
+		}
+	}
+
+	size := float64(uint64(1) << shift)
+	buckets := make([]CoverageBucket, len(covered))
+	for block, n := range covered {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(block)<<shift)
+		buckets[block] = CoverageBucket{
+			Prefix:  netip.PrefixFrom(netip.AddrFrom4(b), bits),
+			Covered: n,
+			Ratio:   float64(n) / size,
+		}
+	}
+	return buckets, nil
+}
+
+// ExportCoverage writes the result of Coverage in the given format, ready to be
+// loaded into a spreadsheet or plotting tool.
+func (db *IPCountryDB) ExportCoverage(w io.Writer, bits int, format CoverageFormat) error {
+	buckets, err := db.Coverage(bits)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case CoverageCSV:
+		bw := bufio.NewWriter(w)
+		bw.WriteString("prefix,covered,ratio\n")
+		for _, b := range buckets {
+			fmt.Fprintf(bw, "%s,%d,%.6f\n", b.Prefix, b.Covered, b.Ratio)
+		}
+		return bw.Flush()
+	case CoverageJSON:
+		return writeJSON(w, buckets)
+	default:
+		return newLookupError(CodeInvalidArgument, fmt.Errorf("unknown coverage format %d", format))
+	}
+}
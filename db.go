@@ -1,12 +1,20 @@
 package ip2country
 
 import (
-	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"sort"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
+	"net/netip"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,20 +22,29 @@ import (
 )
 
 // IPCountryDB implements the IPCountryLookup interface using a sorted list of IP ranges.
-// It is optimized for lookups using binary search and is protected by a mutex for
-// concurrent access.
+// It is optimized for lookups using binary search. The loaded dataset is an immutable
+// snapshot behind an atomic pointer, so lookups never take a lock on it.
 type IPCountryDB struct {
-	ranges      []IPRange
-	mu          sync.RWMutex
-	initialized int32
-	initErr     error
-	config      Config
-	stats       Stats
-	filePath    string
-	cache       *lruCache
+	snapshot   atomic.Pointer[rangeSnapshot]
+	loadMu     sync.Mutex // Serializes loads; held while a dataset is parsed.
+	life       lifecycle
+	config     Config
+	source     Source
+	cache      resultCache
+	logger     *slog.Logger
+	panics     atomic.Int64
+	groups     atomic.Pointer[groupSet]
+	overrides  atomic.Pointer[overrideSet]
+	suppressed atomic.Pointer[map[string]bool]
+	overrideMu sync.Mutex // Serializes changes to overrides and suppressed.
+	failures   failureLog
+	breaker    *initBreaker
 }
 
-// NewIPCountryDB creates a new instance of IPCountryDB.
+// NewIPCountryDB creates a new instance of IPCountryDB that reads the dataset from the
+// local file at filePath. Files ending in .gz are decompressed on the fly, and .zip,
+// .tar.gz and .tgz archives are searched for the entry selected by Config.ArchivePattern.
+// Index files written by WriteIndex are memory-mapped instead of parsed.
 // The database is not loaded until the first lookup or an explicit call to Reload.
 // It accepts an optional Config; if not provided, DefaultConfig() is used.
 func NewIPCountryDB(filePath string, config ...Config) *IPCountryDB {
@@ -35,6 +52,17 @@ func NewIPCountryDB(filePath string, config ...Config) *IPCountryDB {
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	return NewIPCountryDBFromSource(&FileSource{Path: filePath, ArchivePattern: cfg.ArchivePattern}, cfg)
+}
+
+// NewIPCountryDBFromSource creates an IPCountryDB that loads its dataset from src, e.g.
+// an HTTPSource. Reloads that find the source unchanged keep the loaded data.
+// It accepts an optional Config; if not provided, DefaultConfig() is used.
+func NewIPCountryDBFromSource(src Source, config ...Config) *IPCountryDB {
+	cfg := DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
 
 	if cfg.Delimiter == "" {
 		cfg.Delimiter = ","
@@ -43,137 +71,413 @@ func NewIPCountryDB(filePath string, config ...Config) *IPCountryDB {
 		cfg.CacheSize = 1000
 	}
 
-	return &IPCountryDB{
-		filePath: filePath,
-		config:   cfg,
-		cache:    newLRUCache(cfg.CacheSize),
+	db := &IPCountryDB{
+		source:  src,
+		config:  cfg,
+		cache:   newResultCache(cfg),
+		logger:  newLogger(cfg),
+		life:    newLifecycle(),
+		breaker: newInitBreaker(&cfg),
 	}
+	db.groups.Store(newGroupSet(cfg.Groups))
+	return db
+}
+
+// NewIPCountryDBFromReader creates an IPCountryDB that loads its dataset from r, e.g. a
+// network stream, without touching the local filesystem. The reader is consumed by the
+// first load; later reloads keep the data read from it. MaxFileSize applies to the
+// number of bytes read.
+func NewIPCountryDBFromReader(r io.Reader, config Config) *IPCountryDB {
+	return NewIPCountryDBFromSource(&readerSource{r: r}, config)
 }
 
-// initializeWithContext handles the one-time loading and processing of the IP range data.
-func (db *IPCountryDB) initializeWithContext(ctx context.Context) error {
-	if atomic.LoadInt32(&db.initialized) == 1 {
-		return db.initErr
+// NewIPCountryDBFromFS creates an IPCountryDB that loads its dataset from the file at
+// path in fsys, e.g. an embed.FS populated with go:embed. Archives are not supported in
+// an fs.FS; with FormatGeoLite2, the locations file is read from fsys as well.
+// It accepts an optional Config; if not provided, DefaultConfig() is used.
+func NewIPCountryDBFromFS(fsys fs.FS, path string, config ...Config) *IPCountryDB {
+	return NewIPCountryDBFromSource(&FSSource{FS: fsys, Path: path}, config...)
+}
+
+// rangeSnapshot is an immutable, sorted and validated range dataset. A new snapshot is
+// built for every load and swapped in whole, so readers holding the previous one are
+// never affected.
+type rangeSnapshot struct {
+	columns     *rangeColumns
+	mapped      *mappedRanges // Replaces columns with Config.SnapshotDir and index files.
+	store       RangeStore    // Replaces columns and mapped with Config.NewRangeStore.
+	index       rangeIndex
+	stats       Stats
+	attribution Attribution
+	source      Metadata
+	parseErrors parseErrorCollector
+	generation  uint64
+}
+
+// initializeWithContext handles the one-time loading and processing of the IP range data
+// and returns the live snapshot.
+func (db *IPCountryDB) initializeWithContext(ctx context.Context) (*rangeSnapshot, error) {
+	if snap := db.snapshot.Load(); snap != nil {
+		return snap, nil
+	}
+	if err := db.breaker.allow(); err != nil {
+		return nil, err
 	}
 
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	db.loadMu.Lock()
+	defer db.loadMu.Unlock()
 
-	if atomic.LoadInt32(&db.initialized) == 1 {
-		return db.initErr
+	if snap := db.snapshot.Load(); snap != nil {
+		return snap, nil
+	}
+	if db.life.closed() {
+		return nil, ErrClosed
+	}
+	// Lookups that waited for a failed attempt must not repeat it.
+	if err := db.breaker.allow(); err != nil {
+		return nil, err
 	}
+	snap, err := db.load(ctx, db.source)
+	if err != nil {
+		db.breaker.record(err)
+		return nil, err
+	}
+	db.install(snap)
+	return snap, nil
+}
 
+// load parses, sorts and validates the dataset provided by src. It does not touch the
+// installed ranges, so lookups keep being served while a new dataset is read. The
+// caller must hold loadMu.
+func (db *IPCountryDB) load(ctx context.Context, src Source) (*rangeSnapshot, error) {
 	start := time.Now()
-	result, err := db.parseFileWithContext(ctx, db.filePath)
+	var file *snapshotWriter
+	var columns *rangeColumns
+	var store *rangeStoreWriter
+	if newStore := db.config.NewRangeStore; newStore != nil {
+		// Sorted columns are the built-in storage, so they keep its search indexes.
+		if c, ok := newStore().(*rangeColumns); ok {
+			columns = c
+		} else {
+			store = newRangeStoreWriter(newStore())
+		}
+	}
+	mapped, result, meta, err := openIndexFile(src, db.config.MaxFileSize)
+	if err == nil && (columns != nil || store != nil) {
+		err = fillFromMapped(ctx, mapped, columns, store)
+		mapped = nil
+	}
+	if errors.Is(err, errNotIndexFile) {
+		var add func(IPRange) error
+		if store != nil {
+			add = store.add
+		} else if columns != nil {
+			add = columns.add
+		} else if dir := db.config.SnapshotDir; dir != "" {
+			if file, err = newSnapshotWriter(dir); err != nil {
+				db.logger.Error("dataset load failed", "source", sourceName(src), "error", err)
+				return nil, newLoadError(err)
+			}
+			defer file.discard()
+			add = file.add
+		} else {
+			// Size the columns for a dataset like the live one, so reloads do not regrow them.
+			capacity := 0
+			if snap := db.snapshot.Load(); snap != nil {
+				capacity = snap.len()
+			}
+			columns = newRangeColumns(capacity)
+			add = columns.add
+		}
+		result, meta, err = db.parseSourceWithContext(ctx, src, add)
+	}
+	if errors.Is(err, ErrNotModified) {
+		return nil, err
+	}
 	if err != nil {
-		db.initErr = err
-		return db.initErr
+		db.logger.Error("dataset load failed", "source", sourceName(src), "error", err)
+		return nil, newLoadError(err)
 	}
 
-	sort.Slice(result.Ranges, func(i, j int) bool {
-		return result.Ranges[i].StartIP < result.Ranges[j].StartIP
-	})
+	data := &rangeSnapshot{
+		columns:     columns,
+		mapped:      mapped,
+		stats:       result.Stats,
+		attribution: detectAttribution(src, meta, db.config),
+		source:      meta,
+		parseErrors: parseErrorCollector{errors: result.Errors, summary: result.ErrorSummary},
+	}
+	if file != nil {
+		if data.mapped, err = file.finish(); err != nil {
+			db.logger.Error("dataset load failed", "source", sourceName(src), "error", err)
+			return nil, newLoadError(err)
+		}
+	} else if store != nil {
+		if err := store.finish(); err != nil {
+			db.logger.Error("dataset load failed", "source", sourceName(src), "error", err)
+			return nil, newLoadError(err)
+		}
+		data.store = store.store
+	} else if columns != nil {
+		columns.finish()
+	}
 
-	if err := db.validateRanges(result.Ranges); err != nil {
-		db.initErr = fmt.Errorf("range validation failed: %w", err)
-		return db.initErr
+	if err := data.validate(); err != nil {
+		err = newLoadError(fmt.Errorf("range validation failed: %w", err))
+		db.logger.Error("dataset load failed", "source", sourceName(src), "error", err)
+		return nil, err
 	}
 
-	db.ranges = result.Ranges
-	db.stats = result.Stats
-	db.stats.LoadTime = time.Since(start)
-	db.stats.LastUpdate = time.Now()
+	data.stats.LoadTime = time.Since(start)
+	data.stats.LastUpdate = time.Now()
+	data.stats.Provider = data.attribution.Provider
+	if data.store != nil {
+		data.stats.SearchStrategy = "store"
+	} else {
+		data.index = buildIndex(data, db.config.IndexStrategy)
+		data.stats.SearchStrategy = data.index.strategy.String()
+	}
+	db.logger.Info("dataset loaded", "source", meta.Name, "ranges", data.len(),
+		"parse_errors", result.ErrorSummary.Total, "duration", data.stats.LoadTime)
+	if n := db.config.SelfBenchmarkLookups; n > 0 {
+		data.stats.SelfBenchmark = runSelfBenchmark(n, func(ipNum uint32) bool {
+			_, ok := data.search(ipNum)
+			return ok
+		})
+		db.logger.Info("self-benchmark finished", "lookups", n, "qps", int(data.stats.SelfBenchmark.QPS),
+			"p50", data.stats.SelfBenchmark.P50, "p99", data.stats.SelfBenchmark.P99)
+	}
+	if result.ErrorSummary.Total > 0 {
+		db.logger.Warn("dataset lines rejected", "source", meta.Name, "summary", result.ErrorSummary.String())
+	}
+	return data, nil
+}
 
-	atomic.StoreInt32(&db.initialized, 1)
-	return nil
+// install publishes snap as the live dataset and advances the cache generation, so
+// answers computed from the previous snapshot are no longer cached. The caller must
+// hold loadMu.
+func (db *IPCountryDB) install(snap *rangeSnapshot) {
+	if prev := db.snapshot.Load(); prev != nil {
+		snap.generation = prev.generation + 1
+	}
+	db.snapshot.Store(snap)
+	db.cache.setGeneration(snap.generation)
+	db.breaker.record(nil)
+	if c, ok := db.source.(loadCommitter); ok {
+		c.commitLoad()
+	}
 }
 
-// validateRanges checks for overlapping IP ranges in a sorted slice.
-func (db *IPCountryDB) validateRanges(ranges []IPRange) error {
-	for i := 0; i < len(ranges)-1; i++ {
-		if ranges[i].EndIP >= ranges[i+1].StartIP {
+// validate checks the sorted ranges of s for overlaps.
+func (s *rangeSnapshot) validate() error {
+	var prev IPRange
+	for i, r := range s.all() {
+		if i > 0 && prev.EndIP >= r.StartIP {
 			return fmt.Errorf("overlapping ranges detected: [%d-%d] and [%d-%d]",
-				ranges[i].StartIP, ranges[i].EndIP, ranges[i+1].StartIP, ranges[i+1].EndIP)
+				prev.StartIP, prev.EndIP, r.StartIP, r.EndIP)
 		}
+		prev = r
 	}
 	return nil
 }
 
-// parseFileWithContext opens and parses the data file.
-func (db *IPCountryDB) parseFileWithContext(ctx context.Context, filePath string) (*ParseResult, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+// all returns an iterator over the ranges of s in ascending order, with their
+// positions.
+func (s *rangeSnapshot) all() iter.Seq2[int, IPRange] {
+	return func(yield func(int, IPRange) bool) {
+		if s.store != nil {
+			i := 0
+			s.store.Iterate(func(r IPRange) bool {
+				i++
+				return yield(i-1, r)
+			})
+			return
+		}
+		for i := range s.len() {
+			if !yield(i, s.at(i)) {
+				return
+			}
+		}
 	}
-	defer file.Close()
+}
+
+// len returns the number of ranges in s.
+func (s *rangeSnapshot) len() int {
+	if s.store != nil {
+		return s.store.Len()
+	}
+	if s.mapped != nil {
+		return s.mapped.len()
+	}
+	return s.columns.len()
+}
 
-	stat, err := file.Stat()
+// at returns the i-th range of s in ascending order. A RangeStore has no indexed
+// access, so s must not hold one; use all instead.
+func (s *rangeSnapshot) at(i int) IPRange {
+	if s.mapped != nil {
+		return s.mapped.at(i)
+	}
+	return s.columns.at(i)
+}
+
+// startAt returns the first address of the i-th range of s, which must not hold a
+// RangeStore.
+func (s *rangeSnapshot) startAt(i int) uint32 {
+	if s.mapped != nil {
+		return s.mapped.startAt(i)
+	}
+	return s.columns.startAt(i)
+}
+
+// parseSourceWithContext opens and parses the dataset provided by src. If add is not
+// nil, the ranges are passed to it instead of being collected in the result.
+func (db *IPCountryDB) parseSourceWithContext(ctx context.Context, src Source, add func(IPRange) error) (*ParseResult, Metadata, error) {
+	file, meta, err := src.Open(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file stats: %w", err)
+		return nil, meta, err
 	}
-	fileSize := stat.Size()
+	defer file.Close()
+
+	fileSize := meta.Size
 	if db.config.MaxFileSize > 0 && fileSize > db.config.MaxFileSize {
-		return nil, fmt.Errorf("file size %d exceeds limit %d", fileSize, db.config.MaxFileSize)
+		return nil, meta, fmt.Errorf("%w: %d > %d", ErrFileTooLarge, fileSize, db.config.MaxFileSize)
+	}
+
+	hash := sha256.New()
+	counter := &limitedReader{r: io.TeeReader(file, hash), limit: db.config.MaxFileSize}
+	reader, layout := detectLayout(counter, &db.config)
+
+	parse := func(line string) (*IPRange, error) {
+		return db.parseLine(line, layout.delimiter)
+	}
+	if db.config.Format == FormatGeoLite2 {
+		locations, err := geoLite2Locations(src, db.config)
+		if err != nil {
+			return nil, meta, err
+		}
+		parse = func(line string) (*IPRange, error) {
+			return parseGeoLite2Line(line, layout.delimiter, locations)
+		}
 	}
 
-	result, err := db.parseReaderWithContext(ctx, file)
+	result, err := db.parseReaderWithContext(ctx, reader, layout.skipHeader, parse, add)
 	if err != nil {
-		return nil, err
+		return nil, meta, err
 	}
 
+	if fileSize < 0 {
+		fileSize = counter.n
+	}
 	result.Stats.FileSize = fileSize
-	return result, nil
+	result.Stats.Source = meta.Name
+	result.Stats.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return result, meta, nil
 }
 
-// parseReaderWithContext reads from an io.Reader and parses the data line by line.
-func (db *IPCountryDB) parseReaderWithContext(ctx context.Context, reader io.Reader) (*ParseResult, error) {
-	scanner := bufio.NewScanner(reader)
+// parseReaderWithContext reads from an io.Reader and parses the data line by line
+// with the given line parser, skipping the first line if skipHeader is set. Parsed
+// ranges are passed to add or, if it is nil, collected in the result.
+func (db *IPCountryDB) parseReaderWithContext(ctx context.Context, reader io.Reader, skipHeader bool, parse func(string) (*IPRange, error), add func(IPRange) error) (*ParseResult, error) {
 	var ranges []IPRange
-	var errors []ParseError
+	if add == nil {
+		// Size the slice for a dataset like the live one, so reloads do not regrow it.
+		if snap := db.snapshot.Load(); snap != nil {
+			ranges = make([]IPRange, 0, snap.len())
+		}
+	}
+	count := 0
+	parseErrors := newParseErrorCollector(&db.config)
+	progress := progressReporter{fn: db.config.ProgressFunc}
+
+	handle := func(lineNum int, line string, ipRange *IPRange, err error) (bool, error) {
+		if errors.Is(err, errSkipLine) {
+			return false, nil
+		}
+		if err != nil {
+			return false, parseErrors.add(lineNum, line, err)
+		}
+
+		if add != nil {
+			if err := add(*ipRange); err != nil {
+				return false, err
+			}
+		} else {
+			ranges = append(ranges, *ipRange)
+		}
+		count++
+		return db.config.MaxRanges > 0 && count >= db.config.MaxRanges, nil
+	}
+
+	var err error
+	if db.config.ParseWorkers > 1 {
+		err = db.parseLinesParallel(ctx, reader, skipHeader, &progress, parse, handle)
+	} else {
+		err = db.parseLines(ctx, reader, skipHeader, &progress, parse, handle)
+	}
+	if err != nil {
+		return nil, err
+	}
+	progress.done()
+
+	return &ParseResult{
+		Ranges:       ranges,
+		Errors:       parseErrors.errors,
+		ErrorSummary: parseErrors.summary,
+		Stats:        Stats{TotalRanges: count, ParseErrors: parseErrors.summary.Total},
+	}, nil
+}
+
+// lineHandler receives the outcome of parsing a line: the range, or the parse error.
+// It reports whether parsing should stop early, or an error that aborts it.
+type lineHandler func(lineNum int, line string, ipRange *IPRange, err error) (done bool, _ error)
+
+// parseLines reads lines from reader, skipping blank and comment lines and the first
+// line if skipHeader is set, and passes the outcome of parsing each one to handle.
+func (db *IPCountryDB) parseLines(ctx context.Context, reader io.Reader, skipHeader bool, progress *progressReporter, parse func(string) (*IPRange, error), handle lineHandler) error {
+	scanner, release := newLineScanner(reader)
+	defer release()
 	lineNum := 0
 
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return ctx.Err()
 		default:
 		}
 
 		lineNum++
+		progress.line(len(scanner.Bytes()))
 		line := strings.TrimSpace(scanner.Text())
-		if line == "" || (db.config.SkipHeader && lineNum == 1) {
-			continue
-		}
-
-		ipRange, err := db.parseLine(line)
-		if err != nil {
-			errors = append(errors, ParseError{Line: lineNum, Content: line, Err: err})
+		if line == "" || (skipHeader && lineNum == 1) || isComment(line, db.config.CommentPrefixes) {
 			continue
 		}
 
-		ranges = append(ranges, *ipRange)
-		if db.config.MaxRanges > 0 && len(ranges) >= db.config.MaxRanges {
-			break
+		ipRange, err := parse(line)
+		done, err := handle(lineNum, line, ipRange, err)
+		if err != nil || done {
+			return err
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scanner error: %w", err)
+		return fmt.Errorf("scanner error: %w", err)
 	}
-
-	return &ParseResult{
-		Ranges: ranges,
-		Errors: errors,
-		Stats:  Stats{TotalRanges: len(ranges)},
-	}, nil
+	return nil
 }
 
 // parseLine parses a single line of text into an IPRange.
-// Expected format: start_ip,end_ip,country_code
-func (db *IPCountryDB) parseLine(line string) (*IPRange, error) {
-	parts := strings.Split(line, db.config.Delimiter)
+// Expected format: start_ip,end_ip,country_code, or network,country_code for CIDR blocks.
+func (db *IPCountryDB) parseLine(line, delimiter string) (*IPRange, error) {
+	parts, err := splitFields(line, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	if db.config.Format == FormatCIDR || (len(parts) == 2 && strings.Contains(parts[0], "/")) {
+		return parseCIDRLine(parts, db.config.MultiCodePolicy)
+	}
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("incorrect number of fields: expected 3, got %d", len(parts))
+		return nil, fmt.Errorf("%w: expected 3, got %d", errFieldCount, len(parts))
 	}
 
 	startIP, err := parseIP(strings.TrimSpace(parts[0]))
@@ -184,13 +488,17 @@ func (db *IPCountryDB) parseLine(line string) (*IPRange, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid end IP %q: %w", parts[1], err)
 	}
-	countryCode := strings.TrimSpace(parts[2])
+	countryCode, alternates, err := splitCodes(parts[2], db.config.MultiCodePolicy)
+	if err != nil {
+		return nil, err
+	}
 
 	ipRange := &IPRange{
-		StartIP: startIP,
-		EndIP:   endIP,
-		Country: countryCode, // Per new requirement, Country is the same as Code.
-		Code:    countryCode,
+		StartIP:        startIP,
+		EndIP:          endIP,
+		Country:        countryCode, // Per new requirement, Country is the same as Code.
+		Code:           countryCode,
+		AlternateCodes: alternates,
 	}
 
 	if err := ipRange.Validate(); err != nil {
@@ -199,55 +507,242 @@ func (db *IPCountryDB) parseLine(line string) (*IPRange, error) {
 	return ipRange, nil
 }
 
-// findCountryForIP performs a binary search to find the country for a given IP number.
-func (db *IPCountryDB) findCountryForIP(ipNum uint32) (string, string, error) {
-	if entry, found := db.cache.get(ipNum); found {
+// findCountryForIP performs a binary search in snap to find the country for a given IP
+// number and writes the match into res. cached reports whether the answer, a match or a
+// miss, was served from the cache.
+func (db *IPCountryDB) findCountryForIP(snap *rangeSnapshot, ipNum uint32, res *Result, bypassCache bool) (cached bool, err error) {
+	key, prefixed := db.cacheKey(ipNum)
+	if bypassCache {
+		if rangeItem, ok := snap.search(ipNum); ok {
+			res.Country, res.Code, res.AlternateCodes = rangeItem.Country, rangeItem.Code, rangeItem.AlternateCodes
+			res.StartIP, res.EndIP = rangeItem.StartIP, rangeItem.EndIP
+			return false, nil
+		}
+		return false, newLookupError(CodeNotFound, ErrNotFound)
+	}
+	if entry, found := db.cache.get(key, ipNum); found {
 		if !entry.found {
-			return "", "", fmt.Errorf("country not found for IP (cached miss)")
+			return true, newLookupError(CodeNotFound, fmt.Errorf("%w (cached miss)", ErrNotFound))
 		}
-		return entry.country, entry.code, nil
+		entry.fill(res)
+		return true, nil
 	}
 
-	idx := sort.Search(len(db.ranges), func(i int) bool {
-		return db.ranges[i].StartIP > ipNum
-	})
+	if rangeItem, ok := snap.search(ipNum); ok {
+		entry := cacheEntry{
+			ip: key, country: rangeItem.Country, code: rangeItem.Code,
+			alternates: rangeItem.AlternateCodes, startIP: rangeItem.StartIP, endIP: rangeItem.EndIP, found: true,
+		}
+		db.cache.put(key, entry, snap.generation)
+		entry.fill(res)
+		return false, nil
+	}
+
+	miss := cacheEntry{ip: key, startIP: ipNum, endIP: ipNum, found: false}
+	if prefixed {
+		miss.startIP, miss.endIP = snap.gap(ipNum)
+	}
+	db.cache.put(key, miss, snap.generation)
+	return false, newLookupError(CodeNotFound, ErrNotFound)
+}
+
+// cacheKey returns the cache key for ipNum: the address itself or, with
+// Config.CachePrefixLen, the first address of its prefix. prefixed reports whether the
+// key is shared by several addresses.
+func (db *IPCountryDB) cacheKey(ipNum uint32) (key uint32, prefixed bool) {
+	bits := db.config.CachePrefixLen
+	if bits <= 0 || bits >= 32 {
+		return ipNum, false
+	}
+	return ipNum & prefixMask(bits), true
+}
+
+// cacheBlock returns the first and last address whose lookups may be answered by the
+// cache entry stored under key.
+func (db *IPCountryDB) cacheBlock(key uint32) (first, last uint32) {
+	if _, prefixed := db.cacheKey(key); !prefixed {
+		return key, key
+	}
+	return key, key | ^prefixMask(db.config.CachePrefixLen)
+}
 
-	var entry cacheEntry
+// gap returns the bounds of the unmatched address span around ipNum, which must not be
+// covered by any range. A RangeStore cannot tell the span, so with one it is ipNum
+// alone.
+func (s *rangeSnapshot) gap(ipNum uint32) (first, last uint32) {
+	if s.store != nil {
+		return ipNum, ipNum
+	}
+	n := s.len()
+	idx := s.upperBound(ipNum)
+	first, last = 0, math.MaxUint32
 	if idx > 0 {
-		rangeItem := db.ranges[idx-1]
-		if rangeItem.Contains(ipNum) {
-			entry = cacheEntry{ip: ipNum, country: rangeItem.Country, code: rangeItem.Code, found: true}
-			db.cache.put(ipNum, entry)
-			return rangeItem.Country, rangeItem.Code, nil
+		first = s.at(idx-1).EndIP + 1
+	}
+	if idx < n {
+		last = s.startAt(idx) - 1
+	}
+	return first, last
+}
+
+// search finds the range containing ipNum without consulting the cache.
+func (s *rangeSnapshot) search(ipNum uint32) (IPRange, bool) {
+	if s.store != nil {
+		return s.store.Find(ipNum)
+	}
+	if idx := s.upperBound(ipNum); idx > 0 {
+		if r := s.at(idx - 1); r.Contains(ipNum) {
+			return r, true
 		}
 	}
+	return IPRange{}, false
+}
 
-	entry = cacheEntry{ip: ipNum, found: false}
-	db.cache.put(ipNum, entry)
-	return "", "", fmt.Errorf("country not found for IP")
+// LookupInto resolves ipStr and writes the matched country and range into res.
+func (db *IPCountryDB) LookupInto(ipStr string, res *Result) error {
+	return db.LookupIntoWithContext(context.Background(), ipStr, res)
 }
 
-// GetCountry retrieves the country code for a given IP address string.
-func (db *IPCountryDB) GetCountry(ipStr string) (string, error) {
-	return db.GetCountryWithContext(context.Background(), ipStr)
+// LookupIntoWithContext resolves ipStr, respecting the context, and writes the matched
+// country and range into res. On error, res is reset to its zero value.
+func (db *IPCountryDB) LookupIntoWithContext(ctx context.Context, ipStr string, res *Result) error {
+	return db.lookupInto(ctx, res, func() (uint32, error) { return parseLookupIP(ipStr, &db.config) })
 }
 
-// GetCountryWithContext retrieves the country code, respecting the context.
-func (db *IPCountryDB) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
-	if err := db.initializeWithContext(ctx); err != nil {
-		return "", fmt.Errorf("initialization failed: %w", err)
+// GetCountryCodeForAddr retrieves the country code for an already parsed address,
+// avoiding the string round-trip. IPv4-mapped IPv6 addresses are unmapped.
+func (db *IPCountryDB) GetCountryCodeForAddr(addr netip.Addr) (string, error) {
+	var res Result
+	err := db.lookupInto(context.Background(), &res, func() (uint32, error) { return addrToUint32(addr) })
+	return res.Code, err
+}
+
+// GetCountryCodeForIP retrieves the country code for a net.IP in 4-byte or IPv4-mapped
+// 16-byte form.
+func (db *IPCountryDB) GetCountryCodeForIP(ip net.IP) (string, error) {
+	var res Result
+	err := db.lookupInto(context.Background(), &res, func() (uint32, error) { return netIPToUint32(ip) })
+	return res.Code, err
+}
+
+// GetCountryCodeForUint32 retrieves the country code for an IPv4 address in host byte
+// order, e.g. 134744072 for 8.8.8.8. It is the fastest lookup path.
+func (db *IPCountryDB) GetCountryCodeForUint32(ipNum uint32) (string, error) {
+	var res Result
+	err := db.lookupInto(context.Background(), &res, func() (uint32, error) { return ipNum, nil })
+	return res.Code, err
+}
+
+// lookupInto resolves the address returned by parse, which is called once the dataset
+// is available, and writes the matched country and range into res. On error, res is
+// reset to its zero value.
+func (db *IPCountryDB) lookupInto(ctx context.Context, res *Result, parse func() (uint32, error)) error {
+	_, err := db.lookup(ctx, res, parse, newLookupOptions(&db.config, nil))
+	return err
+}
+
+// lookup is lookupInto that also returns the snapshot that answered, or nil if the
+// dataset is not available.
+func (db *IPCountryDB) lookup(ctx context.Context, res *Result, parse func() (uint32, error), opts lookupOptions) (snap *rangeSnapshot, err error) {
+	var ipNum uint32
+	parsed := false
+	defer func() {
+		if err != nil {
+			db.failures.record(ipNum, parsed, err)
+		}
+	}()
+	defer recoverPanic(db.logger, db.config.Name, "lookup", &db.panics, &err)
+
+	*res = Result{}
+	snap, err = db.initializeWithContext(ctx)
+	if err != nil {
+		return nil, newLookupError(failureCode(err, CodeNotLoaded), namedError(db.config.Name, fmt.Errorf("%w: %w", ErrNotInitialized, err)))
+	}
+
+	if ipNum, err = parse(); err != nil {
+		return snap, newLookupError(CodeInvalidIP, fmt.Errorf("%w: %w", ErrInvalidIP, err))
 	}
+	parsed = true
+
+	bits := db.config.AnonymizedPrefixLen
+	first, last := anonymizedBlock(ipNum, bits)
+
+	cached := false
+	if entry, ok := db.overrides.Load().match(first); ok {
+		entry.fill(res)
+	} else {
+		cached, err = db.findCountryForIP(snap, first, res, opts.bypassCache)
+		if debugAssertions {
+			db.checkInvariants(snap, first, res, err)
+		}
+	}
+	if err == nil && bits > 0 {
+		err = checkAnonymizedMatch(res, first, last, bits)
+	}
+	// A suppressed answer is a miss over the matched range rather than a gap.
+	spanStart, spanEnd := res.StartIP, res.EndIP
+	suppressed := err == nil && db.isSuppressed(res.Code)
+	if suppressed {
+		err = newLookupError(CodeNotFound, fmt.Errorf("%w: country %s is suppressed", ErrNotFound, res.Code))
+		*res = Result{}
+	}
+	if err == nil && db.config.HitSink != nil {
+		db.config.HitSink.record(ipNum, res.Code, cached)
+	}
+	if err != nil && opts.defaultCode != "" && errors.Is(err, ErrNotFound) {
+		*res = Result{Country: opts.defaultCode, Code: opts.defaultCode}
+		if suppressed {
+			res.StartIP, res.EndIP = spanStart, spanEnd
+		} else {
+			res.StartIP, res.EndIP = snap.gap(first)
+		}
+		err = nil
+	}
+	return snap, err
+}
 
-	ipNum, err := parseIP(ipStr)
+// Lookup resolves ipStr and returns the full answer: country, matched range and the
+// dataset that produced it. opts adjust this lookup only, e.g.
+//
+//	db.Lookup(ctx, ip, WithBypassCache(), WithDefault("ZZ"))
+func (db *IPCountryDB) Lookup(ctx context.Context, ipStr string, opts ...LookupOption) (*Record, error) {
+	var res Result
+	snap, err := db.lookup(ctx, &res, func() (uint32, error) { return parseLookupIP(ipStr, &db.config) }, newLookupOptions(&db.config, opts))
 	if err != nil {
-		return "", fmt.Errorf("invalid IP: %w", err)
+		return nil, err
+	}
+	return newRecord(&res, snap.source, snap.attribution.Provider, snap.stats.LastUpdate), nil
+}
+
+// LookupRange returns the dataset range that contains ipStr, e.g. to build allowlists
+// or to see which entry of the dataset produced an answer.
+func (db *IPCountryDB) LookupRange(ipStr string) (IPRange, error) {
+	return db.LookupRangeWithContext(context.Background(), ipStr)
+}
+
+// LookupRangeWithContext returns the dataset range that contains ipStr, respecting the
+// context.
+func (db *IPCountryDB) LookupRangeWithContext(ctx context.Context, ipStr string) (IPRange, error) {
+	var res Result
+	if err := db.LookupIntoWithContext(ctx, ipStr, &res); err != nil {
+		return IPRange{}, err
 	}
+	return IPRange{
+		Country: res.Country, Code: res.Code, AlternateCodes: res.AlternateCodes,
+		StartIP: res.StartIP, EndIP: res.EndIP,
+	}, nil
+}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+// GetCountry retrieves the country code for a given IP address string.
+func (db *IPCountryDB) GetCountry(ipStr string) (string, error) {
+	return db.GetCountryWithContext(context.Background(), ipStr)
+}
 
-	country, _, err := db.findCountryForIP(ipNum)
-	return country, err
+// GetCountryWithContext retrieves the country code, respecting the context.
+func (db *IPCountryDB) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	var res Result
+	err := db.LookupIntoWithContext(ctx, ipStr, &res)
+	return res.Country, err
 }
 
 // GetCountryCode retrieves the country code for a given IP address string.
@@ -257,52 +752,205 @@ func (db *IPCountryDB) GetCountryCode(ipStr string) (string, error) {
 
 // GetCountryCodeWithContext retrieves the country code, respecting the context.
 func (db *IPCountryDB) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
-	if err := db.initializeWithContext(ctx); err != nil {
-		return "", fmt.Errorf("initialization failed: %w", err)
+	var res Result
+	err := db.LookupIntoWithContext(ctx, ipStr, &res)
+	return res.Code, err
+}
+
+// GetLocales returns the likely locales (e.g., "de-DE") for the country of the given IP,
+// so web applications can pre-select a language. It returns nil if the country has no
+// known locales.
+func (db *IPCountryDB) GetLocales(ipStr string) ([]string, error) {
+	return getLocales(db, ipStr)
+}
+
+// GetContinentCode returns the continent code (e.g., "EU") of the country of the given
+// IP, for continent-level routing. It returns an empty string if the country has no
+// known continent.
+func (db *IPCountryDB) GetContinentCode(ipStr string) (string, error) {
+	return getContinentCode(db, ipStr)
+}
+
+// InGroup reports whether the country of the given IP belongs to the named group from
+// Config.Groups. It returns an error for unknown groups and failed lookups.
+func (db *IPCountryDB) InGroup(ipStr, group string) (bool, error) {
+	return inGroup(db, db.groups.Load(), ipStr, group)
+}
+
+// IsSanctioned reports whether the country of the given IP is in SanctionedGroup.
+func (db *IPCountryDB) IsSanctioned(ipStr string) (bool, error) {
+	return db.InGroup(ipStr, SanctionedGroup)
+}
+
+// IsEU reports whether the country of the given IP is a member state of the European
+// Union, as listed in EUGroup.
+func (db *IPCountryDB) IsEU(ipStr string) (bool, error) {
+	return db.InGroup(ipStr, EUGroup)
+}
+
+// SetGroups atomically replaces the user-defined groups, e.g. after an updated
+// definitions file was loaded with LoadGroups. Built-in groups remain available
+// unless overridden.
+func (db *IPCountryDB) SetGroups(groups map[string][]string) {
+	db.groups.Store(newGroupSet(groups))
+}
+
+// GetParseErrors returns the lines of the live dataset that were rejected during the
+// last load/reload, up to Config.MaxStoredParseErrors, so operators can see which lines
+// of the file were skipped.
+func (db *IPCountryDB) GetParseErrors() []ParseError {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return []ParseError{}
 	}
+	return slices.Clone(snap.parseErrors.errors)
+}
 
-	ipNum, err := parseIP(ipStr)
-	if err != nil {
-		return "", fmt.Errorf("invalid IP: %w", err)
+// GetParseErrorSummary returns the counts per kind of all errors that occurred during
+// the last load/reload, including those not returned by GetParseErrors.
+func (db *IPCountryDB) GetParseErrorSummary() ParseErrorSummary {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return ParseErrorSummary{}
 	}
+	summary := snap.parseErrors.summary
+	summary.ByKind = maps.Clone(summary.ByKind)
+	return summary
+}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+// LastParseResult returns the outcome of parsing the live dataset: the rejected lines,
+// their summary and the load statistics. Ranges is left nil; the ranges are served by
+// the lookup methods, Prefixes and LookupRange. It returns nil if the dataset is not
+// loaded.
+func (db *IPCountryDB) LastParseResult() *ParseResult {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return nil
+	}
+	summary := snap.parseErrors.summary
+	summary.ByKind = maps.Clone(summary.ByKind)
+	return &ParseResult{
+		Errors:       slices.Clone(snap.parseErrors.errors),
+		ErrorSummary: summary,
+		Stats:        snap.stats,
+	}
+}
 
-	_, code, err := db.findCountryForIP(ipNum)
-	return code, err
+// Attribution returns the provider and license metadata of the loaded dataset, e.g.
+// for displaying the credit line required by DB-IP or MaxMind. It is the zero value if
+// the dataset is not loaded or its provider is unknown.
+func (db *IPCountryDB) Attribution() Attribution {
+	if snap := db.snapshot.Load(); snap != nil {
+		return snap.attribution
+	}
+	return Attribution{}
 }
 
 // Stats returns the current operational statistics of the database.
 func (db *IPCountryDB) Stats() Stats {
-	db.mu.RLock()
-	s := db.stats
-	db.mu.RUnlock()
+	var s Stats
+	if snap := db.snapshot.Load(); snap != nil {
+		s = snap.stats
+	}
 
 	hits, misses := db.cache.getStats()
+	s.Name = db.config.Name
 	s.CacheHits = hits
 	s.CacheMisses = misses
+	s.CacheBytes = db.cache.usage()
+	s.RecoveredPanics = db.panics.Load()
+	s.InitCircuit, s.InitFailures = db.breaker.state()
 	return s
 }
 
-// Reload clears the current dataset and loads it again from the source file.
+// StartAutoReload starts a background goroutine that checks the source every
+// Config.RefreshInterval and reloads the dataset when it has changed, so long-running
+// servers pick up new data without restarts. Local files are considered changed when
+// their size or modification time differs. The goroutine stops when ctx is canceled;
+// failed reloads are logged and retried at the next interval.
+func (db *IPCountryDB) StartAutoReload(ctx context.Context) error {
+	if db.life.closed() {
+		return ErrClosed
+	}
+	return startAutoReload(db.life.bind(ctx), db.config.RefreshInterval, db.source, db.logger, coordinatedReload(db.config, db.ReloadWithContext))
+}
+
+// Watch polls the source every Config.RefreshInterval (or every second if unset) and
+// reloads the dataset when the file is replaced, reporting the outcome of each reload
+// on the returned channel so callers can log success or failure. The channel is closed
+// when ctx is canceled.
+func (db *IPCountryDB) Watch(ctx context.Context) <-chan ReloadEvent {
+	return watch(db.life.bind(ctx), db.config.RefreshInterval, db.source, coordinatedReload(db.config, db.ReloadWithContext))
+}
+
+// Close stops the background reloads started by StartAutoReload and Watch and releases
+// the dataset, waiting for a reload in progress to finish. Lookups and reloads after
+// Close return ErrClosed. It is safe to call more than once.
+func (db *IPCountryDB) Close() error {
+	db.life.close()
+
+	db.loadMu.Lock()
+	defer db.loadMu.Unlock()
+
+	db.snapshot.Store(nil)
+	db.cache.clear()
+	return nil
+}
+
+// Reload loads the dataset again from the source.
 func (db *IPCountryDB) Reload() error {
 	return db.ReloadWithContext(context.Background())
 }
 
 // ReloadWithContext reloads the dataset, respecting the context for cancellation.
-func (db *IPCountryDB) ReloadWithContext(ctx context.Context) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// The new dataset is parsed while lookups are still served from the current one, which
+// is swapped out only once loading succeeded; if the reload fails, the current dataset
+// stays in service. Cached lookups are invalidated according to Config.CacheInvalidation.
+func (db *IPCountryDB) ReloadWithContext(ctx context.Context) (err error) {
+	defer recoverPanic(db.logger, db.config.Name, "reload", &db.panics, &err)
 
-	atomic.StoreInt32(&db.initialized, 0)
-	db.ranges = nil
-	db.initErr = nil
-	db.cache.clear()
+	db.loadMu.Lock()
+	defer db.loadMu.Unlock()
 
-	err := db.initializeWithContext(ctx)
+	if db.life.closed() {
+		return newLookupError(CodeClosed, namedError(db.config.Name, fmt.Errorf("reload failed: %w", ErrClosed)))
+	}
+	old := db.snapshot.Load()
+	snap, err := db.load(ctx, db.source)
+	if errors.Is(err, ErrNotModified) && old != nil {
+		db.logger.Debug("dataset not modified")
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("reload failed: %w", err)
+		code := CodeNotLoaded
+		if old != nil {
+			code = CodeStaleData
+		}
+		return newLookupError(failureCode(err, code), namedError(db.config.Name, fmt.Errorf("reload failed: %w", err)))
+	}
+
+	var changed []ipInterval
+	if db.config.CacheInvalidation == CacheInvalidateChanged && old != nil {
+		if old.mapped != nil || snap.mapped != nil || old.store != nil || snap.store != nil {
+			// Diffing would page in both mapped datasets, and stores have no indexed
+			// access; treat everything as changed.
+			changed = []ipInterval{{start: 0, end: math.MaxUint32}}
+		} else {
+			changed = diffRanges(old, snap)
+		}
+	}
+
+	db.install(snap)
+	switch db.config.CacheInvalidation {
+	case CacheInvalidateNegative:
+		db.cache.removeIf(func(_ uint32, e cacheEntry) bool { return !e.found })
+	case CacheInvalidateChanged:
+		db.cache.removeIf(func(key uint32, _ cacheEntry) bool {
+			first, last := db.cacheBlock(key)
+			return intervalsOverlap(changed, first, last)
+		})
+	default:
+		db.cache.clear()
 	}
 	return nil
 }
@@ -0,0 +1,187 @@
+// Package dbip downloads the free DB-IP "lite" datasets for use with ip2country.
+//
+// The lite databases are published monthly by DB-IP under the Creative Commons
+// Attribution 4.0 International License, which requires crediting DB-IP wherever the
+// data is used (see Attribution). FetchLatest refuses to download anything unless the
+// caller acknowledges the license by setting Config.AcceptLicense.
+package dbip
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// Attribution is the credit line required by the DB-IP lite license.
+const Attribution = `IP Geolocation by DB-IP (https://db-ip.com)`
+
+// LicenseURL points to the license the lite datasets are distributed under.
+const LicenseURL = "https://creativecommons.org/licenses/by/4.0/"
+
+// DefaultBaseURL is the location the monthly lite files are published under.
+const DefaultBaseURL = "https://download.db-ip.com/free"
+
+// Edition names a DB-IP lite dataset.
+type Edition string
+
+const (
+	// CountryLite is the IP-to-Country dataset in start_ip,end_ip,country_code format,
+	// loadable with ip2country.NewIPCountryDB.
+	CountryLite Edition = "country-lite"
+)
+
+// ErrLicenseNotAccepted is returned when FetchLatest is called without
+// Config.AcceptLicense set.
+var ErrLicenseNotAccepted = errors.New("dbip: the DB-IP lite license (CC BY 4.0) must be accepted via Config.AcceptLicense")
+
+// Config holds settings for FetchLatest.
+// Fields are ordered for optimal memory alignment.
+type Config struct {
+	// Client is the HTTP client used for downloads. If nil, a client with a 5m timeout is used.
+	Client *http.Client
+	// Now returns the current time and determines which month is requested. Defaults to time.Now.
+	Now func() time.Time
+	// BaseURL overrides DefaultBaseURL, e.g. for an internal mirror.
+	BaseURL string
+	// AcceptLicense acknowledges the CC BY 4.0 license of the lite datasets, including the
+	// obligation to show Attribution. Downloads are refused unless it is set.
+	AcceptLicense bool
+}
+
+// FetchLatest downloads the current month's lite CSV for the given edition into dir,
+// decompresses and verifies it, and returns the path of the resulting CSV file. If the
+// current month has not been published yet, the previous month is used. A file that is
+// already present in dir is reused without downloading it again.
+func FetchLatest(ctx context.Context, dir string, edition Edition, config ...Config) (string, error) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if !cfg.AcceptLicense {
+		return "", ErrLicenseNotAccepted
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+
+	now := cfg.Now().UTC()
+	months := []time.Time{now, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)}
+
+	var lastErr error
+	for _, month := range months {
+		name := fmt.Sprintf("dbip-%s-%s.csv", edition, month.Format("2006-01"))
+		dest := filepath.Join(dir, name)
+		if _, err := os.Stat(dest); err == nil {
+			return dest, nil
+		}
+
+		err := download(ctx, cfg.Client, cfg.BaseURL+"/"+name+".gz", dest)
+		if err == nil {
+			if err := verify(dest, edition); err != nil {
+				os.Remove(dest)
+				return "", err
+			}
+			return dest, nil
+		}
+		if !errors.Is(err, errNotPublished) {
+			return "", err
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// errNotPublished is returned by download when the requested month does not exist yet.
+var errNotPublished = errors.New("dbip: dataset not published")
+
+// download fetches a gzip-compressed file and writes its decompressed content to dest.
+// The file is written to a temporary name first, so dest never holds a partial download.
+func download(ctx context.Context, client *http.Client, url, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("dbip: download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", errNotPublished, url)
+	default:
+		return fmt.Errorf("dbip: download of %s returned status %d", url, resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("dbip: invalid gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("dbip: failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Reading to EOF makes the gzip reader verify the stream checksum.
+	if _, err := io.Copy(tmp, gz); err != nil {
+		tmp.Close()
+		return fmt.Errorf("dbip: download of %s is corrupt: %w", url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("dbip: failed to write file: %w", err)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// verify checks that a downloaded file is usable by ip2country.
+func verify(path string, edition Edition) error {
+	if edition != CountryLite {
+		return verifyNonEmpty(path)
+	}
+
+	cfg := ip2country.DefaultConfig()
+	cfg.MaxFileSize = 0
+	cfg.MaxRanges = 1
+	result, err := ip2country.ParseCSVRanges(path, cfg)
+	if err != nil {
+		return fmt.Errorf("dbip: verification failed: %w", err)
+	}
+	if len(result.Ranges) == 0 {
+		return fmt.Errorf("dbip: verification failed: no IPv4 ranges in %s", path)
+	}
+	return nil
+}
+
+// verifyNonEmpty checks that the file contains at least one line.
+func verifyNonEmpty(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("dbip: verification failed: %w", err)
+	}
+	defer file.Close()
+
+	if !bufio.NewScanner(file).Scan() {
+		return fmt.Errorf("dbip: verification failed: %s is empty", path)
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+//go:build !ip2country_debug
+
+package ip2country
+
+// debugAssertions is disabled in regular builds, so the checks compile away.
+const debugAssertions = false
@@ -0,0 +1,7 @@
+//go:build ip2country_debug
+
+package ip2country
+
+// debugAssertions enables invariant checks on every lookup. It is set by building with
+// -tags ip2country_debug and is intended for development only.
+const debugAssertions = true
@@ -0,0 +1,212 @@
+package ip2country
+
+import (
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// failureSampleSize is the number of recent failed lookups kept for DebugBundle.
+const failureSampleSize = 32
+
+// failedLookup is a failed lookup recorded for DebugBundle. The address is truncated to
+// its /24 block, so bundles can be attached to public bug reports.
+type failedLookup struct {
+	Time    time.Time `json:"time"`
+	Address string    `json:"address,omitempty"` // Empty if the input was not a valid address.
+	Code    ErrorCode `json:"code"`
+}
+
+// failureLog counts failed lookups by error code and keeps the most recent ones.
+type failureLog struct {
+	mu      sync.Mutex
+	counts  map[ErrorCode]int64
+	samples [failureSampleSize]failedLookup
+	next    int
+	full    bool
+}
+
+// record adds a failed lookup of ipNum; valid reports whether the input was parsed.
+func (l *failureLog) record(ipNum uint32, valid bool, err error) {
+	sample := failedLookup{Time: time.Now(), Code: ErrorCodeOf(err)}
+	if valid {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], ipNum&prefixMask(24))
+		sample.Address = netip.AddrFrom4(b).String()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = make(map[ErrorCode]int64)
+	}
+	l.counts[sample.Code]++
+	l.samples[l.next] = sample
+	l.next = (l.next + 1) % failureSampleSize
+	l.full = l.full || l.next == 0
+}
+
+// snapshot returns a copy of the counts and the recorded samples, oldest first.
+func (l *failureLog) snapshot() (map[ErrorCode]int64, []failedLookup) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[ErrorCode]int64, len(l.counts))
+	for code, n := range l.counts {
+		counts[code] = n
+	}
+	samples := append([]failedLookup(nil), l.samples[:l.next]...)
+	if l.full {
+		samples = append(append([]failedLookup(nil), l.samples[l.next:]...), samples...)
+	}
+	return counts, samples
+}
+
+// debugConfig is the part of a Config included in a debug bundle: no file paths, hooks
+// or loggers, only whether they are set.
+type debugConfig struct {
+	Name                   string            `json:"name,omitempty"`
+	DefaultCountryCode     string            `json:"default_country_code,omitempty"`
+	Delimiter              string            `json:"delimiter"`
+	ArchivePattern         string            `json:"archive_pattern,omitempty"`
+	Groups                 []string          `json:"groups,omitempty"`
+	CommentPrefixes        []string          `json:"comment_prefixes,omitempty"`
+	MaxFileSize            int64             `json:"max_file_size"`
+	MaxRanges              int               `json:"max_ranges"`
+	MaxStoredParseErrors   int               `json:"max_stored_parse_errors"`
+	MaxParseErrors         int               `json:"max_parse_errors,omitempty"`
+	AnonymizedPrefixLen    int               `json:"anonymized_prefix_len,omitempty"`
+	RefreshInterval        time.Duration     `json:"refresh_interval,omitempty"`
+	ReloadJitter           time.Duration     `json:"reload_jitter,omitempty"`
+	CacheTTL               time.Duration     `json:"cache_ttl,omitempty"`
+	InitCooldown           time.Duration     `json:"init_cooldown,omitempty"`
+	ParseWorkers           int               `json:"parse_workers,omitempty"`
+	InitFailureThreshold   int               `json:"init_failure_threshold,omitempty"`
+	CacheSize              int               `json:"cache_size"`
+	CacheDegradedWindow    int               `json:"cache_degraded_window,omitempty"`
+	SelfBenchmarkLookups   int               `json:"self_benchmark_lookups,omitempty"`
+	CachePrefixLen         int               `json:"cache_prefix_len,omitempty"`
+	NegativeCacheSize      int               `json:"negative_cache_size,omitempty"`
+	CacheMemoryBudgetBytes int64             `json:"cache_memory_budget_bytes,omitempty"`
+	CacheDegradedRatio     float64           `json:"cache_degraded_ratio,omitempty"`
+	Format                 Format            `json:"format"`
+	CacheInvalidation      CacheInvalidation `json:"cache_invalidation"`
+	CacheEviction          CacheEviction     `json:"cache_eviction"`
+	NegativeCaching        NegativeCaching   `json:"negative_caching"`
+	IPv4Parsing            IPv4Parsing       `json:"ipv4_parsing"`
+	MultiCodePolicy        MultiCodePolicy   `json:"multi_code_policy"`
+	IndexStrategy          IndexStrategy     `json:"index_strategy"`
+	SkipHeader             bool              `json:"skip_header"`
+	RejectIntegerIPs       bool              `json:"reject_integer_ips,omitempty"`
+	StrictParsing          bool              `json:"strict_parsing,omitempty"`
+	DetectLayout           bool              `json:"detect_layout,omitempty"`
+	CustomCache            bool              `json:"custom_cache,omitempty"`
+	CustomLocationsFile    bool              `json:"custom_locations_file,omitempty"`
+	SnapshotDir            bool              `json:"snapshot_dir,omitempty"`
+	BeforeReload           bool              `json:"before_reload,omitempty"`
+	OnCacheDegraded        bool              `json:"on_cache_degraded,omitempty"`
+	HitSink                bool              `json:"hit_sink,omitempty"`
+	ProgressFunc           bool              `json:"progress_func,omitempty"`
+	NewRangeStore          bool              `json:"new_range_store,omitempty"`
+}
+
+func newDebugConfig(cfg Config) debugConfig {
+	groups := make([]string, 0, len(cfg.Groups))
+	for name := range cfg.Groups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	return debugConfig{
+		Name:                   cfg.Name,
+		DefaultCountryCode:     cfg.DefaultCountryCode,
+		Delimiter:              cfg.Delimiter,
+		ArchivePattern:         cfg.ArchivePattern,
+		Groups:                 groups,
+		CommentPrefixes:        cfg.CommentPrefixes,
+		MaxFileSize:            cfg.MaxFileSize,
+		MaxRanges:              cfg.MaxRanges,
+		MaxStoredParseErrors:   cfg.MaxStoredParseErrors,
+		MaxParseErrors:         cfg.MaxParseErrors,
+		AnonymizedPrefixLen:    cfg.AnonymizedPrefixLen,
+		RefreshInterval:        cfg.RefreshInterval,
+		ReloadJitter:           cfg.ReloadJitter,
+		CacheTTL:               cfg.CacheTTL,
+		InitCooldown:           cfg.InitCooldown,
+		ParseWorkers:           cfg.ParseWorkers,
+		InitFailureThreshold:   cfg.InitFailureThreshold,
+		CacheSize:              cfg.CacheSize,
+		CacheDegradedWindow:    cfg.CacheDegradedWindow,
+		SelfBenchmarkLookups:   cfg.SelfBenchmarkLookups,
+		CachePrefixLen:         cfg.CachePrefixLen,
+		NegativeCacheSize:      cfg.NegativeCacheSize,
+		CacheMemoryBudgetBytes: cfg.CacheMemoryBudgetBytes,
+		CacheDegradedRatio:     cfg.CacheDegradedRatio,
+		Format:                 cfg.Format,
+		CacheInvalidation:      cfg.CacheInvalidation,
+		CacheEviction:          cfg.CacheEviction,
+		NegativeCaching:        cfg.NegativeCaching,
+		IPv4Parsing:            cfg.IPv4Parsing,
+		MultiCodePolicy:        cfg.MultiCodePolicy,
+		IndexStrategy:          cfg.IndexStrategy,
+		SkipHeader:             cfg.SkipHeader,
+		RejectIntegerIPs:       cfg.RejectIntegerIPs,
+		StrictParsing:          cfg.StrictParsing,
+		DetectLayout:           cfg.DetectLayout,
+		CustomCache:            cfg.Cache != nil,
+		CustomLocationsFile:    cfg.LocationsFile != "",
+		SnapshotDir:            cfg.SnapshotDir != "",
+		BeforeReload:           cfg.BeforeReload != nil,
+		OnCacheDegraded:        cfg.OnCacheDegraded != nil,
+		HitSink:                cfg.HitSink != nil,
+		ProgressFunc:           cfg.ProgressFunc != nil,
+		NewRangeStore:          cfg.NewRangeStore != nil,
+	}
+}
+
+// redactSource reduces a dataset name to what is safe to share: the base name of a
+// file, or the scheme, host and base name of a URL without credentials or query.
+func redactSource(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host + "/" + filepath.Base(u.Path)
+	}
+	if name == "" {
+		return ""
+	}
+	return filepath.Base(name)
+}
+
+// debugBundle is the document written by DebugBundle.
+type debugBundle struct {
+	Time           time.Time           `json:"time"`
+	GoVersion      string              `json:"go_version"`
+	Stats          Stats               `json:"stats"`
+	Config         debugConfig         `json:"config"`
+	ParseErrors    ParseErrorSummary   `json:"parse_errors"`
+	LookupFailures map[ErrorCode]int64 `json:"lookup_failures"`
+	RecentFailures []failedLookup      `json:"recent_failures"`
+}
+
+// DebugBundle writes a JSON document to attach to bug reports against this package:
+// the statistics, the configuration without file paths or hooks, the parse errors of
+// the live dataset by kind, the failed lookups by error code and the most recent
+// failed lookups, with addresses truncated to their /24 block. The dataset source is
+// reduced to its base name.
+func (db *IPCountryDB) DebugBundle(w io.Writer) error {
+	bundle := debugBundle{
+		Time:      time.Now(),
+		GoVersion: runtime.Version(),
+		Stats:     db.Stats(),
+		Config:    newDebugConfig(db.config),
+	}
+	bundle.Stats.Source = redactSource(bundle.Stats.Source)
+	if snap := db.snapshot.Load(); snap != nil {
+		bundle.ParseErrors = snap.parseErrors.summary
+	}
+	bundle.LookupFailures, bundle.RecentFailures = db.failures.snapshot()
+	return writeJSON(w, bundle)
+}
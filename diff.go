@@ -0,0 +1,197 @@
+package ip2country
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// ipInterval is an inclusive interval of IPv4 addresses represented as uint32 values.
+type ipInterval struct {
+	start uint32
+	end   uint32
+}
+
+// rangeList is a sorted, non-overlapping range set, such as a rangeSnapshot.
+type rangeList interface {
+	len() int
+	at(i int) IPRange
+}
+
+// rangeSlice is a rangeList over a sorted slice.
+type rangeSlice []IPRange
+
+func (s rangeSlice) len() int { return len(s) }
+
+func (s rangeSlice) at(i int) IPRange { return s[i] }
+
+// RangeChange is an address interval whose country assignment differs between two
+// datasets. An empty code means the interval is not covered by that dataset.
+// Fields are ordered for optimal memory alignment.
+type RangeChange struct {
+	// OldCode is the country code in the old dataset.
+	OldCode string `json:"old_code"`
+	// NewCode is the country code in the new dataset.
+	NewCode string `json:"new_code"`
+	// StartIP is the first address of the interval.
+	StartIP uint32 `json:"start_ip"`
+	// EndIP is the last address of the interval.
+	EndIP uint32 `json:"end_ip"`
+}
+
+// DatasetDiff summarizes how the country assignment changed between two datasets, e.g.
+// to gate the promotion of a new release in a data pipeline.
+type DatasetDiff struct {
+	// Changes lists the changed intervals in ascending order. Adjacent intervals with
+	// the same old and new codes are merged.
+	Changes []RangeChange `json:"changes"`
+	// CoveredAddresses is the number of addresses covered by either dataset.
+	CoveredAddresses uint64 `json:"covered_addresses"`
+	// AddedAddresses is the number of addresses covered only by the new dataset.
+	AddedAddresses uint64 `json:"added_addresses"`
+	// RemovedAddresses is the number of addresses covered only by the old dataset.
+	RemovedAddresses uint64 `json:"removed_addresses"`
+	// ReassignedAddresses is the number of addresses whose country changed.
+	ReassignedAddresses uint64 `json:"reassigned_addresses"`
+}
+
+// ChangedAddresses returns the number of added, removed and reassigned addresses.
+func (d DatasetDiff) ChangedAddresses() uint64 {
+	return d.AddedAddresses + d.RemovedAddresses + d.ReassignedAddresses
+}
+
+// ChangedPercent returns ChangedAddresses as a percentage of CoveredAddresses.
+func (d DatasetDiff) ChangedPercent() float64 {
+	if d.CoveredAddresses == 0 {
+		return 0
+	}
+	return 100 * float64(d.ChangedAddresses()) / float64(d.CoveredAddresses)
+}
+
+// DiffRanges compares two range datasets, each free of overlaps as checked by
+// ValidateIPRanges. The inputs do not need to be sorted and are not modified.
+func DiffRanges(oldRanges, newRanges []IPRange) DatasetDiff {
+	return diffSorted(rangeSlice(sortedRanges(oldRanges)), rangeSlice(sortedRanges(newRanges)))
+}
+
+// diffSorted is DiffRanges for sorted range sets.
+func diffSorted(oldSorted, newSorted rangeList) DatasetDiff {
+	var d DatasetDiff
+	walkSegments(oldSorted, newSorted, func(start, end uint32, oldSeg, newSeg *IPRange) {
+		oldCode, newCode := oldSeg.Code, newSeg.Code
+		size := uint64(end) - uint64(start) + 1
+		if oldCode != "" || newCode != "" {
+			d.CoveredAddresses += size
+		}
+		if oldCode == newCode {
+			return
+		}
+		switch {
+		case oldCode == "":
+			d.AddedAddresses += size
+		case newCode == "":
+			d.RemovedAddresses += size
+		default:
+			d.ReassignedAddresses += size
+		}
+
+		n := len(d.Changes)
+		if n > 0 && uint64(d.Changes[n-1].EndIP)+1 == uint64(start) &&
+			d.Changes[n-1].OldCode == oldCode && d.Changes[n-1].NewCode == newCode {
+			d.Changes[n-1].EndIP = end
+			return
+		}
+		d.Changes = append(d.Changes, RangeChange{OldCode: oldCode, NewCode: newCode, StartIP: start, EndIP: end})
+	})
+	return d
+}
+
+// sortedRanges returns a copy of ranges sorted by StartIP.
+func sortedRanges(ranges []IPRange) []IPRange {
+	sorted := make([]IPRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartIP < sorted[j].StartIP
+	})
+	return sorted
+}
+
+// diffRanges returns the sorted, non-overlapping address intervals whose lookup answer
+// differs between two sorted, non-overlapping range sets. Cached answers carry the
+// bounds and alternate codes of the matched range, or the bounds of the gap for a
+// miss, so an address is reported as changed if any of them differs, not just its code.
+func diffRanges(oldRanges, newRanges rangeList) []ipInterval {
+	var changed []ipInterval
+	walkSegments(oldRanges, newRanges, func(start, end uint32, oldSeg, newSeg *IPRange) {
+		if sameAnswer(oldSeg, newSeg) {
+			return
+		}
+		n := len(changed)
+		if n > 0 && uint64(changed[n-1].end)+1 == uint64(start) {
+			changed[n-1].end = end
+		} else {
+			changed = append(changed, ipInterval{start: start, end: end})
+		}
+	})
+	return changed
+}
+
+// walkSegments splits the IPv4 address space into consecutive intervals over which
+// both sorted, non-overlapping range sets have a constant range or gap, and calls fn
+// for each in ascending order with the range of each set covering it. Gaps are passed
+// as ranges with the code "" spanning the whole gap.
+func walkSegments(oldRanges, newRanges rangeList, fn func(start, end uint32, oldSeg, newSeg *IPRange)) {
+	var i, j int
+	for cur := uint64(0); cur <= math.MaxUint32; {
+		for i < oldRanges.len() && uint64(oldRanges.at(i).EndIP) < cur {
+			i++
+		}
+		for j < newRanges.len() && uint64(newRanges.at(j).EndIP) < cur {
+			j++
+		}
+
+		oldSeg := segmentAt(oldRanges, i, cur)
+		newSeg := segmentAt(newRanges, j, cur)
+		end := uint64(min(oldSeg.EndIP, newSeg.EndIP))
+
+		fn(uint32(cur), uint32(end), &oldSeg, &newSeg)
+		cur = end + 1
+	}
+}
+
+// segmentAt returns the i-th range if it covers address cur, or else the gap before
+// it, as a range with the code "". i is the first range ending at or after cur.
+func segmentAt(ranges rangeList, i int, cur uint64) IPRange {
+	gap := IPRange{EndIP: math.MaxUint32}
+	if i > 0 {
+		gap.StartIP = ranges.at(i-1).EndIP + 1
+	}
+	if i >= ranges.len() {
+		return gap
+	}
+	r := ranges.at(i)
+	if uint64(r.StartIP) <= cur {
+		return r
+	}
+	gap.EndIP = r.StartIP - 1
+	return gap
+}
+
+// sameAnswer reports whether two segments of walkSegments give the same lookup answer.
+func sameAnswer(a, b *IPRange) bool {
+	return a.StartIP == b.StartIP && a.EndIP == b.EndIP && a.Code == b.Code &&
+		a.Country == b.Country && slices.Equal(a.AlternateCodes, b.AlternateCodes)
+}
+
+// intervalsContain reports whether ip falls into any of the sorted intervals.
+func intervalsContain(intervals []ipInterval, ip uint32) bool {
+	return intervalsOverlap(intervals, ip, ip)
+}
+
+// intervalsOverlap reports whether any of the sorted intervals overlaps [start, end].
+func intervalsOverlap(intervals []ipInterval, start, end uint32) bool {
+	idx := sort.Search(len(intervals), func(i int) bool {
+		return intervals[i].end >= start
+	})
+	return idx < len(intervals) && intervals[idx].start <= end
+}
@@ -0,0 +1,64 @@
+package ip2country
+
+import (
+	"context"
+	"fmt"
+)
+
+// LoadReport describes a dataset that was parsed and validated by ReloadDryRun without
+// being put into service.
+// Fields are ordered for optimal memory alignment.
+type LoadReport struct {
+	// Diff compares the dataset with the live one. It is nil if no dataset is loaded or
+	// either dataset is memory-mapped with Config.SnapshotDir or held in a RangeStore.
+	Diff *DatasetDiff
+	// Errors lists the rejected lines, capped at Config.MaxStoredParseErrors.
+	Errors []ParseError
+	// ErrorSummary counts all rejected lines by kind.
+	ErrorSummary ParseErrorSummary
+	// Stats describes the dataset as Stats would after a reload; the cache and panic
+	// counters are zero.
+	Stats Stats
+}
+
+// ReloadDryRun parses and validates the dataset of the source, or of the file at path
+// if given, exactly as a reload would, and reports the result without swapping the live
+// dataset, so CI/CD pipelines and admin endpoints can verify data before committing it.
+// Config.StrictParsing and Config.MaxParseErrors fail the dry run as they would fail
+// the reload. The next reload still sees the source as changed. A dataset read from a
+// stream cannot be read again; ReloadDryRun then fails with CodeUnsupported.
+func (db *IPCountryDB) ReloadDryRun(ctx context.Context, path ...string) (report *LoadReport, err error) {
+	defer recoverPanic(db.logger, db.config.Name, "dry run", &db.panics, &err)
+
+	src := db.source
+	if len(path) > 0 {
+		src = &FileSource{Path: path[0], ArchivePattern: db.config.ArchivePattern}
+	} else if d, ok := src.(dryRunSource); ok {
+		if src = d.dryRunCopy(); src == nil {
+			return nil, newLookupError(CodeUnsupported, namedError(db.config.Name, fmt.Errorf("dry run is not supported: %s cannot be read again", sourceName(db.source))))
+		}
+	}
+
+	db.loadMu.Lock()
+	defer db.loadMu.Unlock()
+
+	if db.life.closed() {
+		return nil, newLookupError(CodeClosed, namedError(db.config.Name, fmt.Errorf("dry run failed: %w", ErrClosed)))
+	}
+	snap, err := db.load(ctx, src)
+	if err != nil {
+		return nil, newLookupError(failureCode(err, CodeNotLoaded), namedError(db.config.Name, fmt.Errorf("dry run failed: %w", err)))
+	}
+
+	report = &LoadReport{
+		Errors:       snap.parseErrors.errors,
+		ErrorSummary: snap.parseErrors.summary,
+		Stats:        snap.stats,
+	}
+	report.Stats.Name = db.config.Name
+	if old := db.snapshot.Load(); old != nil && old.mapped == nil && snap.mapped == nil && old.store == nil && snap.store == nil {
+		diff := diffSorted(old, snap)
+		report.Diff = &diff
+	}
+	return report, nil
+}
@@ -0,0 +1,146 @@
+package ip2country
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EnrichOptions configures EnrichJSONLines and EnrichHandler.
+// Fields are ordered for optimal memory alignment.
+type EnrichOptions struct {
+	// Field is the dot-separated path of the address in each event, e.g. "client.ip".
+	// Defaults to "ip".
+	Field string
+	// Target is the dot-separated path the country code is written to, created as
+	// needed. Defaults to "country_code".
+	Target string
+	// UnknownCode is written to Target for events whose address cannot be resolved. If
+	// empty, Target is left unset for them.
+	UnknownCode string
+}
+
+// withDefaults returns o with the defaults applied.
+func (o EnrichOptions) withDefaults() EnrichOptions {
+	if o.Field == "" {
+		o.Field = "ip"
+	}
+	if o.Target == "" {
+		o.Target = "country_code"
+	}
+	return o
+}
+
+// EnrichJSONLines reads events as JSON objects, one per line, and writes each back on
+// its own line with the country code of its address added. Lines that are not JSON
+// objects are written unchanged, so every input line yields exactly one output line.
+// Output is flushed whenever no more input is buffered, which makes it usable as a
+// line-by-line subprocess, e.g. with the subprocess processor of Redpanda Connect
+// (Benthos) or an exec step in other stream processors.
+func EnrichJSONLines(ctx context.Context, r io.Reader, w io.Writer, reader CountryReader, opts EnrichOptions) error {
+	opts = opts.withDefaults()
+	in := bufio.NewReader(r)
+	out := bufio.NewWriter(w)
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(line) > 0 {
+			out.Write(enrichEvent(ctx, bytes.TrimRight(line, "\r\n"), reader, &opts))
+			out.WriteByte('\n')
+		}
+		if errors.Is(err, io.EOF) {
+			return out.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if in.Buffered() == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// EnrichHandler returns an HTTP handler that enriches the JSON events posted to it like
+// EnrichJSONLines and responds with the enriched events. A body may hold a single event
+// or one event per line, so it can serve as the target of the http processor of
+// Redpanda Connect (Benthos) or of an HTTP enrichment step in other pipelines.
+func EnrichHandler(reader CountryReader, opts EnrichOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		EnrichJSONLines(r.Context(), r.Body, w, reader, opts)
+	})
+}
+
+// enrichEvent returns line with the country code of the event's address added, or line
+// itself if it is not a JSON object.
+func enrichEvent(ctx context.Context, line []byte, reader CountryReader, opts *EnrichOptions) []byte {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return line
+	}
+	var event map[string]any
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	if err := dec.Decode(&event); err != nil {
+		return line
+	}
+
+	code := opts.UnknownCode
+	if ip, ok := eventField(event, opts.Field).(string); ok {
+		if c, err := reader.GetCountryCodeWithContext(ctx, ip); err == nil {
+			code = c
+		}
+	}
+	if code == "" {
+		return line
+	}
+	setEventField(event, opts.Target, code)
+	enriched, err := json.Marshal(event)
+	if err != nil {
+		return line
+	}
+	return enriched
+}
+
+// eventField returns the value at the dot-separated path of event, or nil.
+func eventField(event map[string]any, path string) any {
+	var v any = event
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// setEventField sets the value at the dot-separated path of event, replacing values
+// on the path that are not objects.
+func setEventField(event map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	m := event
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
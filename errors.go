@@ -0,0 +1,152 @@
+package ip2country
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Sentinel errors wrapped by lookup errors, so callers can tell a genuine miss from
+// invalid input or a failed load with errors.Is.
+var (
+	// ErrNotFound is returned when the address is valid but no loaded range or entry
+	// contains it.
+	ErrNotFound = errors.New("country not found for IP")
+	// ErrInvalidIP is returned when the address cannot be parsed.
+	ErrInvalidIP = errors.New("invalid IP")
+	// ErrNotInitialized is returned by lookups when the dataset could not be loaded.
+	ErrNotInitialized = errors.New("initialization failed")
+	// ErrFileTooLarge is returned when a dataset exceeds Config.MaxFileSize.
+	ErrFileTooLarge = errors.New("file size exceeds limit")
+)
+
+// ErrorCode is a stable, machine-readable classification of an error returned by this
+// package. Codes never change once released, so HTTP or gRPC layers can map them to
+// status codes without matching error messages.
+type ErrorCode string
+
+const (
+	// CodeNotFound means the address is valid but no loaded range or entry contains it.
+	CodeNotFound ErrorCode = "ERR_NOT_FOUND"
+	// CodeInvalidIP means the address could not be parsed.
+	CodeInvalidIP ErrorCode = "ERR_INVALID_IP"
+	// CodeInvalidArgument means an argument other than the address was rejected, such as
+	// an empty country code passed to ExactIPCountryMap.Set.
+	CodeInvalidArgument ErrorCode = "ERR_INVALID_ARGUMENT"
+	// CodeAmbiguous means an anonymized address block spans more than one range.
+	CodeAmbiguous ErrorCode = "ERR_AMBIGUOUS"
+	// CodeNotLoaded means no dataset could be loaded, so lookups cannot be served.
+	CodeNotLoaded ErrorCode = "ERR_NOT_LOADED"
+	// CodeStaleData means a reload failed and the previously loaded dataset is still
+	// being served.
+	CodeStaleData ErrorCode = "ERR_STALE_DATA"
+	// CodeClosed means the lookup was closed.
+	CodeClosed ErrorCode = "ERR_CLOSED"
+	// CodeCanceled means the context was canceled or its deadline exceeded.
+	CodeCanceled ErrorCode = "ERR_CANCELED"
+	// CodeUnavailable means no remote replica could answer.
+	CodeUnavailable ErrorCode = "ERR_UNAVAILABLE"
+	// CodeQueueFull means an AsyncLookup queue had no room left.
+	CodeQueueFull ErrorCode = "ERR_QUEUE_FULL"
+	// CodeUnsupported means the operation is not supported by the implementation.
+	CodeUnsupported ErrorCode = "ERR_UNSUPPORTED"
+	// CodeInternal covers everything else, including recovered panics.
+	CodeInternal ErrorCode = "ERR_INTERNAL"
+)
+
+// LookupError attaches an ErrorCode to an error returned by a lookup, reload or update.
+// Its message is that of the underlying error.
+type LookupError struct {
+	// Err is the underlying error.
+	Err error
+	// Code classifies the error.
+	Code ErrorCode
+}
+
+// Error returns a string representation of the LookupError.
+func (e *LookupError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// newLookupError wraps err with code.
+func newLookupError(code ErrorCode, err error) error {
+	return &LookupError{Err: err, Code: code}
+}
+
+// codeSentinels maps error codes to the sentinel errors they wrap.
+var codeSentinels = map[ErrorCode]error{
+	CodeNotFound:  ErrNotFound,
+	CodeInvalidIP: ErrInvalidIP,
+	CodeNotLoaded: ErrNotInitialized,
+	CodeClosed:    ErrClosed,
+}
+
+// serverError is an error reported by a lookup server, rebuilt by a client.
+type serverError struct {
+	sentinel error
+	msg      string
+}
+
+func (e *serverError) Error() string { return e.msg }
+func (e *serverError) Unwrap() error { return e.sentinel }
+
+// newServerError rebuilds an error reported by NewHTTPHandler or a SocketServer from
+// its code and message, so that errors.Is matches the same sentinel errors on the
+// client as on the server.
+func newServerError(code ErrorCode, msg string) error {
+	return newLookupError(code, &serverError{sentinel: codeSentinels[code], msg: msg})
+}
+
+// ErrorCodeOf returns the code of the first LookupError in err's chain. Errors without
+// one are classified by their sentinel errors, falling back to CodeInternal. It returns
+// an empty code for a nil error.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var lookupErr *LookupError
+	if errors.As(err, &lookupErr) {
+		return lookupErr.Code
+	}
+	return failureCode(err, CodeInternal)
+}
+
+// failureCode classifies a failure caused by Close, cancellation or a full queue, and
+// returns fallback for any other error.
+func failureCode(err error, fallback ErrorCode) ErrorCode {
+	switch {
+	case errors.Is(err, ErrClosed):
+		return CodeClosed
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	case errors.Is(err, ErrQueueFull):
+		return CodeQueueFull
+	}
+	return fallback
+}
+
+// HTTPStatus returns the HTTP status code conventionally used for code, as served by
+// NewHTTPHandler. Failures that another replica may not share map to 503.
+func HTTPStatus(code ErrorCode) int {
+	switch code {
+	case "":
+		return http.StatusOK
+	case CodeNotFound, CodeAmbiguous:
+		return http.StatusNotFound
+	case CodeInvalidIP, CodeInvalidArgument:
+		return http.StatusBadRequest
+	case CodeUnsupported:
+		return http.StatusNotImplemented
+	case CodeQueueFull:
+		return http.StatusTooManyRequests
+	case CodeInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
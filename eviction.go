@@ -0,0 +1,307 @@
+package ip2country
+
+import "container/list"
+
+// CacheEviction selects the policy that decides which cached lookups are evicted first
+// when the cache is full.
+type CacheEviction int
+
+const (
+	// CacheEvictLRU evicts the least recently used entry. It suits web traffic, where
+	// recently seen clients are likely to return.
+	CacheEvictLRU CacheEviction = iota
+	// CacheEvictSLRU is a segmented LRU: new entries start in a probationary segment
+	// and are promoted to a protected segment on their second hit, so a single pass
+	// over many addresses only flushes the probationary segment.
+	CacheEvictSLRU
+	// CacheEvictTinyLFU is W-TinyLFU: a small LRU window in front of a segmented LRU,
+	// with entries admitted to the main segments only if they are requested more often
+	// than the entry they would evict. It keeps the hot set under scanning workloads
+	// such as log replays, at the cost of a small frequency sketch.
+	CacheEvictTinyLFU
+)
+
+// evictionPolicy orders cached keys and chooses eviction victims.
+// Its methods are called with the cache mutex held.
+type evictionPolicy interface {
+	// add records a newly inserted key.
+	add(key uint32)
+	// access records a hit on a cached key.
+	access(key uint32)
+	// remove forgets a key that left the cache.
+	remove(key uint32)
+	// victim returns the key to evict next, or false if the policy tracks no keys.
+	victim() (uint32, bool)
+	// clear forgets all keys.
+	clear()
+}
+
+// newEvictionPolicy returns the policy for kind, sized for a cache of capacity entries.
+func newEvictionPolicy(kind CacheEviction, capacity int) evictionPolicy {
+	switch kind {
+	case CacheEvictSLRU:
+		return newSLRUPolicy(capacity)
+	case CacheEvictTinyLFU:
+		return newTinyLFUPolicy(capacity)
+	default:
+		return &lruPolicy{keys: newKeyList()}
+	}
+}
+
+// keyList is a recency-ordered list of keys with constant-time lookup.
+type keyList struct {
+	order *list.List
+	elems map[uint32]*list.Element
+}
+
+func newKeyList() *keyList {
+	return &keyList{order: list.New(), elems: make(map[uint32]*list.Element)}
+}
+
+func (k *keyList) len() int { return k.order.Len() }
+
+func (k *keyList) pushFront(key uint32) {
+	k.elems[key] = k.order.PushFront(key)
+}
+
+// moveToFront marks key as most recently used and reports whether it was present.
+func (k *keyList) moveToFront(key uint32) bool {
+	elem, ok := k.elems[key]
+	if ok {
+		k.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// remove deletes key and reports whether it was present.
+func (k *keyList) remove(key uint32) bool {
+	elem, ok := k.elems[key]
+	if ok {
+		k.order.Remove(elem)
+		delete(k.elems, key)
+	}
+	return ok
+}
+
+func (k *keyList) front() (uint32, bool) {
+	if elem := k.order.Front(); elem != nil {
+		return elem.Value.(uint32), true
+	}
+	return 0, false
+}
+
+func (k *keyList) back() (uint32, bool) {
+	if elem := k.order.Back(); elem != nil {
+		return elem.Value.(uint32), true
+	}
+	return 0, false
+}
+
+func (k *keyList) clear() {
+	k.order.Init()
+	k.elems = make(map[uint32]*list.Element)
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy struct {
+	keys *keyList
+}
+
+func (p *lruPolicy) add(key uint32)         { p.keys.pushFront(key) }
+func (p *lruPolicy) access(key uint32)      { p.keys.moveToFront(key) }
+func (p *lruPolicy) remove(key uint32)      { p.keys.remove(key) }
+func (p *lruPolicy) victim() (uint32, bool) { return p.keys.back() }
+func (p *lruPolicy) clear()                 { p.keys.clear() }
+
+// slruProtectedShare is the fraction of a segmented LRU reserved for the protected segment.
+const slruProtectedShare = 0.8
+
+// slruPolicy is a segmented LRU with a probationary and a protected segment.
+type slruPolicy struct {
+	probation    *keyList
+	protected    *keyList
+	protectedCap int
+}
+
+func newSLRUPolicy(capacity int) *slruPolicy {
+	return &slruPolicy{
+		probation:    newKeyList(),
+		protected:    newKeyList(),
+		protectedCap: max(1, int(float64(capacity)*slruProtectedShare)),
+	}
+}
+
+func (p *slruPolicy) add(key uint32) { p.probation.pushFront(key) }
+
+// access promotes a probationary key to the protected segment, demoting the least
+// recently used protected key back to probation when the segment is full.
+func (p *slruPolicy) access(key uint32) {
+	if p.protected.moveToFront(key) {
+		return
+	}
+	if !p.probation.remove(key) {
+		return
+	}
+	p.protected.pushFront(key)
+	if p.protected.len() > p.protectedCap {
+		demoted, _ := p.protected.back()
+		p.protected.remove(demoted)
+		p.probation.pushFront(demoted)
+	}
+}
+
+func (p *slruPolicy) remove(key uint32) {
+	if !p.probation.remove(key) {
+		p.protected.remove(key)
+	}
+}
+
+func (p *slruPolicy) victim() (uint32, bool) {
+	if key, ok := p.probation.back(); ok {
+		return key, true
+	}
+	return p.protected.back()
+}
+
+func (p *slruPolicy) clear() {
+	p.probation.clear()
+	p.protected.clear()
+}
+
+// tinyLFUWindowShare is the fraction of a W-TinyLFU cache used for the admission window.
+const tinyLFUWindowShare = 0.01
+
+// tinyLFUPolicy implements W-TinyLFU. New keys enter an LRU window; keys leaving the
+// window join the probationary segment of the main SLRU, where the newest arrival has
+// to be requested more often than the probationary victim to stay.
+type tinyLFUPolicy struct {
+	window    *keyList
+	main      *slruPolicy
+	sketch    *countMinSketch
+	windowCap int
+}
+
+func newTinyLFUPolicy(capacity int) *tinyLFUPolicy {
+	windowCap := max(1, int(float64(capacity)*tinyLFUWindowShare))
+	return &tinyLFUPolicy{
+		window:    newKeyList(),
+		main:      newSLRUPolicy(max(1, capacity-windowCap)),
+		sketch:    newCountMinSketch(capacity),
+		windowCap: windowCap,
+	}
+}
+
+func (p *tinyLFUPolicy) add(key uint32) {
+	p.sketch.increment(key)
+	p.window.pushFront(key)
+	if p.window.len() > p.windowCap {
+		moved, _ := p.window.back()
+		p.window.remove(moved)
+		p.main.add(moved)
+	}
+}
+
+func (p *tinyLFUPolicy) access(key uint32) {
+	p.sketch.increment(key)
+	if !p.window.moveToFront(key) {
+		p.main.access(key)
+	}
+}
+
+func (p *tinyLFUPolicy) remove(key uint32) {
+	if !p.window.remove(key) {
+		p.main.remove(key)
+	}
+}
+
+// victim lets the newest probationary key compete with the least recently used one and
+// evicts the less frequently requested of the two; ties evict the newcomer.
+func (p *tinyLFUPolicy) victim() (uint32, bool) {
+	if p.main.probation.len() >= 2 {
+		candidate, _ := p.main.probation.front()
+		incumbent, _ := p.main.probation.back()
+		if p.sketch.estimate(candidate) > p.sketch.estimate(incumbent) {
+			return incumbent, true
+		}
+		return candidate, true
+	}
+	if key, ok := p.main.victim(); ok {
+		return key, true
+	}
+	return p.window.back()
+}
+
+func (p *tinyLFUPolicy) clear() {
+	p.window.clear()
+	p.main.clear()
+	p.sketch.clear()
+}
+
+// sketchDepth is the number of rows in the count-min sketch.
+const sketchDepth = 4
+
+// sketchSeeds are the multiplicative hash seeds of the sketch rows.
+var sketchSeeds = [sketchDepth]uint32{0x9e3779b1, 0x85ebca77, 0xc2b2ae3d, 0x27d4eb2f}
+
+// countMinSketch estimates access frequencies with 4-bit saturating counters that are
+// halved periodically, so the estimates favor recent popularity.
+type countMinSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint32
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+	s := &countMinSketch{mask: uint32(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) index(key uint32, row int) uint32 {
+	h := (key ^ key>>16) * sketchSeeds[row]
+	return (h ^ h>>16) & s.mask
+}
+
+func (s *countMinSketch) increment(key uint32) {
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(key, i)]; *c < 15 {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+func (s *countMinSketch) estimate(key uint32) uint8 {
+	least := uint8(15)
+	for i := range s.rows {
+		least = min(least, s.rows[i][s.index(key, i)])
+	}
+	return least
+}
+
+// age halves every counter so that past popularity decays.
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) clear() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	s.additions = 0
+}
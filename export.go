@@ -0,0 +1,328 @@
+package ip2country
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"math/bits"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// EdgeFormat selects the CDN or firewall configuration format written by ExportPrefixes.
+type EdgeFormat int
+
+const (
+	// EdgeFormatFastlyVCL writes one Fastly VCL acl per country, named geo_<code>.
+	EdgeFormatFastlyVCL EdgeFormat = iota
+	// EdgeFormatCloudflare writes a Cloudflare ruleset JSON document with one blocking
+	// rule per country, matching ip.src against the country's prefixes.
+	EdgeFormatCloudflare
+	// EdgeFormatAWSWAF writes a JSON array of AWS WAF IPSet definitions, one per country,
+	// in the shape accepted by CreateIPSet.
+	EdgeFormatAWSWAF
+)
+
+// ExportFormat selects the output format of Export.
+type ExportFormat int
+
+const (
+	// ExportCSV writes a range per line as start,end,code with dotted addresses and
+	// alternate codes joined to the code with "/", the format NewIPCountryDB reads.
+	ExportCSV ExportFormat = iota
+	// ExportCIDR writes the ranges as prefix,code lines, merging adjacent ranges of the
+	// same codes first, the format read with FormatCIDR.
+	ExportCIDR
+	// ExportIndex writes a compiled index file, see WriteIndex.
+	ExportIndex
+	// ExportMMDB writes a MaxMind DB file with an IPv4 search tree and a country.iso_code
+	// per network, readable by MaxMind DB readers and tools built on them.
+	ExportMMDB
+	// ExportFastlyVCL writes Fastly VCL acls, see EdgeFormatFastlyVCL.
+	ExportFastlyVCL
+	// ExportCloudflare writes a Cloudflare ruleset, see EdgeFormatCloudflare.
+	ExportCloudflare
+	// ExportAWSWAF writes AWS WAF IPSet definitions, see EdgeFormatAWSWAF.
+	ExportAWSWAF
+)
+
+// exportCheckInterval is the number of ranges written between checks of the context.
+const exportCheckInterval = 1 << 14
+
+// ExportOptions holds options for Export.
+// Fields are ordered for optimal memory alignment.
+type ExportOptions struct {
+	// DatabaseType is the database_type of ExportMMDB files. Defaults to
+	// "ip2country-Country".
+	DatabaseType string
+	// Codes restricts the output to the ranges of these countries. Defaults to all.
+	Codes []string
+}
+
+// Export writes the loaded dataset in the given format. Range formats are streamed
+// from the live snapshot, so a reload during the export does not mix datasets.
+func (db *IPCountryDB) Export(w io.Writer, format ExportFormat, opts ExportOptions) error {
+	return db.ExportWithContext(context.Background(), w, format, opts)
+}
+
+// ExportWithContext is like Export but stops with the context's error once ctx is done.
+func (db *IPCountryDB) ExportWithContext(ctx context.Context, w io.Writer, format ExportFormat, opts ExportOptions) error {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return newLookupError(CodeNotLoaded, fmt.Errorf("export failed: %w", ErrNotInitialized))
+	}
+	var keep func(*IPRange) bool
+	if len(opts.Codes) > 0 {
+		wanted := make(map[string]bool, len(opts.Codes))
+		for _, code := range opts.Codes {
+			wanted[strings.ToUpper(code)] = true
+		}
+		keep = func(r *IPRange) bool { return wanted[r.Code] }
+	}
+
+	switch format {
+	case ExportCSV:
+		return writeRangeCSV(ctx, w, snap, keep)
+	case ExportCIDR:
+		return writeCIDRList(ctx, w, snap, keep)
+	case ExportIndex:
+		return writeIndex(ctx, w, snap, keep)
+	case ExportMMDB:
+		return writeMMDB(ctx, w, snap, keep, opts.DatabaseType)
+	case ExportFastlyVCL, ExportCloudflare, ExportAWSWAF:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return db.ExportPrefixes(w, EdgeFormat(format-ExportFastlyVCL), opts.Codes...)
+	default:
+		return newLookupError(CodeInvalidArgument, fmt.Errorf("unknown export format %d", format))
+	}
+}
+
+// writeRangeCSV writes the ranges of snap kept by keep as start,end,code lines.
+func writeRangeCSV(ctx context.Context, w io.Writer, snap *rangeSnapshot, keep func(*IPRange) bool) error {
+	bw := bufio.NewWriter(w)
+	for i, r := range snap.all() {
+		if i%exportCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if keep != nil && !keep(&r) {
+			continue
+		}
+		bw.WriteString(uint32ToAddr(r.StartIP).String())
+		bw.WriteByte(',')
+		bw.WriteString(uint32ToAddr(r.EndIP).String())
+		bw.WriteByte(',')
+		bw.WriteString(joinCodes(&r))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// writeCIDRList writes the ranges of snap kept by keep as prefix,code lines.
+func writeCIDRList(ctx context.Context, w io.Writer, snap *rangeSnapshot, keep func(*IPRange) bool) error {
+	bw := bufio.NewWriter(w)
+	var prefixes []netip.Prefix
+	for r, end := range mergedRuns(snap, joinCodes) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if keep != nil && !keep(&r) {
+			continue
+		}
+		codes := joinCodes(&r)
+		prefixes = appendRangePrefixes(prefixes[:0], r.StartIP, end)
+		for _, p := range prefixes {
+			bw.WriteString(p.String())
+			bw.WriteByte(',')
+			bw.WriteString(codes)
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// mergedRuns returns an iterator over the runs of adjacent ranges of snap with the same
+// key, yielding the first range of each run and the last address of the run.
+func mergedRuns(snap *rangeSnapshot, key func(*IPRange) string) iter.Seq2[IPRange, uint32] {
+	return func(yield func(IPRange, uint32) bool) {
+		var run IPRange
+		var runKey string
+		var end uint32
+		for i, r := range snap.all() {
+			if i > 0 && uint64(r.StartIP) == uint64(end)+1 && key(&r) == runKey {
+				end = r.EndIP
+				continue
+			}
+			if i > 0 && !yield(run, end) {
+				return
+			}
+			run, runKey, end = r, key(&r), r.EndIP
+		}
+		if snap.len() > 0 {
+			yield(run, end)
+		}
+	}
+}
+
+// uint32ToAddr converts an address in integer form to a netip.Addr.
+func uint32ToAddr(ipNum uint32) netip.Addr {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], ipNum)
+	return netip.AddrFrom4(b)
+}
+
+// joinCodes returns the code of r followed by its alternate codes, joined with
+// codeSeparator.
+func joinCodes(r *IPRange) string {
+	if len(r.AlternateCodes) == 0 {
+		return r.Code
+	}
+	return r.Code + codeSeparator + strings.Join(r.AlternateCodes, codeSeparator)
+}
+
+// Prefixes returns the loaded dataset as minimal per-country CIDR prefix lists, merging
+// adjacent ranges of the same country first. If codes are given, only those countries
+// are returned. It returns nil if the dataset is not loaded.
+func (db *IPCountryDB) Prefixes(codes ...string) map[string][]netip.Prefix {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		wanted[strings.ToUpper(code)] = true
+	}
+
+	prefixes := make(map[string][]netip.Prefix)
+	for r, end := range mergedRuns(snap, func(r *IPRange) string { return r.Code }) {
+		if len(wanted) > 0 && !wanted[r.Code] {
+			continue
+		}
+		prefixes[r.Code] = appendRangePrefixes(prefixes[r.Code], r.StartIP, end)
+	}
+	return prefixes
+}
+
+// appendRangePrefixes appends the smallest set of CIDR prefixes covering [start, end].
+func appendRangePrefixes(dst []netip.Prefix, start, end uint32) []netip.Prefix {
+	for cur := uint64(start); cur <= uint64(end); {
+		size := 32
+		if cur != 0 {
+			size = bits.TrailingZeros32(uint32(cur))
+		}
+		for size > 0 && cur+(1<<size)-1 > uint64(end) {
+			size--
+		}
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(cur))
+		dst = append(dst, netip.PrefixFrom(netip.AddrFrom4(b), 32-size))
+		cur += 1 << size
+	}
+	return dst
+}
+
+// ExportPrefixes writes the per-country prefix lists of the loaded dataset in the given
+// edge configuration format, so geo-blocking rules at the CDN or firewall are generated
+// from the same dataset the application uses. If codes are given, only those countries
+// are exported. Countries are written in alphabetical order.
+func (db *IPCountryDB) ExportPrefixes(w io.Writer, format EdgeFormat, codes ...string) error {
+	if db.snapshot.Load() == nil {
+		return newLookupError(CodeNotLoaded, fmt.Errorf("export failed: %w", ErrNotInitialized))
+	}
+	prefixes := db.Prefixes(codes...)
+	countries := make([]string, 0, len(prefixes))
+	for code := range prefixes {
+		countries = append(countries, code)
+	}
+	sort.Strings(countries)
+
+	switch format {
+	case EdgeFormatFastlyVCL:
+		return writeFastlyVCL(w, countries, prefixes)
+	case EdgeFormatCloudflare:
+		return writeCloudflareRuleset(w, countries, prefixes)
+	case EdgeFormatAWSWAF:
+		return writeAWSWAFIPSets(w, countries, prefixes)
+	default:
+		return newLookupError(CodeInvalidArgument, fmt.Errorf("unknown edge format %d", format))
+	}
+}
+
+// writeFastlyVCL writes an acl block per country.
+func writeFastlyVCL(w io.Writer, countries []string, prefixes map[string][]netip.Prefix) error {
+	bw := bufio.NewWriter(w)
+	for i, code := range countries {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "acl geo_%s {\n", strings.ToLower(code))
+		for _, p := range prefixes[code] {
+			fmt.Fprintf(bw, "  %q/%d;\n", p.Addr().String(), p.Bits())
+		}
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
+
+// cloudflareRule is a rule of a Cloudflare ruleset.
+type cloudflareRule struct {
+	Action      string `json:"action"`
+	Description string `json:"description"`
+	Expression  string `json:"expression"`
+}
+
+// writeCloudflareRuleset writes a ruleset with a blocking rule per country.
+func writeCloudflareRuleset(w io.Writer, countries []string, prefixes map[string][]netip.Prefix) error {
+	rules := make([]cloudflareRule, 0, len(countries))
+	for _, code := range countries {
+		list := make([]string, len(prefixes[code]))
+		for i, p := range prefixes[code] {
+			list[i] = p.String()
+		}
+		rules = append(rules, cloudflareRule{
+			Action:      "block",
+			Description: "ip2country " + code,
+			Expression:  "(ip.src in {" + strings.Join(list, " ") + "})",
+		})
+	}
+	return writeJSON(w, struct {
+		Rules []cloudflareRule `json:"rules"`
+	}{rules})
+}
+
+// awsIPSet is an AWS WAF IPSet definition.
+type awsIPSet struct {
+	Name             string   `json:"Name"`
+	Scope            string   `json:"Scope"`
+	IPAddressVersion string   `json:"IPAddressVersion"`
+	Addresses        []string `json:"Addresses"`
+}
+
+// writeAWSWAFIPSets writes an IPSet definition per country.
+func writeAWSWAFIPSets(w io.Writer, countries []string, prefixes map[string][]netip.Prefix) error {
+	sets := make([]awsIPSet, 0, len(countries))
+	for _, code := range countries {
+		set := awsIPSet{Name: "ip2country-" + code, Scope: "REGIONAL", IPAddressVersion: "IPV4"}
+		for _, p := range prefixes[code] {
+			set.Addresses = append(set.Addresses, p.String())
+		}
+		sets = append(sets, set)
+	}
+	return writeJSON(w, sets)
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,255 @@
+package ip2country
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+	"unique"
+)
+
+// Format identifies the layout of a range dataset.
+type Format int
+
+const (
+	// FormatDBIP is the DB-IP layout: start_ip,end_ip,country_code.
+	FormatDBIP Format = iota
+	// FormatGeoLite2 is the MaxMind GeoLite2-Country CSV layout: a blocks file with
+	// network (CIDR) and geoname_id columns, resolved to country codes through the
+	// companion locations file (see Config.LocationsFile).
+	FormatGeoLite2
+	// FormatCIDR is a two-column layout of CIDR blocks: network,country_code, e.g.
+	// "1.0.0.0/24,AU". With FormatDBIP, such lines are detected and parsed as well.
+	// Networks with host bits set, such as "1.0.0.5/24", are parse errors of kind
+	// ParseErrorHostBits.
+	FormatCIDR
+)
+
+// geoLite2LocationsFile is the default name of the GeoLite2 companion locations file.
+const geoLite2LocationsFile = "GeoLite2-Country-Locations-en.csv"
+
+// splitFields splits a line of a data file at delimiter. Fields may be quoted as in
+// RFC 4180, so a quoted field can contain the delimiter, e.g. "Korea, Republic of", and
+// escaped quotes. Lines without quotes take a fast path.
+func splitFields(line, delimiter string) ([]string, error) {
+	comma, size := utf8.DecodeRuneInString(delimiter)
+	if !strings.Contains(line, `"`) || size != len(delimiter) {
+		return strings.Split(line, delimiter), nil
+	}
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	fields, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errQuoting, err)
+	}
+	return fields, nil
+}
+
+// isComment reports whether line starts with one of the comment prefixes.
+func isComment(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// codeSeparator separates the codes of a range tagged with several codes, e.g. "UA/RU".
+const codeSeparator = "/"
+
+// internCode returns the canonical copy of a country code, so the ranges of a country
+// share one string instead of each keeping a substring of its line, and the line,
+// alive. It is safe for concurrent use.
+func internCode(code string) string {
+	return unique.Make(code).Value()
+}
+
+// splitCodes splits a country code field listing several codes into the code chosen by
+// policy and the others, in the order listed. A field with a single code has no
+// alternates.
+func splitCodes(field string, policy MultiCodePolicy) (code string, alternates []string, err error) {
+	field = strings.TrimSpace(field)
+	if !strings.Contains(field, codeSeparator) {
+		return internCode(field), nil, nil
+	}
+	if policy == MultiCodeReject {
+		return "", nil, fmt.Errorf("%w: %q", errMultiCode, field)
+	}
+	var codes []string
+	for _, c := range strings.Split(field, codeSeparator) {
+		if c = strings.TrimSpace(c); c != "" {
+			codes = append(codes, internCode(c))
+		}
+	}
+	switch {
+	case len(codes) == 0:
+		return "", nil, errEmptyCode
+	case len(codes) == 1:
+		return codes[0], nil, nil
+	case policy == MultiCodeLast:
+		return codes[len(codes)-1], codes[:len(codes)-1], nil
+	default:
+		return codes[0], codes[1:], nil
+	}
+}
+
+// errSkipLine marks a line that carries no range and is not an error, such as a header.
+var errSkipLine = errors.New("skip line")
+
+// geoLite2Locations opens the locations file for the blocks file provided by src and
+// returns a map from geoname_id to ISO country code. If Config.LocationsFile is empty,
+// the file is looked up next to the blocks file, or inside the same archive.
+func geoLite2Locations(src Source, cfg Config) (map[string]string, error) {
+	var file io.ReadCloser
+	var err error
+	locPath := cfg.LocationsFile
+	switch rel, ok := src.(relatedOpener); {
+	case locPath != "":
+		file, _, err = openDataFile(locPath, geoLite2LocationsFile)
+	case ok:
+		locPath = geoLite2LocationsFile
+		file, err = rel.openRelated(geoLite2LocationsFile, "*-Locations-en.csv")
+	default:
+		return nil, fmt.Errorf("Config.LocationsFile is required to load GeoLite2 data from %s", sourceName(src))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open locations file: %w", err)
+	}
+	defer file.Close()
+
+	locations, err := parseGeoLite2Locations(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse locations file %s: %w", locPath, err)
+	}
+	return locations, nil
+}
+
+// parseGeoLite2Locations reads a GeoLite2 locations CSV, locating the geoname_id and
+// country_iso_code columns by header. Continent-only entries are skipped.
+func parseGeoLite2Locations(r io.Reader) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	idCol, codeCol := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case "geoname_id":
+			idCol = i
+		case "country_iso_code":
+			codeCol = i
+		}
+	}
+	if idCol < 0 || codeCol < 0 {
+		return nil, fmt.Errorf("missing geoname_id or country_iso_code column")
+	}
+
+	locations := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) <= max(idCol, codeCol) || record[codeCol] == "" {
+			continue
+		}
+		locations[record[idCol]] = record[codeCol]
+	}
+	return locations, nil
+}
+
+// parseCIDRLine parses the fields of a line in FormatCIDR into an IPRange.
+// Expected format: network,country_code. Networks with host bits set are rejected
+// rather than widened, as they usually come from garbled rows.
+func parseCIDRLine(parts []string, policy MultiCodePolicy) (*IPRange, error) {
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: expected 2, got %d", errFieldCount, len(parts))
+	}
+	network := strings.TrimSpace(parts[0])
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidNetwork, network, err)
+	}
+	if !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("not an IPv4 network: %s: %w", network, ErrIPv6Unsupported)
+	}
+	if masked := prefix.Masked(); masked != prefix {
+		return nil, fmt.Errorf("%w %s: did you mean %s?", errHostBits, network, masked)
+	}
+
+	code, alternates, err := splitCodes(parts[1], policy)
+	if err != nil {
+		return nil, err
+	}
+	addr := prefix.Addr().As4()
+	start := binary.BigEndian.Uint32(addr[:])
+	ipRange := &IPRange{
+		StartIP:        start,
+		EndIP:          start | ^prefixMask(prefix.Bits()),
+		Country:        code,
+		Code:           code,
+		AlternateCodes: alternates,
+	}
+	if err := ipRange.Validate(); err != nil {
+		return nil, err
+	}
+	return ipRange, nil
+}
+
+// parseGeoLite2Line parses a line of a GeoLite2 blocks file into an IPRange.
+// Expected format: network,geoname_id,registered_country_geoname_id,...
+// Networks without any country (anonymous proxies, satellite providers) are skipped.
+func parseGeoLite2Line(line, delimiter string, locations map[string]string) (*IPRange, error) {
+	parts, err := splitFields(line, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("%w: expected at least 3, got %d", errFieldCount, len(parts))
+	}
+	network := strings.TrimSpace(parts[0])
+	if network == "network" {
+		return nil, errSkipLine
+	}
+
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidNetwork, network, err)
+	}
+	if !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("not an IPv4 network: %s: %w", network, ErrIPv6Unsupported)
+	}
+
+	geonameID := strings.TrimSpace(parts[1])
+	if geonameID == "" {
+		geonameID = strings.TrimSpace(parts[2])
+	}
+	if geonameID == "" {
+		return nil, errSkipLine
+	}
+	code, ok := locations[geonameID]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", errUnknownLocation, geonameID)
+	}
+
+	addr := prefix.Masked().Addr().As4()
+	start := binary.BigEndian.Uint32(addr[:])
+	return &IPRange{
+		StartIP: start,
+		EndIP:   start | ^prefixMask(prefix.Bits()),
+		Country: code,
+		Code:    code,
+	}, nil
+}
@@ -0,0 +1,123 @@
+package ip2country
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the format tests")
+
+// TestFormatGolden parses the sample datasets in testdata/golden and compares the ranges
+// or entries and the parse errors with the .golden files next to them. Run with -update
+// to rewrite the golden files after an intended change of parser behavior.
+func TestFormatGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format Format
+		exact  bool // Two-column ip,country_code layout routed to ExactIPCountryMap.
+	}{
+		{"dbip", "dbip.csv", FormatDBIP, false},
+		{"geolite2", "GeoLite2-Country-Blocks-IPv4.csv", FormatGeoLite2, false},
+		{"cidr", "cidr.csv", FormatCIDR, false},
+		{"exact", "exact.csv", FormatDBIP, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Format = tt.format
+			input := filepath.Join("testdata", "golden", tt.input)
+			var got string
+			if tt.exact {
+				got = parseExactGolden(t, input, cfg)
+			} else {
+				result, err := ParseCSVRanges(input, cfg)
+				if err != nil {
+					t.Fatalf("ParseCSVRanges: %v", err)
+				}
+				got = formatParseResult(result)
+			}
+
+			path := filepath.Join("testdata", "golden", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("parsed %s differs from %s:\ngot:\n%s\nwant:\n%s", tt.input, path, got, want)
+			}
+		})
+	}
+}
+
+// parseExactGolden loads the file at path through NewAuto, which must detect the exact
+// IP layout, and renders the entries in address order and the parse errors.
+func parseExactGolden(t *testing.T, path string, cfg Config) string {
+	t.Helper()
+	lookup, err := NewAuto(path, cfg)
+	if err != nil {
+		t.Fatalf("NewAuto: %v", err)
+	}
+	m, ok := lookup.(*ExactIPCountryMap)
+	if !ok {
+		t.Fatalf("NewAuto returned %T, want *ExactIPCountryMap", lookup)
+	}
+	snap, err := m.load(context.Background())
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var ips []uint32
+	for i := range snap.ipMap.shards {
+		for ip := range snap.ipMap.shards[i].m {
+			ips = append(ips, ip)
+		}
+	}
+	slices.Sort(ips)
+	var b strings.Builder
+	for _, ip := range ips {
+		code, _ := snap.ipMap.get(ip)
+		fmt.Fprintf(&b, "%s %s\n", goldenAddr(ip), code)
+	}
+	for _, e := range snap.parseErrors.errors {
+		fmt.Fprintf(&b, "line %d: %s: %q\n", e.Line, e.Kind, e.Content)
+	}
+	return b.String()
+}
+
+// formatParseResult renders the ranges and parse errors of result, one per line.
+func formatParseResult(result *ParseResult) string {
+	var b strings.Builder
+	for _, r := range result.Ranges {
+		fmt.Fprintf(&b, "%s-%s %s %s", goldenAddr(r.StartIP), goldenAddr(r.EndIP), r.Code, r.Country)
+		if len(r.AlternateCodes) > 0 {
+			fmt.Fprintf(&b, " %s", strings.Join(r.AlternateCodes, codeSeparator))
+		}
+		b.WriteByte('\n')
+	}
+	for _, e := range result.Errors {
+		fmt.Fprintf(&b, "line %d: %s: %q\n", e.Line, e.Kind, e.Content)
+	}
+	return b.String()
+}
+
+// goldenAddr renders an address in integer form in dot-decimal notation.
+func goldenAddr(ipNum uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], ipNum)
+	return netip.AddrFrom4(b).String()
+}
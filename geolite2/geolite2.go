@@ -0,0 +1,296 @@
+// Package geolite2 downloads and unpacks the MaxMind GeoLite2-Country CSV database
+// using a MaxMind license key, and can keep it up to date on a schedule.
+//
+// GeoLite2 data is subject to the MaxMind GeoLite2 End User License Agreement; a free
+// account and license key are required, see https://www.maxmind.com/en/geolite2/signup.
+package geolite2
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/byteonabeach/ip2country"
+)
+
+// DefaultBaseURL is the MaxMind download endpoint.
+const DefaultBaseURL = "https://download.maxmind.com/app/geoip_download"
+
+// Edition is the MaxMind edition ID of the country CSV database.
+const Edition = "GeoLite2-Country-CSV"
+
+// BlocksIPv4File is the name of the IPv4 network blocks file inside the database.
+const BlocksIPv4File = "GeoLite2-Country-Blocks-IPv4.csv"
+
+// checksumFile records the checksum of the last unpacked archive in the target directory.
+const checksumFile = Edition + ".sha256"
+
+// Config holds settings for Fetch and Updater.
+// Fields are ordered for optimal memory alignment.
+type Config struct {
+	// Client is the HTTP client used for downloads. If nil, a client with a 5m timeout is used.
+	Client *http.Client
+	// Logger receives update events. If nil, events are discarded.
+	Logger *slog.Logger
+	// LicenseKey is the MaxMind license key. It is required.
+	LicenseKey string
+	// BaseURL overrides DefaultBaseURL, e.g. for an internal mirror.
+	BaseURL string
+	// Interval is how often Updater checks for a new release. Defaults to 24h; MaxMind
+	// publishes GeoLite2 twice a week.
+	Interval time.Duration
+}
+
+// withDefaults returns cfg with unset fields filled in.
+func (cfg Config) withDefaults() (Config, error) {
+	if cfg.LicenseKey == "" {
+		return cfg, errors.New("geolite2: a MaxMind license key is required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.DiscardHandler)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 24 * time.Hour
+	}
+	return cfg, nil
+}
+
+// Fetch downloads the latest GeoLite2-Country CSV archive, verifies its SHA-256
+// checksum, unpacks its files into dir and returns the path of BlocksIPv4File.
+// If dir already holds the latest release, nothing is downloaded.
+func Fetch(ctx context.Context, dir string, cfg Config) (string, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	_, err = fetch(ctx, dir, cfg)
+	return filepath.Join(dir, BlocksIPv4File), err
+}
+
+// fetch performs the download and reports whether new files were unpacked.
+func fetch(ctx context.Context, dir string, cfg Config) (bool, error) {
+	body, err := get(ctx, cfg, "zip.sha256")
+	if err != nil {
+		return false, err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return false, errors.New("geolite2: empty checksum response")
+	}
+	want := strings.ToLower(fields[0])
+
+	if current, err := os.ReadFile(filepath.Join(dir, checksumFile)); err == nil && strings.TrimSpace(string(current)) == want {
+		return false, nil
+	}
+
+	archive, err := downloadArchive(ctx, cfg, dir, want)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(archive)
+
+	if err := unpack(archive, dir); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumFile), []byte(want+"\n"), 0o644); err != nil {
+		return false, fmt.Errorf("geolite2: failed to record checksum: %w", err)
+	}
+	return true, nil
+}
+
+// downloadURL builds the download URL for the given file suffix.
+func downloadURL(cfg Config, suffix string) string {
+	q := url.Values{}
+	q.Set("edition_id", Edition)
+	q.Set("license_key", cfg.LicenseKey)
+	q.Set("suffix", suffix)
+	return cfg.BaseURL + "?" + q.Encode()
+}
+
+// request issues a GET request for the given suffix and checks the status code.
+func request(ctx context.Context, cfg Config, suffix string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL(cfg, suffix), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		// Strip the URL, which contains the license key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("geolite2: download failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, errors.New("geolite2: license key rejected")
+		}
+		return nil, fmt.Errorf("geolite2: download of %s returned status %d", suffix, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// get downloads a small response body for the given suffix.
+func get(ctx context.Context, cfg Config, suffix string) ([]byte, error) {
+	resp, err := request(ctx, cfg, suffix)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, 4096))
+}
+
+// downloadArchive saves the zip archive to a temporary file in dir and verifies that its
+// checksum matches want. The caller removes the returned file.
+func downloadArchive(ctx context.Context, cfg Config, dir, want string) (string, error) {
+	resp, err := request(ctx, cfg, "zip")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	tmp, err := os.CreateTemp(dir, Edition+".*.zip")
+	if err != nil {
+		return "", fmt.Errorf("geolite2: failed to create file: %w", err)
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("geolite2: download failed: %w", err)
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("geolite2: checksum mismatch: got %s, want %s", got, want)
+	}
+	return tmp.Name(), nil
+}
+
+// unpack extracts the regular files of the archive into dir, dropping the dated
+// top-level directory. Each file is written under a temporary name and renamed, so a
+// reader never observes a partially written file.
+func unpack(archivePath, dir string) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("geolite2: failed to open archive: %w", err)
+	}
+	defer archive.Close()
+
+	found := false
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Base(f.Name)
+		if err := extract(f, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		found = found || name == BlocksIPv4File
+	}
+	if !found {
+		return fmt.Errorf("geolite2: %s not found in archive", BlocksIPv4File)
+	}
+	return nil
+}
+
+// extract writes a single archive entry to dest.
+func extract(f *zip.File, dest string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("geolite2: failed to open archive entry %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("geolite2: failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		return fmt.Errorf("geolite2: failed to extract %s: %w", f.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("geolite2: failed to extract %s: %w", f.Name, err)
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// Updater keeps a GeoLite2 directory up to date and reloads a lookup whenever a new
+// release has been unpacked. The lookup should be created from the path returned by
+// Fetch with Config.Format set to ip2country.FormatGeoLite2.
+type Updater struct {
+	lookup ip2country.DBAdmin
+	dir    string
+	config Config
+}
+
+// NewUpdater creates an Updater that unpacks releases into dir and reloads lookup.
+func NewUpdater(lookup ip2country.DBAdmin, dir string, cfg Config) (*Updater, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return &Updater{lookup: lookup, dir: dir, config: cfg}, nil
+}
+
+// Update checks for a new release once and reloads the lookup if one was unpacked.
+// It reports whether the lookup was reloaded.
+func (u *Updater) Update(ctx context.Context) (bool, error) {
+	updated, err := fetch(ctx, u.dir, u.config)
+	if err != nil || !updated {
+		return false, err
+	}
+	if err := u.lookup.ReloadWithContext(ctx); err != nil {
+		return false, fmt.Errorf("geolite2: reload failed: %w", err)
+	}
+	return true, nil
+}
+
+// Run checks for updates every Config.Interval until ctx is canceled. Failed updates
+// are logged and retried at the next interval.
+func (u *Updater) Run(ctx context.Context) error {
+	ticker := time.NewTicker(u.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			updated, err := u.Update(ctx)
+			switch {
+			case err != nil:
+				u.config.Logger.Error("geolite2 update failed", "dir", u.dir, "error", err)
+			case updated:
+				u.config.Logger.Info("geolite2 updated", "dir", u.dir)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ip2country
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SanctionedGroup is the name of the built-in group of commonly sanctioned jurisdictions.
+// Overriding it in Config.Groups or via SetGroups replaces the preset.
+const SanctionedGroup = "SANCTIONED"
+
+// SanctionedPresetVersion identifies the revision of the built-in sanctions preset.
+// It is bumped whenever the preset list changes.
+const SanctionedPresetVersion = "2026.10"
+
+// sanctionedPreset lists jurisdictions subject to broad export-control or sanctions
+// programs. It is a convenience default, not legal advice: review it against your own
+// compliance requirements and override it where they differ.
+var sanctionedPreset = []string{BY, CU, IR, KP, RU, SY}
+
+// EUGroup is the name of the built-in group of European Union member states, e.g. for
+// GDPR consent logic. Overriding it in Config.Groups or via SetGroups replaces the preset.
+const EUGroup = "EU"
+
+// euMembers lists the member states of the European Union.
+var euMembers = []string{
+	AT, BE, BG, CY, CZ, DE, DK, EE, ES, FI, FR, GR, HR, HU,
+	IE, IT, LT, LU, LV, MT, NL, PL, PT, RO, SE, SI, SK,
+}
+
+// builtinGroups are always available and may be overridden by user-defined groups.
+var builtinGroups = map[string][]string{
+	SanctionedGroup: sanctionedPreset,
+	EUGroup:         euMembers,
+}
+
+// SanctionedCountries returns a copy of the built-in sanctions preset.
+func SanctionedCountries() []string {
+	out := make([]string, len(sanctionedPreset))
+	copy(out, sanctionedPreset)
+	return out
+}
+
+// EUCountries returns a copy of the built-in list of European Union member states.
+func EUCountries() []string {
+	out := make([]string, len(euMembers))
+	copy(out, euMembers)
+	return out
+}
+
+// groupSet maps group names (e.g. "EEA") to the set of country codes in the group.
+type groupSet map[string]map[string]struct{}
+
+// newGroupSet builds a groupSet from the built-in groups overlaid with the given
+// definitions, normalizing codes to upper case.
+func newGroupSet(groups map[string][]string) *groupSet {
+	set := make(groupSet, len(builtinGroups)+len(groups))
+	for _, defs := range []map[string][]string{builtinGroups, groups} {
+		for name, codes := range defs {
+			members := make(map[string]struct{}, len(codes))
+			for _, code := range codes {
+				members[strings.ToUpper(strings.TrimSpace(code))] = struct{}{}
+			}
+			set[name] = members
+		}
+	}
+	return &set
+}
+
+// contains reports whether the country code is a member of the named group.
+func (g groupSet) contains(group, code string) (bool, error) {
+	members, ok := g[group]
+	if !ok {
+		return false, fmt.Errorf("unknown group %q", group)
+	}
+	_, ok = members[strings.ToUpper(code)]
+	return ok, nil
+}
+
+// ParseGroups decodes group definitions from JSON of the form
+//
+//	{"EEA": ["AT", "BE", ...], "FIVE_EYES": ["AU", "CA", "GB", "NZ", "US"]}
+//
+// The result can be assigned to Config.Groups.
+func ParseGroups(r io.Reader) (map[string][]string, error) {
+	var groups map[string][]string
+	if err := json.NewDecoder(r).Decode(&groups); err != nil {
+		return nil, fmt.Errorf("failed to decode groups: %w", err)
+	}
+	return groups, nil
+}
+
+// LoadGroups reads group definitions from a JSON file. See ParseGroups for the format.
+func LoadGroups(filePath string) (map[string][]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return ParseGroups(file)
+}
+
+// inGroup resolves an IP address through the given lookup and checks group membership.
+func inGroup(l CountryReader, groups *groupSet, ipStr, group string) (bool, error) {
+	if _, ok := (*groups)[group]; !ok {
+		return false, fmt.Errorf("unknown group %q", group)
+	}
+	code, err := l.GetCountryCode(ipStr)
+	if err != nil {
+		return false, err
+	}
+	return groups.contains(group, code)
+}
@@ -0,0 +1,206 @@
+package ip2country
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LookupHit is a successful lookup recorded by a HitSink.
+// Fields are ordered for optimal memory alignment.
+type LookupHit struct {
+	// Time is when the lookup was performed.
+	Time time.Time `json:"time"`
+	// Network is the /24 network of the looked-up address, so the exported data does
+	// not identify individual clients.
+	Network netip.Prefix `json:"network"`
+	// Code is the country code of the answer.
+	Code string `json:"code"`
+	// CacheHit reports whether the answer was served from the lookup cache.
+	CacheHit bool `json:"cache_hit"`
+}
+
+// HitSinkConfig holds configuration for a HitSink.
+// Fields are ordered for optimal memory alignment.
+type HitSinkConfig struct {
+	// BatchSize is the number of hits passed to the write function at once. Defaults
+	// to 1000.
+	BatchSize int
+	// QueueSize is the number of hits buffered for the writer goroutine. Hits recorded
+	// while the queue is full are dropped rather than slowing down lookups. Defaults to
+	// 8192.
+	QueueSize int
+	// FlushInterval is the longest a recorded hit waits before a partial batch is
+	// written. Defaults to 10 seconds.
+	FlushInterval time.Duration
+}
+
+// HitSinkStats reports the throughput of a HitSink.
+type HitSinkStats struct {
+	// Written is the number of hits passed to the write function.
+	Written int64 `json:"written"`
+	// Dropped is the number of hits discarded because the queue was full.
+	Dropped int64 `json:"dropped"`
+	// WriteErrors is the number of batches the write function failed to write.
+	WriteErrors int64 `json:"write_errors"`
+}
+
+// hitRecord is a queued hit, converted to a LookupHit by the writer goroutine.
+type hitRecord struct {
+	code   string
+	time   int64
+	ip     uint32
+	cached bool
+}
+
+// HitSink batches successful lookups and writes them asynchronously, e.g. to a file
+// or a ClickHouse HTTP insert, for offline analysis of geo traffic without a separate
+// logging pipeline. Set Config.HitSink to record the lookups of a database; several
+// databases may share one sink. HitSink is safe for concurrent use.
+type HitSink struct {
+	write     func([]LookupHit) error
+	queue     chan hitRecord
+	stop      chan struct{}
+	done      chan struct{}
+	err       error
+	config    HitSinkConfig
+	written   atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+	closeOnce sync.Once
+}
+
+// NewHitSink starts a HitSink that passes batches of hits to write, in the order they
+// were recorded. write is called from a single goroutine and must not retain the
+// slice. Close the sink to flush the remaining hits.
+func NewHitSink(write func([]LookupHit) error, config ...HitSinkConfig) *HitSink {
+	var cfg HitSinkConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 8192
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 10 * time.Second
+	}
+
+	s := &HitSink{
+		write:  write,
+		queue:  make(chan hitRecord, cfg.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		config: cfg,
+	}
+	go s.run()
+	return s
+}
+
+// JSONHitWriter returns a write function for NewHitSink that writes every hit to w as
+// a JSON object on its own line, the JSONEachRow format understood by ClickHouse and
+// most log shippers.
+func JSONHitWriter(w io.Writer) func([]LookupHit) error {
+	enc := json.NewEncoder(w)
+	return func(hits []LookupHit) error {
+		for i := range hits {
+			if err := enc.Encode(&hits[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// record queues a hit for ipNum without blocking.
+func (s *HitSink) record(ipNum uint32, code string, cached bool) {
+	select {
+	case <-s.stop:
+		s.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case s.queue <- hitRecord{code: code, time: time.Now().UnixNano(), ip: ipNum, cached: cached}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run collects queued hits into batches and writes them until the sink is closed.
+func (s *HitSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]LookupHit, 0, s.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.write(batch); err != nil {
+			s.failed.Add(1)
+			if s.err == nil {
+				s.err = err
+			}
+		} else {
+			s.written.Add(int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+	add := func(r hitRecord) {
+		var addr [4]byte
+		binary.BigEndian.PutUint32(addr[:], r.ip&prefixMask(24))
+		batch = append(batch, LookupHit{
+			Time:     time.Unix(0, r.time),
+			Network:  netip.PrefixFrom(netip.AddrFrom4(addr), 24),
+			Code:     r.code,
+			CacheHit: r.cached,
+		})
+		if len(batch) == cap(batch) {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case r := <-s.queue:
+			add(r)
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			for {
+				select {
+				case r := <-s.queue:
+					add(r)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stats reports how many hits were written and dropped.
+func (s *HitSink) Stats() HitSinkStats {
+	return HitSinkStats{
+		Written:     s.written.Load(),
+		Dropped:     s.dropped.Load(),
+		WriteErrors: s.failed.Load(),
+	}
+}
+
+// Close writes the hits still queued and stops the sink. Hits recorded afterwards are
+// dropped. It returns the first error of the write function, if any.
+func (s *HitSink) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,113 @@
+package ip2country
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPSource is a Source that downloads the dataset from an HTTP(S) URL, e.g. an
+// internal artifact server. It sends If-None-Match and If-Modified-Since on subsequent
+// requests, so reloading an unchanged dataset costs a single 304 response. Compressed
+// responses are decompressed when the URL ends in .gz or a registered suffix.
+type HTTPSource struct {
+	// Client is the HTTP client used for requests. If nil, a client with a 5m timeout is used.
+	Client *http.Client
+	// Header holds additional request headers, e.g. for authentication.
+	Header http.Header
+	// URL is the location of the dataset.
+	URL string
+
+	mu                  sync.Mutex
+	etag                string
+	lastModified        string
+	pendingETag         string // Validators of the response last opened, see commitLoad.
+	pendingLastModified string
+}
+
+// NewHTTPSource creates an HTTPSource for the given URL.
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{URL: url}
+}
+
+// Open downloads the dataset, or returns ErrNotModified if the server reports that it
+// has not changed since the last download that was put into service.
+func (s *HTTPSource) Open(ctx context.Context) (io.ReadCloser, Metadata, error) {
+	meta := Metadata{Name: s.URL, Size: -1}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, meta, err
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	s.mu.Lock()
+	// Validators of an earlier response must not be committed for this one.
+	s.pendingETag, s.pendingLastModified = "", ""
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+	s.mu.Unlock()
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, meta, fmt.Errorf("failed to download dataset: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, meta, fmt.Errorf("%w: %s", ErrNotModified, s.URL)
+	default:
+		resp.Body.Close()
+		return nil, meta, fmt.Errorf("failed to download dataset: %s returned status %d", s.URL, resp.StatusCode)
+	}
+
+	meta.ETag = resp.Header.Get("ETag")
+	meta.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	s.mu.Lock()
+	s.pendingETag, s.pendingLastModified = meta.ETag, resp.Header.Get("Last-Modified")
+	s.mu.Unlock()
+	body := resp.Body
+
+	decompress := decompressorFor(strings.SplitN(s.URL, "?", 2)[0])
+	if decompress == nil {
+		meta.Size = resp.ContentLength
+		return body, meta, nil
+	}
+	rc, err := decompress(body)
+	if err != nil {
+		body.Close()
+		return nil, meta, fmt.Errorf("failed to open compressed stream: %w", err)
+	}
+	return &multiCloser{Reader: rc, closers: []io.Closer{rc, body}}, meta, nil
+}
+
+// commitLoad makes the validators of the response last opened those sent by Open, once
+// the dataset read from it has been loaded successfully, even if the load stopped
+// reading early at Config.MaxRanges. A download that fails part way fails the load, so
+// its validators are never committed and it is fetched again in full.
+func (s *HTTPSource) commitLoad() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag, s.lastModified = s.pendingETag, s.pendingLastModified
+}
+
+// dryRunCopy returns a copy of s without the validators of the last download, so the
+// copy downloads the dataset unconditionally and s still sees it as new.
+func (s *HTTPSource) dryRunCopy() Source {
+	return &HTTPSource{Client: s.Client, Header: s.Header, URL: s.URL}
+}
@@ -0,0 +1,264 @@
+package ip2country
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// IndexStrategy selects how IPCountryDB searches the ranges of a dataset.
+type IndexStrategy int
+
+const (
+	// IndexAuto profiles every loaded dataset and picks the strategy expected to be
+	// fastest for it, reported in Stats.SearchStrategy.
+	IndexAuto IndexStrategy = iota
+	// IndexLinear scans the ranges in order, which beats the other strategies on tiny
+	// datasets such as override lists and test fixtures.
+	IndexLinear
+	// IndexBinary binary-searches the sorted ranges.
+	IndexBinary
+	// IndexJumpTable narrows the binary search to the ranges starting in the /8 network
+	// of the address, using a table of 257 offsets. It pays off when ranges are spread
+	// across many /8 networks.
+	IndexJumpTable
+	// IndexEytzinger binary-searches a copy of the start addresses in Eytzinger (BFS)
+	// order, whose first levels share cache lines, which is faster on large datasets
+	// that do not fit in the CPU cache. It takes 8 bytes per range of extra memory.
+	IndexEytzinger
+	// IndexJumpTable16 is IndexJumpTable with a table of 65537 offsets, one per /16
+	// network, which narrows searches to a handful of ranges on datasets of millions of
+	// ranges that crowd into a few /8 networks. The table takes 256 KiB.
+	IndexJumpTable16
+	// IndexBranchless binary-searches the start addresses with conditional moves instead
+	// of branches, so the time of a search does not depend on mispredicted branches. It
+	// suits latency-sensitive services doing tens of millions of lookups per second.
+	// Memory-mapped datasets take 4 bytes per range of extra memory for it.
+	IndexBranchless
+	// IndexInterpolation estimates the position of an address from the first and last
+	// start addresses of the searched span, which finds it in a few steps when ranges are
+	// spread evenly over the address space, and falls back to binary search after a few
+	// poor guesses. Memory-mapped datasets take 4 bytes per range of extra memory for it.
+	IndexInterpolation
+)
+
+// String returns the name of the strategy as reported in Stats.SearchStrategy.
+func (s IndexStrategy) String() string {
+	switch s {
+	case IndexAuto:
+		return "auto"
+	case IndexLinear:
+		return "linear"
+	case IndexBinary:
+		return "binary"
+	case IndexJumpTable:
+		return "jump_table"
+	case IndexEytzinger:
+		return "eytzinger"
+	case IndexJumpTable16:
+		return "jump_table_16"
+	case IndexBranchless:
+		return "branchless"
+	case IndexInterpolation:
+		return "interpolation"
+	default:
+		return "unknown"
+	}
+}
+
+// Thresholds used by IndexAuto.
+const (
+	// linearScanThreshold is the range count up to which the linear scan is used.
+	linearScanThreshold = 32
+	// eytzingerMinRanges is the range count from which the Eytzinger layout is
+	// considered, as smaller datasets stay in the CPU cache anyway.
+	eytzingerMinRanges = 1 << 16
+	// jumpBucketMax is the largest number of ranges starting in a single /8 network for
+	// which the jump table alone keeps searches within a few cache lines.
+	jumpBucketMax = 4096
+	// jump16MinRanges is the range count from which the /16 jump table is considered,
+	// as it only pays for its fixed size on large datasets.
+	jump16MinRanges = 1 << 18
+	// interpolationSteps is the number of position estimates an interpolation search
+	// makes before binary-searching what is left.
+	interpolationSteps = 4
+)
+
+// rangeIndex is the search structure of a rangeSnapshot. The zero value
+// binary-searches the ranges.
+type rangeIndex struct {
+	jump     *[257]uint32   // jump[o] is the index of the first range starting at or after o.0.0.0.
+	jump16   *[65537]uint32 // jump16[p] is the index of the first range starting at or after the /16 p.
+	eytz     []uint32       // Start addresses in Eytzinger order, 1-based.
+	eytzPos  []uint32       // Index in sorted order of each entry of eytz.
+	starts   []uint32       // Start addresses in sorted order.
+	strategy IndexStrategy
+}
+
+// buildIndex builds the search structure for the sorted ranges of s. IndexAuto picks
+// the strategy from the number of ranges and how they are spread across /8 networks.
+func buildIndex(s *rangeSnapshot, strategy IndexStrategy) rangeIndex {
+	n := s.len()
+	var jump [257]uint32
+	for i := range n {
+		jump[s.startAt(i)>>24+1]++
+	}
+	maxBucket := uint32(0)
+	for o := 1; o < len(jump); o++ {
+		maxBucket = max(maxBucket, jump[o])
+		jump[o] += jump[o-1]
+	}
+
+	if strategy == IndexAuto {
+		switch {
+		case n <= linearScanThreshold:
+			strategy = IndexLinear
+		case maxBucket <= jumpBucketMax:
+			strategy = IndexJumpTable
+		case n >= jump16MinRanges:
+			strategy = IndexJumpTable16
+		case n >= eytzingerMinRanges && s.mapped == nil:
+			// Keeps memory-mapped datasets off the heap.
+			strategy = IndexEytzinger
+		case int(maxBucket)*4 <= n:
+			strategy = IndexJumpTable
+		default:
+			strategy = IndexBinary
+		}
+	}
+
+	idx := rangeIndex{strategy: strategy}
+	switch strategy {
+	case IndexJumpTable:
+		idx.jump = &jump
+	case IndexJumpTable16:
+		idx.jump16 = new([65537]uint32)
+		for i := range n {
+			idx.jump16[s.startAt(i)>>16+1]++
+		}
+		for p := 1; p < len(idx.jump16); p++ {
+			idx.jump16[p] += idx.jump16[p-1]
+		}
+	case IndexBranchless, IndexInterpolation:
+		if s.columns != nil {
+			idx.starts = s.columns.starts
+		} else {
+			idx.starts = make([]uint32, n)
+			for i := range n {
+				idx.starts[i] = s.startAt(i)
+			}
+		}
+	case IndexEytzinger:
+		idx.eytz = make([]uint32, n+1)
+		idx.eytzPos = make([]uint32, n+1)
+		next := 0
+		var fill func(k int)
+		fill = func(k int) {
+			if k > n {
+				return
+			}
+			fill(2 * k)
+			idx.eytz[k] = s.startAt(next)
+			idx.eytzPos[k] = uint32(next)
+			next++
+			fill(2*k + 1)
+		}
+		fill(1)
+	}
+	return idx
+}
+
+// upperBound returns the number of ranges of s starting at or before ipNum, i.e. the
+// index of the first range starting after it.
+func (s *rangeSnapshot) upperBound(ipNum uint32) int {
+	n := s.len()
+	switch s.index.strategy {
+	case IndexLinear:
+		i := 0
+		for i < n && s.startAt(i) <= ipNum {
+			i++
+		}
+		return i
+	case IndexJumpTable:
+		lo, hi := int(s.index.jump[ipNum>>24]), int(s.index.jump[ipNum>>24+1])
+		return lo + sort.Search(hi-lo, func(i int) bool {
+			return s.startAt(lo+i) > ipNum
+		})
+	case IndexJumpTable16:
+		lo, hi := int(s.index.jump16[ipNum>>16]), int(s.index.jump16[ipNum>>16+1])
+		return lo + sort.Search(hi-lo, func(i int) bool {
+			return s.startAt(lo+i) > ipNum
+		})
+	case IndexBranchless:
+		return branchlessUpperBound(s.index.starts, ipNum)
+	case IndexInterpolation:
+		return interpolationUpperBound(s.index.starts, ipNum)
+	case IndexEytzinger:
+		k := 1
+		for k <= n {
+			k = 2 * k
+			if s.index.eytz[k/2] <= ipNum {
+				k++
+			}
+		}
+		// Undo the descent past the last left turn, which was the answer.
+		k >>= bits.TrailingZeros(uint(^k)) + 1
+		if k == 0 {
+			return n
+		}
+		return int(s.index.eytzPos[k])
+	default:
+		return sort.Search(n, func(i int) bool {
+			return s.startAt(i) > ipNum
+		})
+	}
+}
+
+// branchlessUpperBound returns the index of the first element of the sorted starts
+// greater than ipNum. The loop halves the span with a data-dependent move rather than
+// a branch, which the compiler turns into a conditional move.
+func branchlessUpperBound(starts []uint32, ipNum uint32) int {
+	n := len(starts)
+	if n == 0 {
+		return 0
+	}
+	base := 0
+	for n > 1 {
+		half := n / 2
+		if starts[base+half-1] <= ipNum {
+			base += half
+		}
+		n -= half
+	}
+	if starts[base] <= ipNum {
+		base++
+	}
+	return base
+}
+
+// interpolationUpperBound returns the index of the first element of the sorted starts
+// greater than ipNum.
+func interpolationUpperBound(starts []uint32, ipNum uint32) int {
+	lo, hi := 0, len(starts)
+	for range interpolationSteps {
+		if hi-lo <= linearScanThreshold {
+			break
+		}
+		first, last := starts[lo], starts[hi-1]
+		if ipNum < first {
+			return lo
+		}
+		if ipNum >= last {
+			return hi
+		}
+		// first <= ipNum < last, so the estimate lies in [lo, hi-1).
+		pos := lo + int(uint64(ipNum-first)*uint64(hi-1-lo)/uint64(last-first))
+		if starts[pos] <= ipNum {
+			lo = pos + 1
+		} else {
+			hi = pos
+		}
+	}
+	return lo + sort.Search(hi-lo, func(i int) bool {
+		return starts[lo+i] > ipNum
+	})
+}
@@ -0,0 +1,198 @@
+package ip2country
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// indexMagic starts every compiled index file.
+const indexMagic = "IP2CIDX1"
+
+// indexHeaderSize is the size of the header of a compiled index file: the magic, the
+// record and label counts as little-endian uint32s and the SHA-256 checksum of the
+// dataset the index was compiled from.
+const indexHeaderSize = len(indexMagic) + 4 + 4 + 32
+
+// errNotIndexFile is returned by openIndexFile for sources that are not compiled index
+// files, which are parsed as usual.
+var errNotIndexFile = errors.New("not a compiled index file")
+
+// WriteIndex compiles the loaded dataset into an index file: a header, the ranges as
+// fixed-width records sorted by start address, and the distinct country labels. Passed
+// to NewIPCountryDB, an index file is memory-mapped and searched in place instead of
+// being parsed, so it loads instantly and processes mapping the same file share its
+// pages in the page cache instead of each holding the ranges on the Go heap. Index
+// files are little-endian on every platform and can be copied between hosts.
+func (db *IPCountryDB) WriteIndex(w io.Writer) error {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return newLookupError(CodeNotLoaded, fmt.Errorf("write index failed: %w", ErrNotInitialized))
+	}
+	return writeIndex(context.Background(), w, snap, nil)
+}
+
+// writeIndex writes the ranges of snap for which keep returns true, or all of them if
+// keep is nil, as an index file.
+func writeIndex(ctx context.Context, w io.Writer, snap *rangeSnapshot, keep func(*IPRange) bool) error {
+	// The header needs the counts, so labels are collected before records are written.
+	var labels []rangeLabel
+	index := make(map[labelKey]uint32)
+	count := 0
+	for _, r := range snap.all() {
+		if keep != nil && !keep(&r) {
+			continue
+		}
+		count++
+		key := labelKey{country: r.Country, code: r.Code, alternates: strings.Join(r.AlternateCodes, codeSeparator)}
+		if _, ok := index[key]; !ok {
+			index[key] = uint32(len(labels))
+			labels = append(labels, rangeLabel{country: r.Country, code: r.Code, alternates: r.AlternateCodes})
+		}
+	}
+
+	var header [indexHeaderSize]byte
+	copy(header[:], indexMagic)
+	binary.LittleEndian.PutUint32(header[8:], uint32(count))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(labels)))
+	if sum, err := hex.DecodeString(snap.stats.Checksum); err == nil && len(sum) == 32 && keep == nil {
+		copy(header[16:], sum)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header[:])
+	for i, r := range snap.all() {
+		if i%exportCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if keep != nil && !keep(&r) {
+			continue
+		}
+		var rec [mappedRecordSize]byte
+		binary.LittleEndian.PutUint32(rec[0:], r.StartIP)
+		binary.LittleEndian.PutUint32(rec[4:], r.EndIP)
+		binary.LittleEndian.PutUint32(rec[8:], index[labelKey{country: r.Country, code: r.Code, alternates: strings.Join(r.AlternateCodes, codeSeparator)}])
+		bw.Write(rec[:])
+	}
+	for _, label := range labels {
+		for _, s := range []string{label.country, label.code, strings.Join(label.alternates, codeSeparator)} {
+			if len(s) > math.MaxUint16 {
+				return newLookupError(CodeInvalidArgument, fmt.Errorf("label too long to index: %.32q", s))
+			}
+			var n [2]byte
+			binary.LittleEndian.PutUint16(n[:], uint16(len(s)))
+			bw.Write(n[:])
+			bw.WriteString(s)
+		}
+	}
+	return bw.Flush()
+}
+
+// openIndexFile maps the compiled index file provided by src. It returns
+// errNotIndexFile if src is not a local file starting with indexMagic.
+func openIndexFile(src Source, maxFileSize int64) (*mappedRanges, *ParseResult, Metadata, error) {
+	fileSrc, ok := src.(*FileSource)
+	if !ok {
+		return nil, nil, Metadata{}, errNotIndexFile
+	}
+	meta := Metadata{Name: fileSrc.Path}
+	file, err := os.Open(fileSrc.Path)
+	if err != nil {
+		// Left to the regular load, which reports the error.
+		return nil, nil, meta, errNotIndexFile
+	}
+	defer file.Close()
+
+	var header [indexHeaderSize]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil || string(header[:len(indexMagic)]) != indexMagic {
+		return nil, nil, meta, errNotIndexFile
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, nil, meta, fmt.Errorf("failed to get file stats: %w", err)
+	}
+	meta.Size = stat.Size()
+	meta.ModTime = stat.ModTime()
+	if maxFileSize > 0 && meta.Size > maxFileSize {
+		return nil, nil, meta, fmt.Errorf("%w: %d > %d", ErrFileTooLarge, meta.Size, maxFileSize)
+	}
+
+	count := int64(binary.LittleEndian.Uint32(header[8:]))
+	labelCount := binary.LittleEndian.Uint32(header[12:])
+	recordsEnd := int64(indexHeaderSize) + count*mappedRecordSize
+	if recordsEnd > meta.Size || meta.Size > math.MaxInt {
+		return nil, nil, meta, fmt.Errorf("index file truncated: %d ranges do not fit in %d bytes", count, meta.Size)
+	}
+	data, err := mapFileReadOnly(file, int(meta.Size))
+	if err != nil {
+		return nil, nil, meta, fmt.Errorf("mapping index file: %w", err)
+	}
+
+	labels, err := readIndexLabels(data[recordsEnd:], labelCount)
+	if err != nil {
+		unmapFile(data)
+		return nil, nil, meta, err
+	}
+	m := &mappedRanges{data: data[indexHeaderSize:recordsEnd], labels: labels}
+	for i := range m.len() {
+		if binary.LittleEndian.Uint32(m.data[i*mappedRecordSize+8:]) >= labelCount {
+			unmapFile(data)
+			return nil, nil, meta, fmt.Errorf("index file corrupt: range %d has no label", i)
+		}
+	}
+	runtime.AddCleanup(m, unmapFile, data)
+
+	result := &ParseResult{Stats: Stats{
+		TotalRanges: m.len(),
+		FileSize:    meta.Size,
+		Source:      meta.Name,
+	}}
+	if sum := header[16:]; !bytes.Equal(sum, make([]byte, len(sum))) {
+		result.Stats.Checksum = hex.EncodeToString(sum)
+	}
+	return m, result, meta, nil
+}
+
+// readIndexLabels decodes the count labels at the start of data.
+func readIndexLabels(data []byte, count uint32) ([]rangeLabel, error) {
+	labels := make([]rangeLabel, 0, min(count, uint32(len(data)/6)))
+	next := func() (string, error) {
+		if len(data) < 2 {
+			return "", errors.New("index file corrupt: labels truncated")
+		}
+		n := int(binary.LittleEndian.Uint16(data))
+		if len(data) < 2+n {
+			return "", errors.New("index file corrupt: labels truncated")
+		}
+		s := string(data[2 : 2+n])
+		data = data[2+n:]
+		return s, nil
+	}
+	for range count {
+		var fields [3]string
+		for i := range fields {
+			s, err := next()
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = s
+		}
+		label := rangeLabel{country: fields[0], code: fields[1]}
+		if fields[2] != "" {
+			label.alternates = strings.Split(fields[2], codeSeparator)
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
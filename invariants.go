@@ -0,0 +1,40 @@
+package ip2country
+
+// checkInvariants validates the range index and the cached answer for ipNum after a
+// lookup, reporting violations through the logger. It is only called when
+// debugAssertions is set.
+func (db *IPCountryDB) checkInvariants(snap *rangeSnapshot, ipNum uint32, res *Result, lookupErr error) {
+	var prev IPRange
+	for i, cur := range snap.all() {
+		if i > 0 && prev.EndIP >= cur.StartIP {
+			db.logger.Error("invariant violation: ranges not sorted or overlapping",
+				"index", i, "previous_end", prev.EndIP, "start", cur.StartIP)
+			break
+		}
+		prev = cur
+	}
+
+	want, found := snap.search(ipNum)
+	switch {
+	case found != (lookupErr == nil):
+		db.logger.Error("invariant violation: lookup disagrees with index",
+			"ip", ipNum, "index_found", found, "error", lookupErr)
+	case found && (res.Code != want.Code || res.StartIP != want.StartIP || res.EndIP != want.EndIP):
+		db.logger.Error("invariant violation: cached result disagrees with index",
+			"ip", ipNum, "cached", res.Code, "index", want.Code)
+	}
+}
+
+// checkInvariants validates the cached answer for ipNum against the map after a lookup,
+// reporting violations through the logger. It is only called when debugAssertions is set.
+func (m *ExactIPCountryMap) checkInvariants(snap *mapSnapshot, ipNum uint32, res *Result, lookupErr error) {
+	code, found := snap.ipMap.get(ipNum)
+	switch {
+	case found != (lookupErr == nil):
+		m.logger.Error("invariant violation: lookup disagrees with map",
+			"ip", ipNum, "map_found", found, "error", lookupErr)
+	case found && res.Code != code:
+		m.logger.Error("invariant violation: cached result disagrees with map",
+			"ip", ipNum, "cached", res.Code, "map", code)
+	}
+}
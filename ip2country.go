@@ -12,21 +12,22 @@
 // from DB-IP: https://db-ip.com/db/format/ip-to-country/csv.html
 // This package is designed to parse its specific format: start_ip,end_ip,country_code
 //
-// Both implementations feature thread-safe operations, an in-memory LRU cache to
-// speed up repeated lookups, and on-demand reloading of the dataset.
+// Both implementations feature thread-safe operations, an in-memory cache with
+// selectable eviction policies to speed up repeated lookups, and on-demand reloading of the dataset.
 package ip2country
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"sort"
 	"time"
 )
 
-// IPCountryLookup defines the interface for IP to country lookup services.
-// It provides methods to get country information from an IP address string.
-type IPCountryLookup interface {
+// CountryReader is the read side of an IP to country lookup service. Code that only
+// resolves addresses, such as HTTP middleware, should depend on this interface.
+type CountryReader interface {
 	// GetCountry retrieves the country code for a given IP address string.
 	// In the current implementation, this returns the same value as GetCountryCode.
 	GetCountry(ipStr string) (string, error)
@@ -36,46 +37,321 @@ type IPCountryLookup interface {
 	GetCountryWithContext(ctx context.Context, ipStr string) (string, error)
 	// GetCountryCodeWithContext retrieves the country code, respecting the context.
 	GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error)
+}
+
+// DBAdmin is the operational side of an IP to country lookup service: monitoring,
+// reloading and shutdown.
+type DBAdmin interface {
 	// Stats returns the current operational statistics of the database.
 	Stats() Stats
-	// Reload clears the current dataset and loads it again from the source file.
+	// Reload loads the dataset again from the source, serving the current one until the
+	// new dataset is ready.
 	Reload() error
 	// ReloadWithContext reloads the dataset, respecting the context for cancellation.
 	ReloadWithContext(ctx context.Context) error
+	// Close stops background work and releases the dataset. Lookups and reloads after
+	// Close fail with ErrClosed.
+	Close() error
+}
+
+// IPCountryLookup defines the interface for IP to country lookup services.
+// It combines CountryReader and DBAdmin.
+type IPCountryLookup interface {
+	CountryReader
+	DBAdmin
 }
 
+// ErrClosed is returned by lookups and reloads on a database that has been closed.
+var ErrClosed = errors.New("lookup is closed")
+
 // Config holds configuration parameters for the IP lookup databases.
 // Fields are ordered for optimal memory alignment.
 type Config struct {
+	// Name identifies this instance in logs, Stats and error messages, so services
+	// running several databases (overrides, v4, v6) can tell them apart.
+	Name string
+	// Logger receives load and reload events. If nil, events are discarded.
+	Logger *slog.Logger
+	// Cache, if set, replaces the built-in lookup cache; CacheSize, CacheTTL,
+	// CacheMemoryBudgetBytes and CacheEviction are then ignored.
+	Cache Cache
+	// HitSink, if set, receives every lookup answered from the dataset or an override,
+	// for offline analysis of traffic by network and country. Lookups answered with
+	// DefaultCountryCode are not recorded. The sink is not closed by Close.
+	HitSink *HitSink
+	// OnCacheDegraded, if set together with CacheDegradedRatio, is called when the cache
+	// hit ratio over a window of CacheDegradedWindow lookups falls below
+	// CacheDegradedRatio, a sign of shifted traffic or an undersized cache. It is called
+	// once per degradation on its own goroutine, and again only after the ratio has
+	// recovered. A reload that clears the cache can trigger it.
+	OnCacheDegraded func(CacheDegradation)
+	// BeforeReload, if set, is called before every reload started by StartAutoReload or
+	// Watch, so a fleet can coordinate reloads instead of all instances reloading at
+	// once, e.g. by acquiring a distributed lock or checking a feature flag. If it returns
+	// an error, the reload is skipped and retried at the next interval; otherwise the
+	// returned release function, if not nil, is called when the reload finished. Reload
+	// and ReloadWithContext do not call it.
+	BeforeReload func(ctx context.Context) (release func(), err error)
+	// ProgressFunc, if set, is called while a dataset is parsed with the number of lines
+	// and bytes read so far, every 65536 lines and once more when the file has been
+	// read, so CLIs can show progress bars and services can emit load-phase metrics.
+	// bytesRead counts decompressed data. It is called on the loading goroutine and
+	// should return quickly.
+	ProgressFunc func(linesRead, bytesRead int64)
+	// NewRangeStore, if set, returns the RangeStore each load of a range dataset is
+	// parsed into, replacing the built-in storage, Config.SnapshotDir and
+	// Config.IndexStrategy; Stats.SearchStrategy is then "store". The store is the only
+	// copy of the dataset kept. Stores returned by NewSortedRangeStore are the built-in
+	// storage and keep Config.IndexStrategy. If nil, ranges are kept as by
+	// NewSortedRangeStore. Ignored by ExactIPCountryMap.
+	NewRangeStore func() RangeStore
+	// DefaultCountryCode, if set, is returned by lookups of valid addresses that match no
+	// range or entry, e.g. "ZZ" or "XX", instead of an error wrapping ErrNotFound, for
+	// callers that always want a string to log. Range lookups report the unmatched span
+	// around the address as the range.
+	DefaultCountryCode string
 	// Delimiter specifies the character used to separate fields in the CSV file.
 	Delimiter string
+	// LocationsFile is the local path of the GeoLite2 locations file used with
+	// FormatGeoLite2. If empty, GeoLite2-Country-Locations-en.csv next to the blocks file
+	// is used (for a FileSource or FSSource), or the *-Locations-en.csv entry when the
+	// blocks file is read from an archive.
+	LocationsFile string
+	// ArchivePattern selects the data file when the source is a .zip, .tar.gz or .tgz
+	// archive, matched against entry base names with path.Match. Defaults to "*.csv".
+	ArchivePattern string
+	// SnapshotDir, if set, makes IPCountryDB stream parsed ranges into a compact snapshot
+	// file in this directory and memory-map it instead of holding the dataset on the
+	// heap. The file is removed right after mapping and the mapping is released once
+	// the last lookup using it has finished, so a reload keeps peak memory near one
+	// dataset's size on hosts that cannot hold two. Only supported on Unix systems.
+	SnapshotDir string
 	// MaxFileSize limits the size of the file to be loaded, preventing excessive memory usage.
-	// The value is in bytes. A value of 0 or less means no limit.
+	// The value is in bytes and, for compressed files and archives, applies to the
+	// decompressed data. A value of 0 or less means no limit.
 	MaxFileSize int64
 	// MaxRanges sets the maximum number of IP ranges or entries to load from the file.
-	// A value of 0 or less means no limit.
+	// A value of 0 or less means no limit. Only IPv4 datasets are supported, so there are
+	// no separate limits per address family yet.
 	MaxRanges int
-	// CacheSize defines the number of entries to keep in the LRU cache.
-	// If set to 0 or less, a default value will be used.
+	// MaxStoredParseErrors limits how many rejected lines are kept with their content;
+	// further errors are only counted by kind in the ParseErrorSummary, so a badly
+	// malformed file cannot exhaust memory. A value of 0 or less means no limit.
+	MaxStoredParseErrors int
+	// MaxParseErrors aborts a load with ErrTooManyParseErrors once this many lines were
+	// rejected, so a corrupt file fails loudly instead of loading a fraction of the
+	// data. On a reload, the current dataset stays in service. A value of 0 or less
+	// means no limit.
+	MaxParseErrors int
+	// AnonymizedPrefixLen, if positive, treats every looked-up IPv4 address as a
+	// representative of its /AnonymizedPrefixLen block, e.g. 24 for addresses truncated
+	// with AnonymizeIP(ip, 24). A lookup succeeds only if the whole block lies within one
+	// range, so truncated addresses never resolve to a country that some of the original
+	// addresses did not have.
+	AnonymizedPrefixLen int
+	// RefreshInterval is how often StartAutoReload checks the source for a new dataset.
+	RefreshInterval time.Duration
+	// ReloadJitter, if positive, delays every reload started by StartAutoReload or Watch
+	// by a random duration of up to ReloadJitter, staggering the reloads of a fleet that
+	// sees a new dataset at the same time.
+	ReloadJitter time.Duration
+	// CacheTTL, if positive, expires cached hits and misses this long after they were
+	// stored, so neither is served forever. 0 keeps entries until they are evicted.
+	CacheTTL time.Duration
+	// InitCooldown is how long the initialization circuit breaker stays open before
+	// letting a lookup try to load again, see InitFailureThreshold. Defaults to 30
+	// seconds.
+	InitCooldown time.Duration
+	// ParseWorkers, if greater than 1, parses range datasets on this many goroutines
+	// while another one reads the file, cutting cold-start time on large files. The
+	// ranges and parse errors are the same as with a single goroutine. Ignored by
+	// ExactIPCountryMap.
+	ParseWorkers int
+	// InitFailureThreshold, if positive, opens a circuit breaker after this many
+	// consecutive failures to load the dataset before it was ever loaded. While it is
+	// open, lookups fail immediately with ErrInitCircuitOpen instead of each retrying
+	// the load against a broken source; after InitCooldown, a single lookup retries.
+	// Reload always tries, and any successful load closes the breaker. Its state is
+	// reported in Stats.InitCircuit.
+	InitFailureThreshold int
+	// CacheSize defines the number of entries to keep in the lookup cache.
+	// If set to 0 or less, a default value will be used. Caches of 2048 entries or more
+	// are split into up to 16 shards, each evicting within an even share of the entries,
+	// so concurrent lookups rarely wait for each other.
 	CacheSize int
+	// CacheDegradedWindow is the number of lookups over which the hit ratio is measured
+	// for OnCacheDegraded. If set to 0 or less, 10000 is used.
+	CacheDegradedWindow int
+	// SelfBenchmarkLookups, if positive, times this many random lookups after every
+	// load, before the dataset is put into service, and reports the result in
+	// Stats.SelfBenchmark. A few hundred thousand lookups take well under a second.
+	SelfBenchmarkLookups int
+	// CachePrefixLen, if between 1 and 31, caches lookups per /CachePrefixLen prefix
+	// instead of per address: the entry holds the matched range, so e.g. with 24 all
+	// addresses of 8.8.8.0/24 inside that range share one entry, which raises hit rates
+	// for clients clustered in subnets. Addresses outside the cached range are searched
+	// and replace the entry. Ignored by ExactIPCountryMap.
+	CachePrefixLen int
+	// NegativeCacheSize is the number of misses kept by the separate negative cache used
+	// with NegativeCacheSeparate. If set to 0 or less, a tenth of CacheSize is used.
+	NegativeCacheSize int
+	// CacheMemoryBudgetBytes caps the approximate memory held by the lookup cache,
+	// counting per-entry overhead plus the cached strings. When set, it is the primary
+	// limit and CacheSize still bounds the number of entries. A value of 0 or less
+	// disables the budget.
+	CacheMemoryBudgetBytes int64
+	// CacheDegradedRatio is the hit ratio, between 0 and 1, below which OnCacheDegraded
+	// is called. 0 disables the alarm.
+	CacheDegradedRatio float64
+	// Attribution, if set, is reported by Attribution instead of the provider metadata
+	// detected from the dataset's file name and bundled license files.
+	Attribution Attribution
+	// Groups defines named sets of country codes (e.g. "EEA", "FIVE_EYES") used by InGroup.
+	// Definitions can be loaded from a JSON file with LoadGroups. Built-in groups such as
+	// SanctionedGroup are always available and are replaced by a definition of the same name.
+	Groups map[string][]string
+	// CommentPrefixes lists the prefixes that mark comment lines in data files, such as
+	// annotations or metadata headers. Comment lines are skipped instead of being
+	// reported as parse errors. DefaultConfig sets "#" and ";".
+	CommentPrefixes []string
+	// Format selects the layout of range datasets loaded by IPCountryDB.
+	// The zero value, FormatDBIP, expects start_ip,end_ip,country_code and also accepts
+	// network,country_code lines with CIDR blocks.
+	Format Format
+	// CacheInvalidation controls which cached lookups are discarded on Reload.
+	// The zero value, CacheInvalidateAll, clears the whole cache.
+	CacheInvalidation CacheInvalidation
+	// CacheEviction selects which cached lookups are evicted first when the cache is full.
+	// The zero value, CacheEvictLRU, suits web traffic; CacheEvictTinyLFU resists
+	// scanning workloads such as log replays.
+	CacheEviction CacheEviction
+	// NegativeCaching controls how lookups that match no range are cached. The zero
+	// value, NegativeCacheShared, caches them alongside hits.
+	NegativeCaching NegativeCaching
+	// IPv4Parsing selects how lookups treat dotted addresses that are not in canonical
+	// form, such as "010.1.1.1" or "127.1". The zero value, IPv4ParseStrict, rejects them.
+	IPv4Parsing IPv4Parsing
+	// MultiCodePolicy selects which code of a range tagged with several codes, e.g.
+	// "UA/RU" for a disputed territory, becomes its Code; the others are reported as
+	// AlternateCodes. The zero value, MultiCodeFirst, picks the first listed code.
+	MultiCodePolicy MultiCodePolicy
+	// IndexStrategy selects how range datasets are searched. The zero value, IndexAuto,
+	// profiles every loaded dataset and picks a strategy, reported in
+	// Stats.SearchStrategy. Ignored by ExactIPCountryMap.
+	IndexStrategy IndexStrategy
+	// RejectIntegerIPs rejects lookups of addresses in integer form, e.g. "134744072" for
+	// 8.8.8.8, which are accepted by default for compatibility. Strict deployments set it
+	// so that arbitrary numbers passed by mistake fail with CodeInvalidIP.
+	RejectIntegerIPs bool
 	// SkipHeader indicates whether the first line of the CSV file should be skipped.
 	SkipHeader bool
+	// StrictParsing aborts a load with ErrTooManyParseErrors at the first rejected line,
+	// as MaxParseErrors of 1 does. Comment lines and blank lines are not errors.
+	StrictParsing bool
+	// DetectLayout inspects the first lines of every data file to detect the delimiter
+	// (comma, tab or semicolon) and whether the first line is a header, overriding
+	// Delimiter and SkipHeader, so files from different sources load without tuning.
+	// Delimiter is kept if none of these splits the first lines consistently.
+	DetectLayout bool
 }
 
+// CacheInvalidation selects which cache entries are invalidated when a dataset is reloaded.
+type CacheInvalidation int
+
+const (
+	// CacheInvalidateAll clears the entire cache on reload.
+	CacheInvalidateAll CacheInvalidation = iota
+	// CacheInvalidateNegative drops only cached misses, keeping cached hits.
+	// Hits whose country changed in the new dataset keep serving the old answer
+	// until they are evicted.
+	CacheInvalidateNegative
+	// CacheInvalidateChanged diffs the old and new datasets and drops only the entries
+	// whose answer differs, avoiding the latency spike of a cold cache after reload.
+	CacheInvalidateChanged
+)
+
+// NegativeCaching selects how lookups that match no range (misses) are cached.
+type NegativeCaching int
+
+const (
+	// NegativeCacheShared caches misses in the lookup cache, next to hits.
+	NegativeCacheShared NegativeCaching = iota
+	// NegativeCacheDisabled never caches misses, so a flood of random unroutable
+	// addresses cannot evict cached hits. Repeated misses always search the dataset.
+	NegativeCacheDisabled
+	// NegativeCacheSeparate keeps misses in a separate LRU cache of NegativeCacheSize
+	// entries, so floods of misses only evict other misses.
+	NegativeCacheSeparate
+)
+
+// IPv4Parsing selects how non-canonical dotted IPv4 addresses passed to lookups are
+// handled. Forms like "010.1.1.1" are ambiguous: older Go versions read the parts as
+// decimal, C libraries and browsers read a leading zero as octal, so lenient parsing
+// must pick one interpretation explicitly. Datasets are always parsed strictly.
+type IPv4Parsing int
+
+const (
+	// IPv4ParseStrict accepts only canonical dot-decimal addresses and fails other forms
+	// with CodeInvalidIP.
+	IPv4ParseStrict IPv4Parsing = iota
+	// IPv4ParseDecimal also accepts four decimal parts with leading zeros, normalizing
+	// "010.001.1.1" to 10.1.1.1.
+	IPv4ParseDecimal
+	// IPv4ParseInetAton accepts every form understood by inet_aton: parts with a leading
+	// 0 are octal and with a leading 0x hexadecimal, and shorthand forms with fewer parts
+	// fill the remaining bytes with the last part, so "010.1.1.1" is 8.1.1.1 and "127.1"
+	// is 127.0.0.1. It resolves an address the way a C client connecting to it would.
+	IPv4ParseInetAton
+)
+
+// MultiCodePolicy selects how IPCountryDB handles dataset ranges tagged with several
+// country codes separated by "/", as some feeds do for disputed territories.
+type MultiCodePolicy int
+
+const (
+	// MultiCodeFirst makes the first listed code the range's code.
+	MultiCodeFirst MultiCodePolicy = iota
+	// MultiCodeLast makes the last listed code the range's code.
+	MultiCodeLast
+	// MultiCodeReject rejects such lines as parse errors of kind ParseErrorMultiCode.
+	MultiCodeReject
+)
+
 // DefaultConfig returns a new Config with sensible default values.
 func DefaultConfig() Config {
 	return Config{
-		MaxRanges:   1000000,
-		MaxFileSize: 100 << 20, // 100 MB
-		SkipHeader:  false,
-		Delimiter:   ",",
-		CacheSize:   1000,
+		MaxRanges:            1000000,
+		MaxFileSize:          100 << 20, // 100 MB
+		MaxStoredParseErrors: 1000,
+		SkipHeader:           false,
+		Delimiter:            ",",
+		CommentPrefixes:      []string{"#", ";"},
+		CacheSize:            1000,
 	}
 }
 
 // Stats provides operational statistics for an IP lookup database.
 // Fields are ordered for optimal memory alignment.
 type Stats struct {
+	// Name is the instance name from Config.Name, suitable as a metrics label.
+	Name string `json:"name,omitempty"`
+	// Source identifies the live dataset, e.g. its file path or URL.
+	Source string `json:"source,omitempty"`
+	// Checksum is the hex-encoded SHA-256 of the dataset content as parsed, i.e. after
+	// decompression, so replicas can confirm they serve identical data.
+	Checksum string `json:"checksum,omitempty"`
+	// Provider is the data provider detected from the dataset, e.g. "DB-IP" or "MaxMind".
+	Provider string `json:"provider,omitempty"`
+	// SearchStrategy is the range search used for the dataset, e.g. "binary" or
+	// "jump_table", as chosen by Config.IndexStrategy, or "store" with
+	// Config.NewRangeStore. It is empty for exact-match maps.
+	SearchStrategy string `json:"search_strategy,omitempty"`
+	// InitCircuit is the state of the initialization circuit breaker: "closed", "open"
+	// or "half_open". It is empty unless Config.InitFailureThreshold is set.
+	InitCircuit string `json:"init_circuit,omitempty"`
+	// SelfBenchmark holds the lookup performance measured after the last load, if
+	// Config.SelfBenchmarkLookups is set.
+	SelfBenchmark *SelfBenchmark `json:"self_benchmark,omitempty"`
 	// LastUpdate is the timestamp of the last successful data load or reload.
 	LastUpdate time.Time `json:"last_update"`
 	// LoadTime is the duration it took to load the dataset.
@@ -86,10 +362,112 @@ type Stats struct {
 	CacheHits int64 `json:"cache_hits"`
 	// CacheMisses is the number of times a lookup was not found in the cache.
 	CacheMisses int64 `json:"cache_misses"`
+	// CacheBytes is the approximate memory held by cached entries.
+	CacheBytes int64 `json:"cache_bytes"`
+	// ParseErrors is the number of lines rejected by the last load.
+	ParseErrors int `json:"parse_errors"`
+	// InitFailures is the number of consecutive failed attempts to load the dataset
+	// counted by the initialization circuit breaker.
+	InitFailures int `json:"init_failures,omitempty"`
+	// RecoveredPanics is the number of panics converted into errors on lookup or reload.
+	RecoveredPanics int64 `json:"recovered_panics"`
 	// TotalRanges is the number of IP ranges or entries currently loaded.
 	TotalRanges int `json:"total_ranges"`
 }
 
+// newLogger returns the configured logger annotated with the instance name.
+func newLogger(cfg Config) *slog.Logger {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	if cfg.Name != "" {
+		logger = logger.With("db", cfg.Name)
+	}
+	return logger
+}
+
+// namedError prefixes err with the instance name, if one is configured.
+func namedError(name string, err error) error {
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// Result holds the outcome of a single lookup.
+// Fields are ordered for optimal memory alignment.
+//
+// A Result is owned by the caller. LookupInto-style methods only write into the *Result
+// they are given and never retain it, so a single value (or one taken from a sync.Pool)
+// can be reused across calls in hot loops without per-call allocations.
+type Result struct {
+	// Country is the country of the matched range (currently the same as Code).
+	Country string `json:"country"`
+	// Code is the two-letter country code.
+	Code string `json:"code"`
+	// AlternateCodes lists the other codes of a range tagged with several codes, such as
+	// a disputed territory, as chosen by Config.MultiCodePolicy. It is shared with the
+	// dataset and must not be modified.
+	AlternateCodes []string `json:"alternate_codes,omitempty"`
+	// StartIP is the first address of the matched range, as a 32-bit unsigned integer.
+	StartIP uint32 `json:"start_ip"`
+	// EndIP is the last address of the matched range, as a 32-bit unsigned integer.
+	EndIP uint32 `json:"end_ip"`
+}
+
+// Record is the full answer to a lookup, as returned by Lookup. New kinds of data are
+// added as Record fields, so callers that need more than a country code do not depend
+// on the string-returning methods growing new variants.
+// Fields are ordered for optimal memory alignment.
+type Record struct {
+	// Source describes the dataset that answered the lookup.
+	Source Metadata `json:"source"`
+	// LoadedAt is when that dataset was loaded.
+	LoadedAt time.Time `json:"loaded_at"`
+	// Country is the English name of the country, e.g. "Germany", or the code if it is
+	// not an ISO 3166-1 alpha-2 code.
+	Country string `json:"country"`
+	// Code is the two-letter country code.
+	Code string `json:"code"`
+	// Alpha3 is the ISO 3166-1 alpha-3 code, e.g. "DEU", if known.
+	Alpha3 string `json:"alpha3,omitempty"`
+	// Numeric is the ISO 3166-1 numeric code, e.g. "276", if known.
+	Numeric string `json:"numeric,omitempty"`
+	// Continent is the continent code, e.g. "EU", if known.
+	Continent string `json:"continent,omitempty"`
+	// Provider is the data provider detected from the dataset, e.g. "DB-IP", if known.
+	Provider string `json:"provider,omitempty"`
+	// AlternateCodes lists the other codes of a range tagged with several codes, such as
+	// a disputed territory.
+	AlternateCodes []string `json:"alternate_codes,omitempty"`
+	// StartIP is the first address of the matched range, as a 32-bit unsigned integer.
+	StartIP uint32 `json:"start_ip"`
+	// EndIP is the last address of the matched range, as a 32-bit unsigned integer.
+	EndIP uint32 `json:"end_ip"`
+}
+
+// newRecord builds the Record for a successful lookup.
+func newRecord(res *Result, source Metadata, provider string, loadedAt time.Time) *Record {
+	rec := &Record{
+		Source:         source,
+		LoadedAt:       loadedAt,
+		Country:        res.Code,
+		Code:           res.Code,
+		Continent:      ContinentForCountry(res.Code),
+		Provider:       provider,
+		AlternateCodes: res.AlternateCodes,
+		StartIP:        res.StartIP,
+		EndIP:          res.EndIP,
+	}
+	if info, ok := LookupCountry(res.Code); ok {
+		rec.Country = info.Name
+		rec.Alpha3 = info.Alpha3
+		rec.Numeric = info.Numeric
+	}
+	return rec
+}
+
 // IPRange represents a continuous range of IP addresses belonging to a single country.
 // Fields are ordered for optimal memory alignment.
 type IPRange struct {
@@ -97,6 +475,9 @@ type IPRange struct {
 	Country string `json:"country"`
 	// Code is the two-letter country code.
 	Code string `json:"code"`
+	// AlternateCodes lists the other codes of a range tagged with several codes, such as
+	// a disputed territory. See Config.MultiCodePolicy.
+	AlternateCodes []string `json:"alternate_codes,omitempty"`
 	// StartIP is the starting IP address of the range, as a 32-bit unsigned integer.
 	StartIP uint32 `json:"start_ip"`
 	// EndIP is the ending IP address of the range, as a 32-bit unsigned integer.
@@ -112,10 +493,10 @@ func (r IPRange) Contains(ip uint32) bool {
 // A range is valid if the start IP is not greater than the end IP and the code is not empty.
 func (r IPRange) Validate() error {
 	if r.StartIP > r.EndIP {
-		return fmt.Errorf("invalid range: start IP %d > end IP %d", r.StartIP, r.EndIP)
+		return fmt.Errorf("%w: start IP %d > end IP %d", errInvalidRange, r.StartIP, r.EndIP)
 	}
 	if r.Code == "" {
-		return fmt.Errorf("country code cannot be empty")
+		return errEmptyCode
 	}
 	return nil
 }
@@ -127,6 +508,8 @@ type ParseError struct {
 	Content string
 	// Err is the underlying error.
 	Err error
+	// Kind classifies the error, e.g. ParseErrorInvalidIP.
+	Kind ParseErrorKind
 	// Line is the line number where the error occurred.
 	Line int
 }
@@ -140,8 +523,12 @@ func (e ParseError) Error() string {
 type ParseResult struct {
 	// Ranges is the slice of successfully parsed IP ranges.
 	Ranges []IPRange
-	// Errors is a slice of errors encountered during parsing.
+	// Errors is a slice of errors encountered during parsing, capped at
+	// Config.MaxStoredParseErrors.
 	Errors []ParseError
+	// ErrorSummary counts all errors encountered during parsing by kind, including
+	// those beyond the cap.
+	ErrorSummary ParseErrorSummary
 	// Stats contains statistics about the parsing process.
 	Stats Stats
 }
@@ -192,19 +579,10 @@ func ParseCSVRanges(filePath string, config ...Config) (*ParseResult, error) {
 		cfg = config[0]
 	}
 
-	if cfg.MaxFileSize > 0 {
-		stat, err := os.Stat(filePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get file stats: %w", err)
-		}
-		if stat.Size() > cfg.MaxFileSize {
-			return nil, fmt.Errorf("file size %d exceeds limit %d", stat.Size(), cfg.MaxFileSize)
-		}
-	}
-
 	db := &IPCountryDB{
-		filePath: filePath,
-		config:   cfg,
+		source: &FileSource{Path: filePath, ArchivePattern: cfg.ArchivePattern},
+		config: cfg,
 	}
-	return db.parseFileWithContext(context.Background(), filePath)
+	result, _, err := db.parseSourceWithContext(context.Background(), db.source, nil)
+	return result, err
 }
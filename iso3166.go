@@ -0,0 +1,282 @@
+package ip2country
+
+import "strings"
+
+// CountryInfo describes an ISO 3166-1 country.
+type CountryInfo struct {
+	// Name is the short English name, e.g. "Germany".
+	Name string `json:"name"`
+	// Alpha2 is the two-letter code, e.g. "DE".
+	Alpha2 string `json:"alpha2"`
+	// Alpha3 is the three-letter code, e.g. "DEU".
+	Alpha3 string `json:"alpha3"`
+	// Numeric is the three-digit numeric code, e.g. "276".
+	Numeric string `json:"numeric"`
+}
+
+// countryInfos maps every officially assigned ISO 3166-1 alpha-2 code to its names and
+// alternative codes.
+var countryInfos = map[string]CountryInfo{
+	AD: {Name: "Andorra", Alpha2: AD, Alpha3: "AND", Numeric: "020"},
+	AE: {Name: "United Arab Emirates", Alpha2: AE, Alpha3: "ARE", Numeric: "784"},
+	AF: {Name: "Afghanistan", Alpha2: AF, Alpha3: "AFG", Numeric: "004"},
+	AG: {Name: "Antigua and Barbuda", Alpha2: AG, Alpha3: "ATG", Numeric: "028"},
+	AI: {Name: "Anguilla", Alpha2: AI, Alpha3: "AIA", Numeric: "660"},
+	AL: {Name: "Albania", Alpha2: AL, Alpha3: "ALB", Numeric: "008"},
+	AM: {Name: "Armenia", Alpha2: AM, Alpha3: "ARM", Numeric: "051"},
+	AO: {Name: "Angola", Alpha2: AO, Alpha3: "AGO", Numeric: "024"},
+	AQ: {Name: "Antarctica", Alpha2: AQ, Alpha3: "ATA", Numeric: "010"},
+	AR: {Name: "Argentina", Alpha2: AR, Alpha3: "ARG", Numeric: "032"},
+	AS: {Name: "American Samoa", Alpha2: AS, Alpha3: "ASM", Numeric: "016"},
+	AT: {Name: "Austria", Alpha2: AT, Alpha3: "AUT", Numeric: "040"},
+	AU: {Name: "Australia", Alpha2: AU, Alpha3: "AUS", Numeric: "036"},
+	AW: {Name: "Aruba", Alpha2: AW, Alpha3: "ABW", Numeric: "533"},
+	AX: {Name: "Åland Islands", Alpha2: AX, Alpha3: "ALA", Numeric: "248"},
+	AZ: {Name: "Azerbaijan", Alpha2: AZ, Alpha3: "AZE", Numeric: "031"},
+	BA: {Name: "Bosnia and Herzegovina", Alpha2: BA, Alpha3: "BIH", Numeric: "070"},
+	BB: {Name: "Barbados", Alpha2: BB, Alpha3: "BRB", Numeric: "052"},
+	BD: {Name: "Bangladesh", Alpha2: BD, Alpha3: "BGD", Numeric: "050"},
+	BE: {Name: "Belgium", Alpha2: BE, Alpha3: "BEL", Numeric: "056"},
+	BF: {Name: "Burkina Faso", Alpha2: BF, Alpha3: "BFA", Numeric: "854"},
+	BG: {Name: "Bulgaria", Alpha2: BG, Alpha3: "BGR", Numeric: "100"},
+	BH: {Name: "Bahrain", Alpha2: BH, Alpha3: "BHR", Numeric: "048"},
+	BI: {Name: "Burundi", Alpha2: BI, Alpha3: "BDI", Numeric: "108"},
+	BJ: {Name: "Benin", Alpha2: BJ, Alpha3: "BEN", Numeric: "204"},
+	BL: {Name: "Saint Barthélemy", Alpha2: BL, Alpha3: "BLM", Numeric: "652"},
+	BM: {Name: "Bermuda", Alpha2: BM, Alpha3: "BMU", Numeric: "060"},
+	BN: {Name: "Brunei Darussalam", Alpha2: BN, Alpha3: "BRN", Numeric: "096"},
+	BO: {Name: "Bolivia", Alpha2: BO, Alpha3: "BOL", Numeric: "068"},
+	BQ: {Name: "Bonaire, Sint Eustatius and Saba", Alpha2: BQ, Alpha3: "BES", Numeric: "535"},
+	BR: {Name: "Brazil", Alpha2: BR, Alpha3: "BRA", Numeric: "076"},
+	BS: {Name: "Bahamas", Alpha2: BS, Alpha3: "BHS", Numeric: "044"},
+	BT: {Name: "Bhutan", Alpha2: BT, Alpha3: "BTN", Numeric: "064"},
+	BV: {Name: "Bouvet Island", Alpha2: BV, Alpha3: "BVT", Numeric: "074"},
+	BW: {Name: "Botswana", Alpha2: BW, Alpha3: "BWA", Numeric: "072"},
+	BY: {Name: "Belarus", Alpha2: BY, Alpha3: "BLR", Numeric: "112"},
+	BZ: {Name: "Belize", Alpha2: BZ, Alpha3: "BLZ", Numeric: "084"},
+	CA: {Name: "Canada", Alpha2: CA, Alpha3: "CAN", Numeric: "124"},
+	CC: {Name: "Cocos (Keeling) Islands", Alpha2: CC, Alpha3: "CCK", Numeric: "166"},
+	CD: {Name: "Congo, Democratic Republic of the", Alpha2: CD, Alpha3: "COD", Numeric: "180"},
+	CF: {Name: "Central African Republic", Alpha2: CF, Alpha3: "CAF", Numeric: "140"},
+	CG: {Name: "Congo", Alpha2: CG, Alpha3: "COG", Numeric: "178"},
+	CH: {Name: "Switzerland", Alpha2: CH, Alpha3: "CHE", Numeric: "756"},
+	CI: {Name: "Côte d'Ivoire", Alpha2: CI, Alpha3: "CIV", Numeric: "384"},
+	CK: {Name: "Cook Islands", Alpha2: CK, Alpha3: "COK", Numeric: "184"},
+	CL: {Name: "Chile", Alpha2: CL, Alpha3: "CHL", Numeric: "152"},
+	CM: {Name: "Cameroon", Alpha2: CM, Alpha3: "CMR", Numeric: "120"},
+	CN: {Name: "China", Alpha2: CN, Alpha3: "CHN", Numeric: "156"},
+	CO: {Name: "Colombia", Alpha2: CO, Alpha3: "COL", Numeric: "170"},
+	CR: {Name: "Costa Rica", Alpha2: CR, Alpha3: "CRI", Numeric: "188"},
+	CU: {Name: "Cuba", Alpha2: CU, Alpha3: "CUB", Numeric: "192"},
+	CV: {Name: "Cabo Verde", Alpha2: CV, Alpha3: "CPV", Numeric: "132"},
+	CW: {Name: "Curaçao", Alpha2: CW, Alpha3: "CUW", Numeric: "531"},
+	CX: {Name: "Christmas Island", Alpha2: CX, Alpha3: "CXR", Numeric: "162"},
+	CY: {Name: "Cyprus", Alpha2: CY, Alpha3: "CYP", Numeric: "196"},
+	CZ: {Name: "Czechia", Alpha2: CZ, Alpha3: "CZE", Numeric: "203"},
+	DE: {Name: "Germany", Alpha2: DE, Alpha3: "DEU", Numeric: "276"},
+	DJ: {Name: "Djibouti", Alpha2: DJ, Alpha3: "DJI", Numeric: "262"},
+	DK: {Name: "Denmark", Alpha2: DK, Alpha3: "DNK", Numeric: "208"},
+	DM: {Name: "Dominica", Alpha2: DM, Alpha3: "DMA", Numeric: "212"},
+	DO: {Name: "Dominican Republic", Alpha2: DO, Alpha3: "DOM", Numeric: "214"},
+	DZ: {Name: "Algeria", Alpha2: DZ, Alpha3: "DZA", Numeric: "012"},
+	EC: {Name: "Ecuador", Alpha2: EC, Alpha3: "ECU", Numeric: "218"},
+	EE: {Name: "Estonia", Alpha2: EE, Alpha3: "EST", Numeric: "233"},
+	EG: {Name: "Egypt", Alpha2: EG, Alpha3: "EGY", Numeric: "818"},
+	EH: {Name: "Western Sahara", Alpha2: EH, Alpha3: "ESH", Numeric: "732"},
+	ER: {Name: "Eritrea", Alpha2: ER, Alpha3: "ERI", Numeric: "232"},
+	ES: {Name: "Spain", Alpha2: ES, Alpha3: "ESP", Numeric: "724"},
+	ET: {Name: "Ethiopia", Alpha2: ET, Alpha3: "ETH", Numeric: "231"},
+	FI: {Name: "Finland", Alpha2: FI, Alpha3: "FIN", Numeric: "246"},
+	FJ: {Name: "Fiji", Alpha2: FJ, Alpha3: "FJI", Numeric: "242"},
+	FK: {Name: "Falkland Islands (Malvinas)", Alpha2: FK, Alpha3: "FLK", Numeric: "238"},
+	FM: {Name: "Micronesia", Alpha2: FM, Alpha3: "FSM", Numeric: "583"},
+	FO: {Name: "Faroe Islands", Alpha2: FO, Alpha3: "FRO", Numeric: "234"},
+	FR: {Name: "France", Alpha2: FR, Alpha3: "FRA", Numeric: "250"},
+	GA: {Name: "Gabon", Alpha2: GA, Alpha3: "GAB", Numeric: "266"},
+	GB: {Name: "United Kingdom", Alpha2: GB, Alpha3: "GBR", Numeric: "826"},
+	GD: {Name: "Grenada", Alpha2: GD, Alpha3: "GRD", Numeric: "308"},
+	GE: {Name: "Georgia", Alpha2: GE, Alpha3: "GEO", Numeric: "268"},
+	GF: {Name: "French Guiana", Alpha2: GF, Alpha3: "GUF", Numeric: "254"},
+	GG: {Name: "Guernsey", Alpha2: GG, Alpha3: "GGY", Numeric: "831"},
+	GH: {Name: "Ghana", Alpha2: GH, Alpha3: "GHA", Numeric: "288"},
+	GI: {Name: "Gibraltar", Alpha2: GI, Alpha3: "GIB", Numeric: "292"},
+	GL: {Name: "Greenland", Alpha2: GL, Alpha3: "GRL", Numeric: "304"},
+	GM: {Name: "Gambia", Alpha2: GM, Alpha3: "GMB", Numeric: "270"},
+	GN: {Name: "Guinea", Alpha2: GN, Alpha3: "GIN", Numeric: "324"},
+	GP: {Name: "Guadeloupe", Alpha2: GP, Alpha3: "GLP", Numeric: "312"},
+	GQ: {Name: "Equatorial Guinea", Alpha2: GQ, Alpha3: "GNQ", Numeric: "226"},
+	GR: {Name: "Greece", Alpha2: GR, Alpha3: "GRC", Numeric: "300"},
+	GS: {Name: "South Georgia and the South Sandwich Islands", Alpha2: GS, Alpha3: "SGS", Numeric: "239"},
+	GT: {Name: "Guatemala", Alpha2: GT, Alpha3: "GTM", Numeric: "320"},
+	GU: {Name: "Guam", Alpha2: GU, Alpha3: "GUM", Numeric: "316"},
+	GW: {Name: "Guinea-Bissau", Alpha2: GW, Alpha3: "GNB", Numeric: "624"},
+	GY: {Name: "Guyana", Alpha2: GY, Alpha3: "GUY", Numeric: "328"},
+	HK: {Name: "Hong Kong", Alpha2: HK, Alpha3: "HKG", Numeric: "344"},
+	HM: {Name: "Heard Island and McDonald Islands", Alpha2: HM, Alpha3: "HMD", Numeric: "334"},
+	HN: {Name: "Honduras", Alpha2: HN, Alpha3: "HND", Numeric: "340"},
+	HR: {Name: "Croatia", Alpha2: HR, Alpha3: "HRV", Numeric: "191"},
+	HT: {Name: "Haiti", Alpha2: HT, Alpha3: "HTI", Numeric: "332"},
+	HU: {Name: "Hungary", Alpha2: HU, Alpha3: "HUN", Numeric: "348"},
+	ID: {Name: "Indonesia", Alpha2: ID, Alpha3: "IDN", Numeric: "360"},
+	IE: {Name: "Ireland", Alpha2: IE, Alpha3: "IRL", Numeric: "372"},
+	IL: {Name: "Israel", Alpha2: IL, Alpha3: "ISR", Numeric: "376"},
+	IM: {Name: "Isle of Man", Alpha2: IM, Alpha3: "IMN", Numeric: "833"},
+	IN: {Name: "India", Alpha2: IN, Alpha3: "IND", Numeric: "356"},
+	IO: {Name: "British Indian Ocean Territory", Alpha2: IO, Alpha3: "IOT", Numeric: "086"},
+	IQ: {Name: "Iraq", Alpha2: IQ, Alpha3: "IRQ", Numeric: "368"},
+	IR: {Name: "Iran", Alpha2: IR, Alpha3: "IRN", Numeric: "364"},
+	IS: {Name: "Iceland", Alpha2: IS, Alpha3: "ISL", Numeric: "352"},
+	IT: {Name: "Italy", Alpha2: IT, Alpha3: "ITA", Numeric: "380"},
+	JE: {Name: "Jersey", Alpha2: JE, Alpha3: "JEY", Numeric: "832"},
+	JM: {Name: "Jamaica", Alpha2: JM, Alpha3: "JAM", Numeric: "388"},
+	JO: {Name: "Jordan", Alpha2: JO, Alpha3: "JOR", Numeric: "400"},
+	JP: {Name: "Japan", Alpha2: JP, Alpha3: "JPN", Numeric: "392"},
+	KE: {Name: "Kenya", Alpha2: KE, Alpha3: "KEN", Numeric: "404"},
+	KG: {Name: "Kyrgyzstan", Alpha2: KG, Alpha3: "KGZ", Numeric: "417"},
+	KH: {Name: "Cambodia", Alpha2: KH, Alpha3: "KHM", Numeric: "116"},
+	KI: {Name: "Kiribati", Alpha2: KI, Alpha3: "KIR", Numeric: "296"},
+	KM: {Name: "Comoros", Alpha2: KM, Alpha3: "COM", Numeric: "174"},
+	KN: {Name: "Saint Kitts and Nevis", Alpha2: KN, Alpha3: "KNA", Numeric: "659"},
+	KP: {Name: "Korea, Democratic People's Republic of", Alpha2: KP, Alpha3: "PRK", Numeric: "408"},
+	KR: {Name: "Korea, Republic of", Alpha2: KR, Alpha3: "KOR", Numeric: "410"},
+	KW: {Name: "Kuwait", Alpha2: KW, Alpha3: "KWT", Numeric: "414"},
+	KY: {Name: "Cayman Islands", Alpha2: KY, Alpha3: "CYM", Numeric: "136"},
+	KZ: {Name: "Kazakhstan", Alpha2: KZ, Alpha3: "KAZ", Numeric: "398"},
+	LA: {Name: "Lao People's Democratic Republic", Alpha2: LA, Alpha3: "LAO", Numeric: "418"},
+	LB: {Name: "Lebanon", Alpha2: LB, Alpha3: "LBN", Numeric: "422"},
+	LC: {Name: "Saint Lucia", Alpha2: LC, Alpha3: "LCA", Numeric: "662"},
+	LI: {Name: "Liechtenstein", Alpha2: LI, Alpha3: "LIE", Numeric: "438"},
+	LK: {Name: "Sri Lanka", Alpha2: LK, Alpha3: "LKA", Numeric: "144"},
+	LR: {Name: "Liberia", Alpha2: LR, Alpha3: "LBR", Numeric: "430"},
+	LS: {Name: "Lesotho", Alpha2: LS, Alpha3: "LSO", Numeric: "426"},
+	LT: {Name: "Lithuania", Alpha2: LT, Alpha3: "LTU", Numeric: "440"},
+	LU: {Name: "Luxembourg", Alpha2: LU, Alpha3: "LUX", Numeric: "442"},
+	LV: {Name: "Latvia", Alpha2: LV, Alpha3: "LVA", Numeric: "428"},
+	LY: {Name: "Libya", Alpha2: LY, Alpha3: "LBY", Numeric: "434"},
+	MA: {Name: "Morocco", Alpha2: MA, Alpha3: "MAR", Numeric: "504"},
+	MC: {Name: "Monaco", Alpha2: MC, Alpha3: "MCO", Numeric: "492"},
+	MD: {Name: "Moldova", Alpha2: MD, Alpha3: "MDA", Numeric: "498"},
+	ME: {Name: "Montenegro", Alpha2: ME, Alpha3: "MNE", Numeric: "499"},
+	MF: {Name: "Saint Martin (French part)", Alpha2: MF, Alpha3: "MAF", Numeric: "663"},
+	MG: {Name: "Madagascar", Alpha2: MG, Alpha3: "MDG", Numeric: "450"},
+	MH: {Name: "Marshall Islands", Alpha2: MH, Alpha3: "MHL", Numeric: "584"},
+	MK: {Name: "North Macedonia", Alpha2: MK, Alpha3: "MKD", Numeric: "807"},
+	ML: {Name: "Mali", Alpha2: ML, Alpha3: "MLI", Numeric: "466"},
+	MM: {Name: "Myanmar", Alpha2: MM, Alpha3: "MMR", Numeric: "104"},
+	MN: {Name: "Mongolia", Alpha2: MN, Alpha3: "MNG", Numeric: "496"},
+	MO: {Name: "Macao", Alpha2: MO, Alpha3: "MAC", Numeric: "446"},
+	MP: {Name: "Northern Mariana Islands", Alpha2: MP, Alpha3: "MNP", Numeric: "580"},
+	MQ: {Name: "Martinique", Alpha2: MQ, Alpha3: "MTQ", Numeric: "474"},
+	MR: {Name: "Mauritania", Alpha2: MR, Alpha3: "MRT", Numeric: "478"},
+	MS: {Name: "Montserrat", Alpha2: MS, Alpha3: "MSR", Numeric: "500"},
+	MT: {Name: "Malta", Alpha2: MT, Alpha3: "MLT", Numeric: "470"},
+	MU: {Name: "Mauritius", Alpha2: MU, Alpha3: "MUS", Numeric: "480"},
+	MV: {Name: "Maldives", Alpha2: MV, Alpha3: "MDV", Numeric: "462"},
+	MW: {Name: "Malawi", Alpha2: MW, Alpha3: "MWI", Numeric: "454"},
+	MX: {Name: "Mexico", Alpha2: MX, Alpha3: "MEX", Numeric: "484"},
+	MY: {Name: "Malaysia", Alpha2: MY, Alpha3: "MYS", Numeric: "458"},
+	MZ: {Name: "Mozambique", Alpha2: MZ, Alpha3: "MOZ", Numeric: "508"},
+	NA: {Name: "Namibia", Alpha2: NA, Alpha3: "NAM", Numeric: "516"},
+	NC: {Name: "New Caledonia", Alpha2: NC, Alpha3: "NCL", Numeric: "540"},
+	NE: {Name: "Niger", Alpha2: NE, Alpha3: "NER", Numeric: "562"},
+	NF: {Name: "Norfolk Island", Alpha2: NF, Alpha3: "NFK", Numeric: "574"},
+	NG: {Name: "Nigeria", Alpha2: NG, Alpha3: "NGA", Numeric: "566"},
+	NI: {Name: "Nicaragua", Alpha2: NI, Alpha3: "NIC", Numeric: "558"},
+	NL: {Name: "Netherlands", Alpha2: NL, Alpha3: "NLD", Numeric: "528"},
+	NO: {Name: "Norway", Alpha2: NO, Alpha3: "NOR", Numeric: "578"},
+	NP: {Name: "Nepal", Alpha2: NP, Alpha3: "NPL", Numeric: "524"},
+	NR: {Name: "Nauru", Alpha2: NR, Alpha3: "NRU", Numeric: "520"},
+	NU: {Name: "Niue", Alpha2: NU, Alpha3: "NIU", Numeric: "570"},
+	NZ: {Name: "New Zealand", Alpha2: NZ, Alpha3: "NZL", Numeric: "554"},
+	OM: {Name: "Oman", Alpha2: OM, Alpha3: "OMN", Numeric: "512"},
+	PA: {Name: "Panama", Alpha2: PA, Alpha3: "PAN", Numeric: "591"},
+	PE: {Name: "Peru", Alpha2: PE, Alpha3: "PER", Numeric: "604"},
+	PF: {Name: "French Polynesia", Alpha2: PF, Alpha3: "PYF", Numeric: "258"},
+	PG: {Name: "Papua New Guinea", Alpha2: PG, Alpha3: "PNG", Numeric: "598"},
+	PH: {Name: "Philippines", Alpha2: PH, Alpha3: "PHL", Numeric: "608"},
+	PK: {Name: "Pakistan", Alpha2: PK, Alpha3: "PAK", Numeric: "586"},
+	PL: {Name: "Poland", Alpha2: PL, Alpha3: "POL", Numeric: "616"},
+	PM: {Name: "Saint Pierre and Miquelon", Alpha2: PM, Alpha3: "SPM", Numeric: "666"},
+	PN: {Name: "Pitcairn", Alpha2: PN, Alpha3: "PCN", Numeric: "612"},
+	PR: {Name: "Puerto Rico", Alpha2: PR, Alpha3: "PRI", Numeric: "630"},
+	PS: {Name: "Palestine, State of", Alpha2: PS, Alpha3: "PSE", Numeric: "275"},
+	PT: {Name: "Portugal", Alpha2: PT, Alpha3: "PRT", Numeric: "620"},
+	PW: {Name: "Palau", Alpha2: PW, Alpha3: "PLW", Numeric: "585"},
+	PY: {Name: "Paraguay", Alpha2: PY, Alpha3: "PRY", Numeric: "600"},
+	QA: {Name: "Qatar", Alpha2: QA, Alpha3: "QAT", Numeric: "634"},
+	RE: {Name: "Réunion", Alpha2: RE, Alpha3: "REU", Numeric: "638"},
+	RO: {Name: "Romania", Alpha2: RO, Alpha3: "ROU", Numeric: "642"},
+	RS: {Name: "Serbia", Alpha2: RS, Alpha3: "SRB", Numeric: "688"},
+	RU: {Name: "Russian Federation", Alpha2: RU, Alpha3: "RUS", Numeric: "643"},
+	RW: {Name: "Rwanda", Alpha2: RW, Alpha3: "RWA", Numeric: "646"},
+	SA: {Name: "Saudi Arabia", Alpha2: SA, Alpha3: "SAU", Numeric: "682"},
+	SB: {Name: "Solomon Islands", Alpha2: SB, Alpha3: "SLB", Numeric: "090"},
+	SC: {Name: "Seychelles", Alpha2: SC, Alpha3: "SYC", Numeric: "690"},
+	SD: {Name: "Sudan", Alpha2: SD, Alpha3: "SDN", Numeric: "729"},
+	SE: {Name: "Sweden", Alpha2: SE, Alpha3: "SWE", Numeric: "752"},
+	SG: {Name: "Singapore", Alpha2: SG, Alpha3: "SGP", Numeric: "702"},
+	SH: {Name: "Saint Helena, Ascension and Tristan da Cunha", Alpha2: SH, Alpha3: "SHN", Numeric: "654"},
+	SI: {Name: "Slovenia", Alpha2: SI, Alpha3: "SVN", Numeric: "705"},
+	SJ: {Name: "Svalbard and Jan Mayen", Alpha2: SJ, Alpha3: "SJM", Numeric: "744"},
+	SK: {Name: "Slovakia", Alpha2: SK, Alpha3: "SVK", Numeric: "703"},
+	SL: {Name: "Sierra Leone", Alpha2: SL, Alpha3: "SLE", Numeric: "694"},
+	SM: {Name: "San Marino", Alpha2: SM, Alpha3: "SMR", Numeric: "674"},
+	SN: {Name: "Senegal", Alpha2: SN, Alpha3: "SEN", Numeric: "686"},
+	SO: {Name: "Somalia", Alpha2: SO, Alpha3: "SOM", Numeric: "706"},
+	SR: {Name: "Suriname", Alpha2: SR, Alpha3: "SUR", Numeric: "740"},
+	SS: {Name: "South Sudan", Alpha2: SS, Alpha3: "SSD", Numeric: "728"},
+	ST: {Name: "Sao Tome and Principe", Alpha2: ST, Alpha3: "STP", Numeric: "678"},
+	SV: {Name: "El Salvador", Alpha2: SV, Alpha3: "SLV", Numeric: "222"},
+	SX: {Name: "Sint Maarten (Dutch part)", Alpha2: SX, Alpha3: "SXM", Numeric: "534"},
+	SY: {Name: "Syrian Arab Republic", Alpha2: SY, Alpha3: "SYR", Numeric: "760"},
+	SZ: {Name: "Eswatini", Alpha2: SZ, Alpha3: "SWZ", Numeric: "748"},
+	TC: {Name: "Turks and Caicos Islands", Alpha2: TC, Alpha3: "TCA", Numeric: "796"},
+	TD: {Name: "Chad", Alpha2: TD, Alpha3: "TCD", Numeric: "148"},
+	TF: {Name: "French Southern Territories", Alpha2: TF, Alpha3: "ATF", Numeric: "260"},
+	TG: {Name: "Togo", Alpha2: TG, Alpha3: "TGO", Numeric: "768"},
+	TH: {Name: "Thailand", Alpha2: TH, Alpha3: "THA", Numeric: "764"},
+	TJ: {Name: "Tajikistan", Alpha2: TJ, Alpha3: "TJK", Numeric: "762"},
+	TK: {Name: "Tokelau", Alpha2: TK, Alpha3: "TKL", Numeric: "772"},
+	TL: {Name: "Timor-Leste", Alpha2: TL, Alpha3: "TLS", Numeric: "626"},
+	TM: {Name: "Turkmenistan", Alpha2: TM, Alpha3: "TKM", Numeric: "795"},
+	TN: {Name: "Tunisia", Alpha2: TN, Alpha3: "TUN", Numeric: "788"},
+	TO: {Name: "Tonga", Alpha2: TO, Alpha3: "TON", Numeric: "776"},
+	TR: {Name: "Türkiye", Alpha2: TR, Alpha3: "TUR", Numeric: "792"},
+	TT: {Name: "Trinidad and Tobago", Alpha2: TT, Alpha3: "TTO", Numeric: "780"},
+	TV: {Name: "Tuvalu", Alpha2: TV, Alpha3: "TUV", Numeric: "798"},
+	TW: {Name: "Taiwan", Alpha2: TW, Alpha3: "TWN", Numeric: "158"},
+	TZ: {Name: "Tanzania", Alpha2: TZ, Alpha3: "TZA", Numeric: "834"},
+	UA: {Name: "Ukraine", Alpha2: UA, Alpha3: "UKR", Numeric: "804"},
+	UG: {Name: "Uganda", Alpha2: UG, Alpha3: "UGA", Numeric: "800"},
+	UM: {Name: "United States Minor Outlying Islands", Alpha2: UM, Alpha3: "UMI", Numeric: "581"},
+	US: {Name: "United States", Alpha2: US, Alpha3: "USA", Numeric: "840"},
+	UY: {Name: "Uruguay", Alpha2: UY, Alpha3: "URY", Numeric: "858"},
+	UZ: {Name: "Uzbekistan", Alpha2: UZ, Alpha3: "UZB", Numeric: "860"},
+	VA: {Name: "Holy See", Alpha2: VA, Alpha3: "VAT", Numeric: "336"},
+	VC: {Name: "Saint Vincent and the Grenadines", Alpha2: VC, Alpha3: "VCT", Numeric: "670"},
+	VE: {Name: "Venezuela", Alpha2: VE, Alpha3: "VEN", Numeric: "862"},
+	VG: {Name: "Virgin Islands (British)", Alpha2: VG, Alpha3: "VGB", Numeric: "092"},
+	VI: {Name: "Virgin Islands (U.S.)", Alpha2: VI, Alpha3: "VIR", Numeric: "850"},
+	VN: {Name: "Viet Nam", Alpha2: VN, Alpha3: "VNM", Numeric: "704"},
+	VU: {Name: "Vanuatu", Alpha2: VU, Alpha3: "VUT", Numeric: "548"},
+	WF: {Name: "Wallis and Futuna", Alpha2: WF, Alpha3: "WLF", Numeric: "876"},
+	WS: {Name: "Samoa", Alpha2: WS, Alpha3: "WSM", Numeric: "882"},
+	YE: {Name: "Yemen", Alpha2: YE, Alpha3: "YEM", Numeric: "887"},
+	YT: {Name: "Mayotte", Alpha2: YT, Alpha3: "MYT", Numeric: "175"},
+	ZA: {Name: "South Africa", Alpha2: ZA, Alpha3: "ZAF", Numeric: "710"},
+	ZM: {Name: "Zambia", Alpha2: ZM, Alpha3: "ZMB", Numeric: "894"},
+	ZW: {Name: "Zimbabwe", Alpha2: ZW, Alpha3: "ZWE", Numeric: "716"},
+}
+
+// LookupCountry returns the ISO 3166-1 metadata for an alpha-2 code. The comparison is
+// case-insensitive.
+func LookupCountry(code string) (CountryInfo, bool) {
+	info, ok := countryInfos[strings.ToUpper(code)]
+	return info, ok
+}
+
+// CountryName returns the English name of the country with the given alpha-2 code, e.g.
+// "Germany" for "DE", or an empty string if the code is not assigned.
+func CountryName(code string) string {
+	return countryInfos[strings.ToUpper(code)].Name
+}
@@ -0,0 +1,88 @@
+package ip2country
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// layoutSniffSize is the number of bytes inspected by Config.DetectLayout.
+const layoutSniffSize = 16 << 10
+
+// layoutDelimiters are the delimiters recognized by Config.DetectLayout, in order of
+// preference.
+var layoutDelimiters = []string{",", "\t", ";"}
+
+// lineLayout is the delimiter and header setting used to parse a data file.
+type lineLayout struct {
+	delimiter  string
+	skipHeader bool
+}
+
+// detectLayout returns the layout of the data read from r: Config.Delimiter and
+// Config.SkipHeader, or, with Config.DetectLayout, the layout detected from the first
+// lines. The returned reader must be read instead of r.
+func detectLayout(r io.Reader, cfg *Config) (io.Reader, lineLayout) {
+	layout := lineLayout{delimiter: cfg.Delimiter, skipHeader: cfg.SkipHeader}
+	if !cfg.DetectLayout {
+		return r, layout
+	}
+	br := bufio.NewReaderSize(r, layoutSniffSize)
+	data, err := br.Peek(layoutSniffSize)
+	text := string(data)
+	if err == nil {
+		// The last line may be cut off; leave it out.
+		if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !isComment(line, cfg.CommentPrefixes) {
+			lines = append(lines, line)
+		}
+		if len(lines) == sniffLines {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return br, layout
+	}
+
+	for _, delimiter := range layoutDelimiters {
+		if consistentFields(lines, delimiter) {
+			layout.delimiter = delimiter
+			break
+		}
+	}
+	layout.skipHeader = !startsWithAddress(lines[0], layout.delimiter) &&
+		(len(lines) == 1 || startsWithAddress(lines[1], layout.delimiter))
+	return br, layout
+}
+
+// consistentFields reports whether delimiter splits every line into the same number of
+// fields, at least two.
+func consistentFields(lines []string, delimiter string) bool {
+	count := 0
+	for _, line := range lines {
+		fields, err := splitFields(line, delimiter)
+		if err != nil || len(fields) < 2 || (count != 0 && len(fields) != count) {
+			return false
+		}
+		count = len(fields)
+	}
+	return true
+}
+
+// startsWithAddress reports whether the first field of line is an address or network,
+// which a header line never is.
+func startsWithAddress(line, delimiter string) bool {
+	fields, err := splitFields(line, delimiter)
+	if err != nil || len(fields) == 0 {
+		return false
+	}
+	_, err = parseIP(strings.TrimSpace(strings.SplitN(fields[0], "/", 2)[0]))
+	return err == nil
+}
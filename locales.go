@@ -0,0 +1,154 @@
+package ip2country
+
+import "strings"
+
+// countryLocales maps ISO 3166-1 alpha-2 country codes to the BCP 47 locales most
+// likely preferred by users in that country, ordered from most to least common.
+var countryLocales = map[string][]string{
+	"AD": {"ca-AD", "es-AD", "fr-AD"},
+	"AE": {"ar-AE", "en-AE"},
+	"AF": {"ps-AF", "fa-AF"},
+	"AL": {"sq-AL"},
+	"AM": {"hy-AM", "ru-AM"},
+	"AO": {"pt-AO"},
+	"AR": {"es-AR"},
+	"AT": {"de-AT"},
+	"AU": {"en-AU"},
+	"AZ": {"az-AZ", "ru-AZ"},
+	"BA": {"bs-BA", "hr-BA", "sr-BA"},
+	"BD": {"bn-BD", "en-BD"},
+	"BE": {"nl-BE", "fr-BE", "de-BE"},
+	"BG": {"bg-BG"},
+	"BH": {"ar-BH", "en-BH"},
+	"BO": {"es-BO", "qu-BO"},
+	"BR": {"pt-BR"},
+	"BY": {"be-BY", "ru-BY"},
+	"CA": {"en-CA", "fr-CA"},
+	"CH": {"de-CH", "fr-CH", "it-CH"},
+	"CL": {"es-CL"},
+	"CN": {"zh-CN"},
+	"CO": {"es-CO"},
+	"CR": {"es-CR"},
+	"CU": {"es-CU"},
+	"CY": {"el-CY", "tr-CY", "en-CY"},
+	"CZ": {"cs-CZ"},
+	"DE": {"de-DE"},
+	"DK": {"da-DK"},
+	"DO": {"es-DO"},
+	"DZ": {"ar-DZ", "fr-DZ"},
+	"EC": {"es-EC"},
+	"EE": {"et-EE", "ru-EE"},
+	"EG": {"ar-EG"},
+	"ES": {"es-ES", "ca-ES", "gl-ES", "eu-ES"},
+	"ET": {"am-ET"},
+	"FI": {"fi-FI", "sv-FI"},
+	"FR": {"fr-FR"},
+	"GB": {"en-GB"},
+	"GE": {"ka-GE"},
+	"GH": {"en-GH"},
+	"GR": {"el-GR"},
+	"GT": {"es-GT"},
+	"HK": {"zh-HK", "en-HK"},
+	"HN": {"es-HN"},
+	"HR": {"hr-HR"},
+	"HU": {"hu-HU"},
+	"ID": {"id-ID"},
+	"IE": {"en-IE", "ga-IE"},
+	"IL": {"he-IL", "ar-IL"},
+	"IN": {"hi-IN", "en-IN", "bn-IN", "te-IN", "ta-IN"},
+	"IQ": {"ar-IQ", "ku-IQ"},
+	"IR": {"fa-IR"},
+	"IS": {"is-IS"},
+	"IT": {"it-IT"},
+	"JM": {"en-JM"},
+	"JO": {"ar-JO"},
+	"JP": {"ja-JP"},
+	"KE": {"sw-KE", "en-KE"},
+	"KG": {"ky-KG", "ru-KG"},
+	"KH": {"km-KH"},
+	"KR": {"ko-KR"},
+	"KW": {"ar-KW"},
+	"KZ": {"kk-KZ", "ru-KZ"},
+	"LA": {"lo-LA"},
+	"LB": {"ar-LB", "fr-LB"},
+	"LK": {"si-LK", "ta-LK"},
+	"LT": {"lt-LT"},
+	"LU": {"lb-LU", "fr-LU", "de-LU"},
+	"LV": {"lv-LV", "ru-LV"},
+	"LY": {"ar-LY"},
+	"MA": {"ar-MA", "fr-MA"},
+	"MD": {"ro-MD", "ru-MD"},
+	"ME": {"sr-ME"},
+	"MK": {"mk-MK"},
+	"MM": {"my-MM"},
+	"MN": {"mn-MN"},
+	"MT": {"mt-MT", "en-MT"},
+	"MX": {"es-MX"},
+	"MY": {"ms-MY", "en-MY", "zh-MY"},
+	"NG": {"en-NG", "ha-NG", "yo-NG"},
+	"NI": {"es-NI"},
+	"NL": {"nl-NL"},
+	"NO": {"nb-NO", "nn-NO"},
+	"NP": {"ne-NP"},
+	"NZ": {"en-NZ", "mi-NZ"},
+	"OM": {"ar-OM"},
+	"PA": {"es-PA"},
+	"PE": {"es-PE", "qu-PE"},
+	"PH": {"en-PH", "fil-PH"},
+	"PK": {"ur-PK", "en-PK"},
+	"PL": {"pl-PL"},
+	"PR": {"es-PR", "en-PR"},
+	"PT": {"pt-PT"},
+	"PY": {"es-PY", "gn-PY"},
+	"QA": {"ar-QA"},
+	"RO": {"ro-RO"},
+	"RS": {"sr-RS"},
+	"RU": {"ru-RU"},
+	"SA": {"ar-SA"},
+	"SE": {"sv-SE"},
+	"SG": {"en-SG", "zh-SG", "ms-SG", "ta-SG"},
+	"SI": {"sl-SI"},
+	"SK": {"sk-SK"},
+	"SN": {"fr-SN", "wo-SN"},
+	"SV": {"es-SV"},
+	"SY": {"ar-SY"},
+	"TH": {"th-TH"},
+	"TJ": {"tg-TJ", "ru-TJ"},
+	"TM": {"tk-TM", "ru-TM"},
+	"TN": {"ar-TN", "fr-TN"},
+	"TR": {"tr-TR"},
+	"TW": {"zh-TW"},
+	"TZ": {"sw-TZ", "en-TZ"},
+	"UA": {"uk-UA", "ru-UA"},
+	"UG": {"en-UG", "sw-UG"},
+	"US": {"en-US", "es-US"},
+	"UY": {"es-UY"},
+	"UZ": {"uz-UZ", "ru-UZ"},
+	"VE": {"es-VE"},
+	"VN": {"vi-VN"},
+	"YE": {"ar-YE"},
+	"ZA": {"en-ZA", "af-ZA", "zu-ZA", "xh-ZA"},
+	"ZW": {"en-ZW", "sn-ZW"},
+}
+
+// LocalesForCountry returns the likely locales (e.g., "de-DE") for a country code,
+// ordered from most to least common. It returns nil if the country is not in the
+// embedded mapping. The returned slice is a copy and may be modified by the caller.
+func LocalesForCountry(code string) []string {
+	locales, ok := countryLocales[strings.ToUpper(code)]
+	if !ok {
+		return nil
+	}
+	out := make([]string, len(locales))
+	copy(out, locales)
+	return out
+}
+
+// getLocales resolves an IP address through the given lookup and maps the result to locales.
+func getLocales(l CountryReader, ipStr string) ([]string, error) {
+	code, err := l.GetCountryCode(ipStr)
+	if err != nil {
+		return nil, err
+	}
+	return LocalesForCountry(code), nil
+}
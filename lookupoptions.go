@@ -0,0 +1,37 @@
+package ip2country
+
+// LookupOption adjusts a single call to Lookup, so call sites with special needs do not
+// require a separate database or a change of Config.
+type LookupOption func(*lookupOptions)
+
+// lookupOptions holds the settings applied by LookupOption values.
+type lookupOptions struct {
+	defaultCode string
+	bypassCache bool
+}
+
+// WithBypassCache answers the lookup from the dataset, neither reading nor filling the
+// lookup cache, e.g. to verify a cached answer or for one-off lookups that should not
+// evict hot entries.
+func WithBypassCache() LookupOption {
+	return func(o *lookupOptions) {
+		o.bypassCache = true
+	}
+}
+
+// WithDefault answers the lookup with code instead of failing with ErrNotFound when no
+// country is found, overriding Config.DefaultCountryCode.
+func WithDefault(code string) LookupOption {
+	return func(o *lookupOptions) {
+		o.defaultCode = code
+	}
+}
+
+// newLookupOptions applies opts over the defaults of cfg.
+func newLookupOptions(cfg *Config, opts []LookupOption) lookupOptions {
+	o := lookupOptions{defaultCode: cfg.DefaultCountryCode}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
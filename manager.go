@@ -0,0 +1,115 @@
+package ip2country
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// datasetKey is the context key under which ContextWithDataset stores the dataset name.
+const datasetKey = contextKey("dataset")
+
+// ContextWithDataset returns a copy of ctx that makes a Manager answer lookups from the
+// named dataset, e.g. the tenant's own dataset in a multi-tenant service.
+func ContextWithDataset(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, datasetKey, name)
+}
+
+// DatasetFromContext returns the dataset name stored by ContextWithDataset, if any.
+func DatasetFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(datasetKey).(string)
+	return name, ok
+}
+
+// Manager holds several named datasets, such as one per tenant, and implements
+// CountryReader by routing each lookup to the dataset named in its context with
+// ContextWithDataset. Lookups without a dataset name, including those made through the
+// methods without a context, use the default dataset. It is safe for concurrent use.
+type Manager struct {
+	mu          sync.RWMutex
+	readers     map[string]CountryReader
+	defaultName string
+}
+
+// NewManager returns an empty Manager. Register datasets with Register.
+func NewManager() *Manager {
+	return &Manager{readers: make(map[string]CountryReader)}
+}
+
+// Register adds or replaces the dataset with the given name. The first registered
+// dataset becomes the default unless SetDefault is called.
+func (m *Manager) Register(name string, reader CountryReader) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.readers) == 0 && m.defaultName == "" {
+		m.defaultName = name
+	}
+	m.readers[name] = reader
+}
+
+// Remove unregisters the named dataset. It does not close it.
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.readers, name)
+}
+
+// SetDefault selects the dataset used by lookups whose context names no dataset.
+func (m *Manager) SetDefault(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultName = name
+}
+
+// Dataset returns the named dataset, if registered.
+func (m *Manager) Dataset(name string) (CountryReader, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	reader, ok := m.readers[name]
+	return reader, ok
+}
+
+// reader returns the dataset selected by ctx.
+func (m *Manager) reader(ctx context.Context) (CountryReader, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	name, ok := DatasetFromContext(ctx)
+	if !ok {
+		name = m.defaultName
+	}
+	if reader, ok := m.readers[name]; ok {
+		return reader, nil
+	}
+	return nil, newLookupError(CodeInvalidArgument, fmt.Errorf("unknown dataset %q", name))
+}
+
+// GetCountry retrieves the country code for a given IP address string from the default
+// dataset.
+func (m *Manager) GetCountry(ipStr string) (string, error) {
+	return m.GetCountryWithContext(context.Background(), ipStr)
+}
+
+// GetCountryWithContext retrieves the country code from the dataset selected by ctx.
+func (m *Manager) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	reader, err := m.reader(ctx)
+	if err != nil {
+		return "", err
+	}
+	return reader.GetCountryWithContext(ctx, ipStr)
+}
+
+// GetCountryCode retrieves the country code for a given IP address string from the
+// default dataset.
+func (m *Manager) GetCountryCode(ipStr string) (string, error) {
+	return m.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryCodeWithContext retrieves the country code from the dataset selected by ctx.
+func (m *Manager) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
+	reader, err := m.reader(ctx)
+	if err != nil {
+		return "", err
+	}
+	return reader.GetCountryCodeWithContext(ctx, ipStr)
+}
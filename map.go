@@ -1,10 +1,16 @@
 package ip2country
 
 import (
-	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/netip"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,25 +20,39 @@ import (
 // ExactIPCountryMap implements the IPCountryLookup interface using a map for exact IP matches.
 // This is suitable for datasets where specific IPs are mapped to countries, rather than ranges.
 // It expects a CSV format of: ip,country_code
+// The loaded map is published through an atomic pointer, so lookups only take the lock
+// of the shard holding the address.
 type ExactIPCountryMap struct {
-	ipMap       map[uint32]string
-	mu          sync.RWMutex
-	initialized int32
-	initErr     error
-	config      Config
-	stats       Stats
-	filePath    string
-	cache       *lruCache
-	parseErrors []ParseError
+	snapshot atomic.Pointer[mapSnapshot]
+	loadMu   sync.Mutex // Serializes loads; held while a dataset is parsed.
+	life     lifecycle
+	config   Config
+	source   Source
+	cache    resultCache
+	logger   *slog.Logger
+	panics   atomic.Int64
+	groups   atomic.Pointer[groupSet]
+	breaker  *initBreaker
 }
 
-// NewExactIPCountryMap creates a new instance of ExactIPCountryMap.
+// NewExactIPCountryMap creates a new instance of ExactIPCountryMap that reads the data
+// from the local file at filePath, which may be compressed or inside an archive.
 // The data is not loaded until the first lookup or an explicit call to Reload.
 func NewExactIPCountryMap(filePath string, config ...Config) *ExactIPCountryMap {
 	cfg := DefaultConfig()
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	return NewExactIPCountryMapFromSource(&FileSource{Path: filePath, ArchivePattern: cfg.ArchivePattern}, cfg)
+}
+
+// NewExactIPCountryMapFromSource creates an ExactIPCountryMap that loads its data from
+// src. Reloads that find the source unchanged keep the loaded data.
+func NewExactIPCountryMapFromSource(src Source, config ...Config) *ExactIPCountryMap {
+	cfg := DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
 
 	if cfg.Delimiter == "" {
 		cfg.Delimiter = ","
@@ -41,83 +61,161 @@ func NewExactIPCountryMap(filePath string, config ...Config) *ExactIPCountryMap
 		cfg.CacheSize = 1000
 	}
 
-	return &ExactIPCountryMap{
-		filePath: filePath,
-		config:   cfg,
-		cache:    newLRUCache(cfg.CacheSize),
+	m := &ExactIPCountryMap{
+		source:  src,
+		config:  cfg,
+		cache:   newResultCache(cfg),
+		logger:  newLogger(cfg),
+		life:    newLifecycle(),
+		breaker: newInitBreaker(&cfg),
 	}
+	m.groups.Store(newGroupSet(cfg.Groups))
+	return m
+}
+
+// mapSnapshot is a loaded exact-match dataset. It is swapped in whole on every load;
+// runtime changes made with Set and Delete go to the shards of the live snapshot.
+type mapSnapshot struct {
+	ipMap       *shardedMap
+	parseErrors parseErrorCollector
+	stats       Stats
+	attribution Attribution
+	source      Metadata
+	generation  uint64
 }
 
-// initializeWithContext handles the one-time loading of the IP map from a file.
-func (m *ExactIPCountryMap) initializeWithContext(ctx context.Context) error {
-	if atomic.LoadInt32(&m.initialized) == 1 {
-		return m.initErr
+// initializeWithContext handles the one-time loading of the IP map from a file and
+// returns the live snapshot.
+func (m *ExactIPCountryMap) initializeWithContext(ctx context.Context) (*mapSnapshot, error) {
+	if snap := m.snapshot.Load(); snap != nil {
+		return snap, nil
+	}
+	if err := m.breaker.allow(); err != nil {
+		return nil, err
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
 
-	if atomic.LoadInt32(&m.initialized) == 1 {
-		return m.initErr
+	if snap := m.snapshot.Load(); snap != nil {
+		return snap, nil
+	}
+	if m.life.closed() {
+		return nil, ErrClosed
 	}
+	// Lookups that waited for a failed attempt must not repeat it.
+	if err := m.breaker.allow(); err != nil {
+		return nil, err
+	}
+	snap, err := m.load(ctx)
+	if err != nil {
+		m.breaker.record(err)
+		return nil, err
+	}
+	m.install(snap)
+	return snap, nil
+}
 
+// load parses the dataset into a fresh map without touching the installed one, so
+// lookups keep being served while a new dataset is read. The caller must hold loadMu.
+func (m *ExactIPCountryMap) load(ctx context.Context) (*mapSnapshot, error) {
 	start := time.Now()
-	err := m.parseFileWithContext(ctx, m.filePath)
+	data, meta, err := m.parseSourceWithContext(ctx)
+	if errors.Is(err, ErrNotModified) {
+		return nil, err
+	}
 	if err != nil {
-		m.initErr = err
-		return m.initErr
+		m.logger.Error("dataset load failed", "source", sourceName(m.source), "error", err)
+		return nil, newLoadError(err)
 	}
 
-	m.stats.LoadTime = time.Since(start)
-	m.stats.LastUpdate = time.Now()
-	m.stats.TotalRanges = len(m.ipMap)
+	data.attribution = detectAttribution(m.source, meta, m.config)
+	data.source = meta
+	data.stats.LoadTime = time.Since(start)
+	data.stats.LastUpdate = time.Now()
+	data.stats.Provider = data.attribution.Provider
+	data.stats.TotalRanges = data.ipMap.len()
+	m.logger.Info("dataset loaded", "source", meta.Name, "entries", data.stats.TotalRanges,
+		"parse_errors", data.parseErrors.summary.Total, "duration", data.stats.LoadTime)
+	if n := m.config.SelfBenchmarkLookups; n > 0 {
+		data.stats.SelfBenchmark = runSelfBenchmark(n, func(ipNum uint32) bool {
+			_, ok := data.ipMap.get(ipNum)
+			return ok
+		})
+		m.logger.Info("self-benchmark finished", "lookups", n, "qps", int(data.stats.SelfBenchmark.QPS),
+			"p50", data.stats.SelfBenchmark.P50, "p99", data.stats.SelfBenchmark.P99)
+	}
+	if data.parseErrors.summary.Total > 0 {
+		m.logger.Warn("dataset lines rejected", "source", meta.Name, "summary", data.parseErrors.summary.String())
+	}
+	return data, nil
+}
 
-	atomic.StoreInt32(&m.initialized, 1)
-	return nil
+// install publishes snap as the live dataset and advances the cache generation, so
+// answers computed from the previous snapshot are no longer cached. The caller must
+// hold loadMu.
+func (m *ExactIPCountryMap) install(snap *mapSnapshot) {
+	if prev := m.snapshot.Load(); prev != nil {
+		snap.generation = prev.generation + 1
+	}
+	m.snapshot.Store(snap)
+	m.cache.setGeneration(snap.generation)
+	m.breaker.record(nil)
+	if c, ok := m.source.(loadCommitter); ok {
+		c.commitLoad()
+	}
 }
 
-func (m *ExactIPCountryMap) parseFileWithContext(ctx context.Context, filePath string) error {
-	file, err := os.Open(filePath)
+// parseSourceWithContext opens the dataset provided by the source and parses it into
+// a fresh map.
+func (m *ExactIPCountryMap) parseSourceWithContext(ctx context.Context) (*mapSnapshot, Metadata, error) {
+	file, meta, err := m.source.Open(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return nil, meta, err
 	}
 	defer file.Close()
 
-	stat, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file stats: %w", err)
-	}
-	fileSize := stat.Size()
+	fileSize := meta.Size
 	if m.config.MaxFileSize > 0 && fileSize > m.config.MaxFileSize {
-		return fmt.Errorf("file size %d exceeds limit %d", fileSize, m.config.MaxFileSize)
+		return nil, meta, fmt.Errorf("%w: %d > %d", ErrFileTooLarge, fileSize, m.config.MaxFileSize)
 	}
 
-	m.ipMap = make(map[uint32]string)
-	m.parseErrors = nil
+	data := &mapSnapshot{
+		ipMap:       newShardedMap(),
+		parseErrors: newParseErrorCollector(&m.config),
+	}
 
-	scanner := bufio.NewScanner(file)
+	hash := sha256.New()
+	counter := &limitedReader{r: io.TeeReader(file, hash), limit: m.config.MaxFileSize}
+	reader, layout := detectLayout(counter, &m.config)
+	scanner, release := newLineScanner(reader)
+	defer release()
+	progress := progressReporter{fn: m.config.ProgressFunc}
 	lineNum, processed := 0, 0
 
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, meta, ctx.Err()
 		default:
 		}
 
 		lineNum++
+		progress.line(len(scanner.Bytes()))
 		line := strings.TrimSpace(scanner.Text())
-		if line == "" || (m.config.SkipHeader && lineNum == 1) {
+		if line == "" || (layout.skipHeader && lineNum == 1) || isComment(line, m.config.CommentPrefixes) {
 			continue
 		}
 
-		code, ipNum, err := m.parseLine(line)
+		code, ipNum, err := m.parseLine(line, layout.delimiter)
 		if err != nil {
-			m.parseErrors = append(m.parseErrors, ParseError{Line: lineNum, Content: line, Err: err})
+			if err := data.parseErrors.add(lineNum, line, err); err != nil {
+				return nil, meta, err
+			}
 			continue
 		}
 
-		m.ipMap[ipNum] = code
+		data.ipMap.set(ipNum, code)
 
 		processed++
 		if m.config.MaxRanges > 0 && processed >= m.config.MaxRanges {
@@ -126,19 +224,29 @@ func (m *ExactIPCountryMap) parseFileWithContext(ctx context.Context, filePath s
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("scanner error: %w", err)
+		return nil, meta, fmt.Errorf("scanner error: %w", err)
 	}
+	progress.done()
 
-	m.stats.FileSize = fileSize
-	return nil
+	if fileSize < 0 {
+		fileSize = counter.n
+	}
+	data.stats.ParseErrors = data.parseErrors.summary.Total
+	data.stats.FileSize = fileSize
+	data.stats.Source = meta.Name
+	data.stats.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return data, meta, nil
 }
 
 // parseLine parses a single line for the exact IP map.
 // Expected format: ip,country_code
-func (m *ExactIPCountryMap) parseLine(line string) (code string, ipNum uint32, err error) {
-	parts := strings.Split(line, m.config.Delimiter)
+func (m *ExactIPCountryMap) parseLine(line, delimiter string) (code string, ipNum uint32, err error) {
+	parts, err := splitFields(line, delimiter)
+	if err != nil {
+		return
+	}
 	if len(parts) != 2 {
-		err = fmt.Errorf("incorrect number of fields: expected 2, got %d", len(parts))
+		err = fmt.Errorf("%w: expected 2, got %d", errFieldCount, len(parts))
 		return
 	}
 
@@ -151,62 +259,227 @@ func (m *ExactIPCountryMap) parseLine(line string) (code string, ipNum uint32, e
 
 	code = strings.TrimSpace(parts[1])
 	if code == "" {
-		err = fmt.Errorf("country code cannot be empty")
+		err = errEmptyCode
 		return
 	}
+	code = internCode(code)
 
 	return
 }
 
-// GetParseErrors returns any errors that occurred during the last load/reload.
+// GetParseErrors returns any errors that occurred during the last load/reload, up to
+// Config.MaxStoredParseErrors.
 func (m *ExactIPCountryMap) GetParseErrors() []ParseError {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	errorsCopy := make([]ParseError, len(m.parseErrors))
-	copy(errorsCopy, m.parseErrors)
+	snap := m.snapshot.Load()
+	if snap == nil {
+		return []ParseError{}
+	}
+	errorsCopy := make([]ParseError, len(snap.parseErrors.errors))
+	copy(errorsCopy, snap.parseErrors.errors)
 	return errorsCopy
 }
 
-// findCountryForIP looks up an IP in the map, using the cache.
-func (m *ExactIPCountryMap) findCountryForIP(ipNum uint32) (string, string, error) {
-	if entry, found := m.cache.get(ipNum); found {
+// GetParseErrorSummary returns the counts per kind of all errors that occurred during
+// the last load/reload, including those not returned by GetParseErrors.
+func (m *ExactIPCountryMap) GetParseErrorSummary() ParseErrorSummary {
+	snap := m.snapshot.Load()
+	if snap == nil {
+		return ParseErrorSummary{}
+	}
+	summary := snap.parseErrors.summary
+	summary.ByKind = maps.Clone(summary.ByKind)
+	return summary
+}
+
+// findCountryForIP looks up an IP in the map, using the cache, and writes the match into res.
+// cached reports whether the answer was served from the cache.
+func (m *ExactIPCountryMap) findCountryForIP(snap *mapSnapshot, ipNum uint32, res *Result, bypassCache bool) (cached bool, err error) {
+	if bypassCache {
+		sh := snap.ipMap.shard(ipNum)
+		sh.mu.RLock()
+		code, ok := sh.m[ipNum]
+		sh.mu.RUnlock()
+		if !ok {
+			return false, newLookupError(CodeNotFound, ErrNotFound)
+		}
+		res.Country, res.Code, res.StartIP, res.EndIP = code, code, ipNum, ipNum
+		return false, nil
+	}
+	if entry, found := m.cache.get(ipNum, ipNum); found {
 		if !entry.found {
-			return "", "", fmt.Errorf("country not found for IP (cached miss)")
+			return true, newLookupError(CodeNotFound, fmt.Errorf("%w (cached miss)", ErrNotFound))
 		}
-		return entry.country, entry.code, nil
+		entry.fill(res)
+		return true, nil
 	}
 
-	code, countryExists := m.ipMap[ipNum]
+	// The cache is filled while holding the shard lock, so a concurrent Set or Delete
+	// cannot invalidate the entry before the stale answer is stored.
+	sh := snap.ipMap.shard(ipNum)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	code, countryExists := sh.m[ipNum]
 	if !countryExists {
-		m.cache.put(ipNum, cacheEntry{ip: ipNum, found: false})
-		return "", "", fmt.Errorf("country not found for IP")
+		m.cache.put(ipNum, cacheEntry{ip: ipNum, startIP: ipNum, endIP: ipNum, found: false}, snap.generation)
+		return false, newLookupError(CodeNotFound, ErrNotFound)
 	}
 
-	m.cache.put(ipNum, cacheEntry{ip: ipNum, country: code, code: code, found: true})
-	return code, code, nil
+	entry := cacheEntry{ip: ipNum, country: code, code: code, startIP: ipNum, endIP: ipNum, found: true}
+	m.cache.put(ipNum, entry, snap.generation)
+	entry.fill(res)
+	return false, nil
 }
 
-// GetCountry retrieves the country code for a given IP address string.
-func (m *ExactIPCountryMap) GetCountry(ipStr string) (string, error) {
-	return m.GetCountryWithContext(context.Background(), ipStr)
+// Set maps the IP address to the country code at runtime, replacing any existing entry.
+// Updates lock only a single shard of the map, so heavy override churn does not block
+// lookups of other addresses. Runtime changes are discarded by the next Reload.
+func (m *ExactIPCountryMap) Set(ipStr, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return newLookupError(CodeInvalidArgument, fmt.Errorf("country code cannot be empty"))
+	}
+	return m.update(ipStr, func(sh *mapShard, ipNum uint32) {
+		sh.m[ipNum] = internCode(code)
+	})
 }
 
-// GetCountryWithContext retrieves the country code, respecting the context.
-func (m *ExactIPCountryMap) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
-	if err := m.initializeWithContext(ctx); err != nil {
-		return "", fmt.Errorf("initialization failed: %w", err)
+// Delete removes the IP address from the map at runtime. Deleting an address that is
+// not present is not an error. Runtime changes are discarded by the next Reload.
+func (m *ExactIPCountryMap) Delete(ipStr string) error {
+	return m.update(ipStr, func(sh *mapShard, ipNum uint32) {
+		delete(sh.m, ipNum)
+	})
+}
+
+// update applies fn to the shard holding ipStr, with the shard write-locked, and drops
+// the cached answer for the address. The cache is only touched once the shard lock is
+// released: reloads with CacheInvalidateChanged read the shards while holding the cache
+// lock, so holding both here would deadlock against them.
+func (m *ExactIPCountryMap) update(ipStr string, fn func(sh *mapShard, ipNum uint32)) (err error) {
+	defer recoverPanic(m.logger, m.config.Name, "update", &m.panics, &err)
+
+	snap, err := m.initializeWithContext(context.Background())
+	if err != nil {
+		return newLookupError(failureCode(err, CodeNotLoaded), namedError(m.config.Name, fmt.Errorf("%w: %w", ErrNotInitialized, err)))
+	}
+	ipNum, err := parseIP(strings.TrimSpace(ipStr))
+	if err != nil {
+		return newLookupError(CodeInvalidIP, fmt.Errorf("%w: %w", ErrInvalidIP, err))
+	}
+
+	sh := snap.ipMap.shard(ipNum)
+	func() {
+		sh.mu.Lock()
+		defer sh.mu.Unlock()
+		fn(sh, ipNum)
+	}()
+
+	m.cache.remove(ipNum)
+	return nil
+}
+
+// LookupInto resolves ipStr and writes the matched country into res.
+// For an exact map, the reported range consists of the single matched address.
+func (m *ExactIPCountryMap) LookupInto(ipStr string, res *Result) error {
+	return m.LookupIntoWithContext(context.Background(), ipStr, res)
+}
+
+// LookupIntoWithContext resolves ipStr, respecting the context, and writes the matched
+// country into res. On error, res is reset to its zero value.
+func (m *ExactIPCountryMap) LookupIntoWithContext(ctx context.Context, ipStr string, res *Result) error {
+	return m.lookupInto(ctx, res, func() (uint32, error) { return parseLookupIP(ipStr, &m.config) })
+}
+
+// GetCountryCodeForAddr retrieves the country code for an already parsed address,
+// avoiding the string round-trip. IPv4-mapped IPv6 addresses are unmapped.
+func (m *ExactIPCountryMap) GetCountryCodeForAddr(addr netip.Addr) (string, error) {
+	var res Result
+	err := m.lookupInto(context.Background(), &res, func() (uint32, error) { return addrToUint32(addr) })
+	return res.Code, err
+}
+
+// GetCountryCodeForIP retrieves the country code for a net.IP in 4-byte or IPv4-mapped
+// 16-byte form.
+func (m *ExactIPCountryMap) GetCountryCodeForIP(ip net.IP) (string, error) {
+	var res Result
+	err := m.lookupInto(context.Background(), &res, func() (uint32, error) { return netIPToUint32(ip) })
+	return res.Code, err
+}
+
+// GetCountryCodeForUint32 retrieves the country code for an IPv4 address in host byte
+// order, e.g. 134744072 for 8.8.8.8. It is the fastest lookup path.
+func (m *ExactIPCountryMap) GetCountryCodeForUint32(ipNum uint32) (string, error) {
+	var res Result
+	err := m.lookupInto(context.Background(), &res, func() (uint32, error) { return ipNum, nil })
+	return res.Code, err
+}
+
+// lookupInto resolves the address returned by parse, which is called once the dataset
+// is available, and writes the matched country into res. On error, res is reset to
+// its zero value.
+func (m *ExactIPCountryMap) lookupInto(ctx context.Context, res *Result, parse func() (uint32, error)) error {
+	_, err := m.lookup(ctx, res, parse, newLookupOptions(&m.config, nil))
+	return err
+}
+
+// lookup is lookupInto that also returns the snapshot that answered, or nil if the
+// dataset is not available.
+func (m *ExactIPCountryMap) lookup(ctx context.Context, res *Result, parse func() (uint32, error), opts lookupOptions) (snap *mapSnapshot, err error) {
+	defer recoverPanic(m.logger, m.config.Name, "lookup", &m.panics, &err)
+
+	*res = Result{}
+	snap, err = m.initializeWithContext(ctx)
+	if err != nil {
+		return nil, newLookupError(failureCode(err, CodeNotLoaded), namedError(m.config.Name, fmt.Errorf("%w: %w", ErrNotInitialized, err)))
 	}
 
-	ipNum, err := parseIP(ipStr)
+	ipNum, err := parse()
 	if err != nil {
-		return "", fmt.Errorf("invalid IP: %w", err)
+		return snap, newLookupError(CodeInvalidIP, fmt.Errorf("%w: %w", ErrInvalidIP, err))
 	}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	bits := m.config.AnonymizedPrefixLen
+	first, last := anonymizedBlock(ipNum, bits)
 
-	country, _, err := m.findCountryForIP(ipNum)
-	return country, err
+	cached, err := m.findCountryForIP(snap, first, res, opts.bypassCache)
+	if debugAssertions {
+		m.checkInvariants(snap, first, res, err)
+	}
+	if err == nil && bits > 0 {
+		err = checkAnonymizedMatch(res, first, last, bits)
+	}
+	if err == nil && m.config.HitSink != nil {
+		m.config.HitSink.record(ipNum, res.Code, cached)
+	}
+	if err != nil && opts.defaultCode != "" && errors.Is(err, ErrNotFound) {
+		*res = Result{Country: opts.defaultCode, Code: opts.defaultCode, StartIP: first, EndIP: first}
+		err = nil
+	}
+	return snap, err
+}
+
+// Lookup resolves ipStr and returns the full answer: country, the address as its own
+// range and the dataset that produced it. opts adjust this lookup only.
+func (m *ExactIPCountryMap) Lookup(ctx context.Context, ipStr string, opts ...LookupOption) (*Record, error) {
+	var res Result
+	snap, err := m.lookup(ctx, &res, func() (uint32, error) { return parseLookupIP(ipStr, &m.config) }, newLookupOptions(&m.config, opts))
+	if err != nil {
+		return nil, err
+	}
+	return newRecord(&res, snap.source, snap.attribution.Provider, snap.stats.LastUpdate), nil
+}
+
+// GetCountry retrieves the country code for a given IP address string.
+func (m *ExactIPCountryMap) GetCountry(ipStr string) (string, error) {
+	return m.GetCountryWithContext(context.Background(), ipStr)
+}
+
+// GetCountryWithContext retrieves the country code, respecting the context.
+func (m *ExactIPCountryMap) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	var res Result
+	err := m.LookupIntoWithContext(ctx, ipStr, &res)
+	return res.Country, err
 }
 
 // GetCountryCode retrieves the country code for a given IP address string.
@@ -216,52 +489,157 @@ func (m *ExactIPCountryMap) GetCountryCode(ipStr string) (string, error) {
 
 // GetCountryCodeWithContext retrieves the country code, respecting the context.
 func (m *ExactIPCountryMap) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
-	if err := m.initializeWithContext(ctx); err != nil {
-		return "", fmt.Errorf("initialization failed: %w", err)
-	}
+	var res Result
+	err := m.LookupIntoWithContext(ctx, ipStr, &res)
+	return res.Code, err
+}
 
-	ipNum, err := parseIP(ipStr)
-	if err != nil {
-		return "", fmt.Errorf("invalid IP: %w", err)
-	}
+// GetLocales returns the likely locales (e.g., "de-DE") for the country of the given IP.
+// It returns nil if the country has no known locales.
+func (m *ExactIPCountryMap) GetLocales(ipStr string) ([]string, error) {
+	return getLocales(m, ipStr)
+}
+
+// GetContinentCode returns the continent code (e.g., "EU") of the country of the given
+// IP. It returns an empty string if the country has no known continent.
+func (m *ExactIPCountryMap) GetContinentCode(ipStr string) (string, error) {
+	return getContinentCode(m, ipStr)
+}
+
+// InGroup reports whether the country of the given IP belongs to the named group from
+// Config.Groups. It returns an error for unknown groups and failed lookups.
+func (m *ExactIPCountryMap) InGroup(ipStr, group string) (bool, error) {
+	return inGroup(m, m.groups.Load(), ipStr, group)
+}
+
+// IsSanctioned reports whether the country of the given IP is in SanctionedGroup.
+func (m *ExactIPCountryMap) IsSanctioned(ipStr string) (bool, error) {
+	return m.InGroup(ipStr, SanctionedGroup)
+}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// IsEU reports whether the country of the given IP is a member state of the European
+// Union, as listed in EUGroup.
+func (m *ExactIPCountryMap) IsEU(ipStr string) (bool, error) {
+	return m.InGroup(ipStr, EUGroup)
+}
 
-	_, code, err := m.findCountryForIP(ipNum)
-	return code, err
+// SetGroups atomically replaces the user-defined groups, e.g. after an updated
+// definitions file was loaded with LoadGroups. Built-in groups remain available
+// unless overridden.
+func (m *ExactIPCountryMap) SetGroups(groups map[string][]string) {
+	m.groups.Store(newGroupSet(groups))
+}
+
+// Attribution returns the provider and license metadata of the loaded dataset, e.g.
+// for displaying the credit line required by DB-IP or MaxMind. It is the zero value if
+// the dataset is not loaded or its provider is unknown.
+func (m *ExactIPCountryMap) Attribution() Attribution {
+	if snap := m.snapshot.Load(); snap != nil {
+		return snap.attribution
+	}
+	return Attribution{}
 }
 
 // Stats returns the current operational statistics of the map.
 func (m *ExactIPCountryMap) Stats() Stats {
-	m.mu.RLock()
-	s := m.stats
-	m.mu.RUnlock()
+	var s Stats
+	if snap := m.snapshot.Load(); snap != nil {
+		s = snap.stats
+		s.TotalRanges = snap.ipMap.len()
+	}
 
 	hits, misses := m.cache.getStats()
+	s.Name = m.config.Name
 	s.CacheHits = hits
 	s.CacheMisses = misses
+	s.CacheBytes = m.cache.usage()
+	s.RecoveredPanics = m.panics.Load()
+	s.InitCircuit, s.InitFailures = m.breaker.state()
 	return s
 }
 
-// Reload clears the current dataset and loads it again from the source file.
+// StartAutoReload starts a background goroutine that checks the source every
+// Config.RefreshInterval and reloads the dataset when it has changed, so long-running
+// servers pick up new data without restarts. Local files are considered changed when
+// their size or modification time differs. The goroutine stops when ctx is canceled;
+// failed reloads are logged and retried at the next interval.
+func (m *ExactIPCountryMap) StartAutoReload(ctx context.Context) error {
+	if m.life.closed() {
+		return ErrClosed
+	}
+	return startAutoReload(m.life.bind(ctx), m.config.RefreshInterval, m.source, m.logger, coordinatedReload(m.config, m.ReloadWithContext))
+}
+
+// Watch polls the source every Config.RefreshInterval (or every second if unset) and
+// reloads the dataset when the file is replaced, reporting the outcome of each reload
+// on the returned channel so callers can log success or failure. The channel is closed
+// when ctx is canceled.
+func (m *ExactIPCountryMap) Watch(ctx context.Context) <-chan ReloadEvent {
+	return watch(m.life.bind(ctx), m.config.RefreshInterval, m.source, coordinatedReload(m.config, m.ReloadWithContext))
+}
+
+// Close stops the background reloads started by StartAutoReload and Watch and releases
+// the dataset, waiting for a reload in progress to finish. Lookups and reloads after
+// Close return ErrClosed. It is safe to call more than once.
+func (m *ExactIPCountryMap) Close() error {
+	m.life.close()
+
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	m.snapshot.Store(nil)
+	m.cache.clear()
+	return nil
+}
+
+// Reload loads the dataset again from the source.
 func (m *ExactIPCountryMap) Reload() error {
 	return m.ReloadWithContext(context.Background())
 }
 
 // ReloadWithContext reloads the dataset, respecting the context for cancellation.
-func (m *ExactIPCountryMap) ReloadWithContext(ctx context.Context) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// The new dataset is parsed while lookups are still served from the current one, which
+// is swapped out only once loading succeeded; if the reload fails, the current dataset
+// stays in service. Cached lookups are invalidated according to Config.CacheInvalidation.
+func (m *ExactIPCountryMap) ReloadWithContext(ctx context.Context) (err error) {
+	defer recoverPanic(m.logger, m.config.Name, "reload", &m.panics, &err)
 
-	atomic.StoreInt32(&m.initialized, 0)
-	m.ipMap = nil
-	m.initErr = nil
-	m.cache.clear()
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
 
-	err := m.initializeWithContext(ctx)
+	if m.life.closed() {
+		return newLookupError(CodeClosed, namedError(m.config.Name, fmt.Errorf("reload failed: %w", ErrClosed)))
+	}
+	old := m.snapshot.Load()
+	snap, err := m.load(ctx)
+	if errors.Is(err, ErrNotModified) && old != nil {
+		m.logger.Debug("dataset not modified")
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("reload failed: %w", err)
+		code := CodeNotLoaded
+		if old != nil {
+			code = CodeStaleData
+		}
+		return newLookupError(failureCode(err, code), namedError(m.config.Name, fmt.Errorf("reload failed: %w", err)))
+	}
+
+	m.install(snap)
+	switch m.config.CacheInvalidation {
+	case CacheInvalidateNegative:
+		m.cache.removeIf(func(_ uint32, e cacheEntry) bool { return !e.found })
+	case CacheInvalidateChanged:
+		var oldMap *shardedMap
+		if old != nil {
+			oldMap = old.ipMap
+		}
+		m.cache.removeIf(func(ip uint32, _ cacheEntry) bool {
+			oldCode, oldOK := oldMap.get(ip)
+			newCode, newOK := snap.ipMap.get(ip)
+			return oldOK != newOK || oldCode != newCode
+		})
+	default:
+		m.cache.clear()
 	}
 	return nil
 }
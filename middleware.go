@@ -0,0 +1,200 @@
+package ip2country
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// contextKey is the type of context keys defined by this package.
+type contextKey string
+
+// countryCodeKey is the context key under which Middleware stores the country code.
+const countryCodeKey = contextKey("countryCode")
+
+// originCountryCodeKey is the context key under which Middleware stores the country code
+// of the request's destination host.
+const originCountryCodeKey = contextKey("originCountryCode")
+
+// UnknownCountryCode is the canonical code stored by Middleware with WithUnknownCode for
+// requests whose country cannot be determined.
+const UnknownCountryCode = "??"
+
+// MiddlewareOption configures the behavior of Middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// middlewareConfig holds the settings applied by MiddlewareOption values.
+type middlewareConfig struct {
+	overrides   *ExactIPCountryMap
+	policy      *Policy
+	denied      http.Handler
+	clientIP    func(*http.Request) string
+	originIP    func(*http.Request) string
+	unknownCode string
+}
+
+// WithOverrides makes the middleware consult an exact-match map before the main lookup.
+// IPs present in the map (office networks, QA machines) always resolve to the mapped
+// country; all other IPs fall through to the main lookup.
+func WithOverrides(overrides *ExactIPCountryMap) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.overrides = overrides
+	}
+}
+
+// WithUnknownCode makes the middleware store code, e.g. UnknownCountryCode, for requests
+// whose country cannot be determined instead of leaving the context without a code, so
+// handlers can always read a code and branch on its value.
+func WithUnknownCode(code string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.unknownCode = code
+	}
+}
+
+// WithPolicy makes the middleware enforce a Policy on the client country: denied
+// requests are passed to denied instead of the next handler, with the country code
+// already stored in the context. If denied is nil, they are answered with 403
+// Forbidden. Requests whose country cannot be determined get PolicySpec.Unknown.
+func WithPolicy(policy *Policy, denied http.Handler) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		if denied == nil {
+			denied = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			})
+		}
+		c.policy = policy
+		c.denied = denied
+	}
+}
+
+// WithClientIPFunc replaces ClientIP as the function used to extract the client address
+// from a request, e.g. to only trust forwarding headers set by a known proxy.
+func WithClientIPFunc(fn func(*http.Request) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.clientIP = fn
+	}
+}
+
+// WithOriginCountry makes the middleware also resolve the country of the host the request
+// is addressed to, retrievable with OriginCountryCodeFromContext, so reverse proxies
+// serving many upstreams can base data-residency routing on both the client and the
+// origin country. originIP extracts the destination address from a request; if nil,
+// OriginIP is used, which resolves no host names. Pass the OriginIP method of an
+// OriginResolver to resolve them from a static map or with cached DNS lookups.
+func WithOriginCountry(originIP func(*http.Request) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		if originIP == nil {
+			originIP = OriginIP
+		}
+		c.originIP = originIP
+	}
+}
+
+// Middleware returns HTTP middleware that resolves the client IP of each request and
+// stores the country code in the request context, retrievable with CountryCodeFromContext.
+// Requests whose country cannot be determined are passed through without a code, or with
+// the code set by WithUnknownCode. With WithPolicy, requests from denied countries are
+// stopped.
+func Middleware(lookup CountryReader, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := middlewareConfig{clientIP: ClientIP}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			code, err := cfg.resolve(r.Context(), lookup, cfg.clientIP(r))
+
+			if stored, ok := cfg.stored(code, err); ok {
+				r = r.WithContext(ContextWithCountryCode(r.Context(), stored))
+			}
+			if cfg.policy != nil {
+				if err != nil {
+					code = ""
+				}
+				if cfg.policy.Decide(code) == PolicyDeny {
+					cfg.denied.ServeHTTP(w, r)
+					return
+				}
+			}
+			if cfg.originIP != nil {
+				if stored, ok := cfg.stored(cfg.resolve(r.Context(), lookup, cfg.originIP(r))); ok {
+					r = r.WithContext(ContextWithOriginCountryCode(r.Context(), stored))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stored returns the code to store for the outcome of resolve: the country, or the code
+// set by WithUnknownCode if it could not be determined. ok is false if there is nothing
+// to store.
+func (c *middlewareConfig) stored(code string, err error) (string, bool) {
+	if err == nil {
+		return code, true
+	}
+	return c.unknownCode, c.unknownCode != ""
+}
+
+// resolve looks up ip in the overrides map first, then in the main lookup.
+func (c *middlewareConfig) resolve(ctx context.Context, lookup CountryReader, ip string) (string, error) {
+	if c.overrides != nil {
+		if code, err := c.overrides.GetCountryCodeWithContext(ctx, ip); err == nil {
+			return code, nil
+		}
+	}
+	return lookup.GetCountryCodeWithContext(ctx, ip)
+}
+
+// ContextWithCountryCode returns a copy of ctx carrying the given country code.
+func ContextWithCountryCode(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, countryCodeKey, code)
+}
+
+// CountryCodeFromContext returns the country code stored by Middleware, if any.
+func CountryCodeFromContext(ctx context.Context) (string, bool) {
+	code, ok := ctx.Value(countryCodeKey).(string)
+	return code, ok
+}
+
+// ContextWithOriginCountryCode returns a copy of ctx carrying the given country code of
+// the request's destination host.
+func ContextWithOriginCountryCode(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, originCountryCodeKey, code)
+}
+
+// OriginCountryCodeFromContext returns the country code of the request's destination
+// host stored by Middleware with WithOriginCountry, if any.
+func OriginCountryCodeFromContext(ctx context.Context) (string, bool) {
+	code, ok := ctx.Value(originCountryCodeKey).(string)
+	return code, ok
+}
+
+// OriginIP extracts the destination address of a request from its Host header. Only
+// address literals are supported: host names are returned as is and their lookup
+// fails, as the header is client-controlled. Use OriginResolver to resolve host names.
+func OriginIP(r *http.Request) string {
+	return originHost(r)
+}
+
+// ClientIP extracts the client IP address from a request. It prefers the first entry of
+// X-Forwarded-For, then X-Real-Ip, and finally the connection's remote address.
+// Forwarding headers are client-controlled unless set by a trusted proxy.
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+
+	if realIP := r.Header.Get("X-Real-Ip"); realIP != "" {
+		return realIP
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
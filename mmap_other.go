@@ -0,0 +1,27 @@
+//go:build !unix
+
+package ip2country
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// mapFile is not supported on this platform, so Config.SnapshotDir cannot be used.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory-mapped snapshots are not supported on this platform")
+}
+
+// mapFileReadOnly reads the first size bytes of f into memory, as files cannot be
+// mapped on this platform.
+func mapFileReadOnly(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unmapFile does nothing on this platform, where mappings are plain heap memory.
+func unmapFile(data []byte) {}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package ip2country
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of f into memory. The mapping is shared, so the
+// pages are backed by the file rather than by swap and writes to them never copy.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+// unmapFile releases a mapping returned by mapFile.
+func unmapFile(data []byte) {
+	syscall.Munmap(data)
+}
+
+// mapFileReadOnly maps the first size bytes of f into memory read-only. The mapping is
+// shared, so processes mapping the same file share its pages in the page cache.
+func mapFileReadOnly(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
@@ -0,0 +1,228 @@
+package ip2country
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"net/netip"
+	"time"
+)
+
+// mmdbMetadataMarker separates the data section of a MaxMind DB file from its metadata.
+const mmdbMetadataMarker = "\xab\xcd\xefMaxMind.com"
+
+// MaxMind DB data types.
+const (
+	mmdbString = 2
+	mmdbUint16 = 5
+	mmdbUint32 = 6
+	mmdbMap    = 7
+	mmdbUint64 = 9
+	mmdbArray  = 11
+)
+
+// writeMMDB writes the ranges of snap kept by keep as a MaxMind DB file with an IPv4
+// search tree and a {"country": {"iso_code": ...}} record per distinct label.
+func writeMMDB(ctx context.Context, w io.Writer, snap *rangeSnapshot, keep func(*IPRange) bool, databaseType string) error {
+	if databaseType == "" {
+		databaseType = "ip2country-Country"
+	}
+
+	// nodes[i] holds the two children of node i: 0 for no data, as the root is never a
+	// child, n > 0 for node n, and -(off+1) for the record at offset off of data.
+	nodes := [][2]int32{{}}
+	var data mmdbEncoder
+	offsets := make(map[[2]string]int32)
+	var prefixes []netip.Prefix
+	for i, r := range snap.all() {
+		if i%exportCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if keep != nil && !keep(&r) {
+			continue
+		}
+		key := [2]string{r.Country, r.Code}
+		off, ok := offsets[key]
+		if !ok {
+			if data.Len() >= math.MaxInt32 {
+				return newLookupError(CodeInvalidArgument, fmt.Errorf("too many countries for an MMDB file"))
+			}
+			off = int32(data.Len())
+			data.country(r.Country, r.Code)
+			offsets[key] = off
+		}
+
+		prefixes = appendRangePrefixes(prefixes[:0], r.StartIP, r.EndIP)
+		if len(prefixes) == 1 && prefixes[0].Bits() == 0 {
+			// The root cannot hold data, so the whole address space is split in halves.
+			prefixes = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/1"), netip.MustParsePrefix("128.0.0.0/1")}
+		}
+		for _, p := range prefixes {
+			ip := binary.BigEndian.Uint32(p.Addr().AsSlice())
+			node := 0
+			for depth := range p.Bits() {
+				bit := ip >> (31 - depth) & 1
+				if depth == p.Bits()-1 {
+					nodes[node][bit] = -(off + 1)
+					break
+				}
+				if nodes[node][bit] <= 0 {
+					// Ranges do not overlap, so no record is on the path of a prefix.
+					nodes = append(nodes, [2]int32{})
+					nodes[node][bit] = int32(len(nodes) - 1)
+				}
+				node = int(nodes[node][bit])
+			}
+		}
+	}
+
+	nodeCount := uint64(len(nodes))
+	record := func(child int32) uint64 {
+		switch {
+		case child == 0:
+			return nodeCount
+		case child > 0:
+			return uint64(child)
+		default:
+			return nodeCount + 16 + uint64(-(child + 1))
+		}
+	}
+	recordSize := 24
+	switch maxRecord := nodeCount + 16 + uint64(data.Len()); {
+	case maxRecord >= 1<<32:
+		return newLookupError(CodeInvalidArgument, fmt.Errorf("dataset too large for an MMDB file"))
+	case maxRecord >= 1<<28:
+		recordSize = 32
+	case maxRecord >= 1<<24:
+		recordSize = 28
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, node := range nodes {
+		if i%exportCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		left, right := record(node[0]), record(node[1])
+		switch recordSize {
+		case 24:
+			bw.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			bw.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24)<<4 | byte(right>>24)&0x0f,
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		default:
+			var rec [8]byte
+			binary.BigEndian.PutUint32(rec[0:], uint32(left))
+			binary.BigEndian.PutUint32(rec[4:], uint32(right))
+			bw.Write(rec[:])
+		}
+	}
+	bw.Write(make([]byte, 16))
+	bw.Write(data.Bytes())
+
+	var meta mmdbEncoder
+	meta.mapHeader(9)
+	meta.string("binary_format_major_version")
+	meta.uint(mmdbUint16, 2)
+	meta.string("binary_format_minor_version")
+	meta.uint(mmdbUint16, 0)
+	meta.string("build_epoch")
+	meta.uint(mmdbUint64, uint64(time.Now().Unix()))
+	meta.string("database_type")
+	meta.string(databaseType)
+	meta.string("description")
+	meta.mapHeader(1)
+	meta.string("en")
+	meta.string("Exported by ip2country")
+	meta.string("ip_version")
+	meta.uint(mmdbUint16, 4)
+	meta.string("languages")
+	meta.control(mmdbArray, 1)
+	meta.string("en")
+	meta.string("node_count")
+	meta.uint(mmdbUint32, nodeCount)
+	meta.string("record_size")
+	meta.uint(mmdbUint16, uint64(recordSize))
+	bw.WriteString(mmdbMetadataMarker)
+	bw.Write(meta.Bytes())
+	return bw.Flush()
+}
+
+// mmdbEncoder encodes values in the MaxMind DB data format.
+type mmdbEncoder struct {
+	bytes.Buffer
+}
+
+// control writes the control byte, extended type and size of a value.
+func (e *mmdbEncoder) control(typ, size int) {
+	var ctrl byte
+	if typ <= 7 {
+		ctrl = byte(typ) << 5
+	}
+	var ext []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		ext = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		ext = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		ctrl |= 31
+		ext = []byte{byte((size - 65821) >> 16), byte((size - 65821) >> 8), byte(size - 65821)}
+	}
+	e.WriteByte(ctrl)
+	if typ > 7 {
+		e.WriteByte(byte(typ - 7))
+	}
+	e.Write(ext)
+}
+
+func (e *mmdbEncoder) string(s string) {
+	e.control(mmdbString, len(s))
+	e.WriteString(s)
+}
+
+func (e *mmdbEncoder) uint(typ int, v uint64) {
+	n := (bits.Len64(v) + 7) / 8
+	e.control(typ, n)
+	for i := n - 1; i >= 0; i-- {
+		e.WriteByte(byte(v >> (8 * i)))
+	}
+}
+
+func (e *mmdbEncoder) mapHeader(pairs int) {
+	e.control(mmdbMap, pairs)
+}
+
+// country writes the record of a country, with its name as the English name if it
+// differs from the code.
+func (e *mmdbEncoder) country(name, code string) {
+	e.mapHeader(1)
+	e.string("country")
+	if name == "" || name == code {
+		e.mapHeader(1)
+	} else {
+		e.mapHeader(2)
+	}
+	e.string("iso_code")
+	e.string(code)
+	if name != "" && name != code {
+		e.string("names")
+		e.mapHeader(1)
+		e.string("en")
+		e.string(name)
+	}
+}
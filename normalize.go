@@ -0,0 +1,201 @@
+package ip2country
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// maxNormalizeErrors is the number of invalid lines reported in NormalizeStats.Errors.
+const maxNormalizeErrors = 100
+
+// NormalizeOptions holds options for NormalizeIPList.
+// Fields are ordered for optimal memory alignment.
+type NormalizeOptions struct {
+	// Delimiter separates the address from the rest of a line, which is kept as the
+	// entry's label, e.g. the country code of "1.2.3.4,US". Defaults to ",".
+	Delimiter string
+	// CommentPrefixes lists prefixes of lines to skip. Defaults to "#".
+	CommentPrefixes []string
+	// Aggregate merges overlapping and adjacent entries with the same label into the
+	// smallest set of CIDR prefixes covering them.
+	Aggregate bool
+	// Strict fails on the first invalid line instead of skipping it.
+	Strict bool
+}
+
+// NormalizeStats reports what NormalizeIPList did with its input.
+// Fields are ordered for optimal memory alignment.
+type NormalizeStats struct {
+	// Errors holds the first invalid lines, up to 100.
+	Errors []ParseError `json:"errors,omitempty"`
+	// Read is the number of entries read, excluding blank and comment lines.
+	Read int `json:"read"`
+	// Written is the number of entries written.
+	Written int `json:"written"`
+	// Duplicates is the number of entries dropped as exact duplicates.
+	Duplicates int `json:"duplicates"`
+	// Invalid is the number of lines skipped as invalid.
+	Invalid int `json:"invalid"`
+}
+
+// normalizedEntry is an address or network of the input with its label. Addresses are
+// /32 networks.
+type normalizedEntry struct {
+	label string
+	start uint32
+	bits  int
+}
+
+// end returns the last address of e.
+func (e normalizedEntry) end() uint32 {
+	return e.start | ^prefixMask(e.bits)
+}
+
+// NormalizeIPList reads a list of IPv4 addresses and CIDR networks, one per line and
+// optionally followed by a label such as a country code, and writes it deduplicated,
+// validated and sorted, with addresses in canonical dotted form and networks masked to
+// their prefix, e.g. "10.1.2.3/8" becomes "10.0.0.0/8". It is the usual preprocessing
+// step for a list to be loaded with NewExactIPCountryMap. Addresses are parsed like
+// dataset addresses, so the integer form is accepted. With opts.Aggregate, entries of
+// the same label are merged into minimal CIDR form.
+func NormalizeIPList(r io.Reader, w io.Writer, opts NormalizeOptions) (NormalizeStats, error) {
+	if opts.Delimiter == "" {
+		opts.Delimiter = ","
+	}
+	if opts.CommentPrefixes == nil {
+		opts.CommentPrefixes = []string{"#"}
+	}
+
+	var stats NormalizeStats
+	var entries []normalizedEntry
+	scanner, release := newLineScanner(r)
+	defer release()
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || isComment(line, opts.CommentPrefixes) {
+			continue
+		}
+		stats.Read++
+		entry, err := parseNormalizeLine(line, opts.Delimiter)
+		if err != nil {
+			perr := ParseError{Content: line, Err: err, Kind: classifyParseError(err), Line: lineNum}
+			if opts.Strict {
+				return stats, perr
+			}
+			stats.Invalid++
+			if len(stats.Errors) < maxNormalizeErrors {
+				stats.Errors = append(stats.Errors, perr)
+			}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("scanner error: %w", err)
+	}
+
+	sortNormalizedEntries(entries)
+	unique := entries[:0]
+	for i, e := range entries {
+		if i > 0 && e == entries[i-1] {
+			stats.Duplicates++
+			continue
+		}
+		unique = append(unique, e)
+	}
+	entries = unique
+	if opts.Aggregate {
+		entries = aggregateEntries(entries)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		prefix := netip.PrefixFrom(uint32ToAddr(e.start), e.bits)
+		if prefix.Bits() == 32 {
+			bw.WriteString(prefix.Addr().String())
+		} else {
+			bw.WriteString(prefix.String())
+		}
+		if e.label != "" {
+			bw.WriteString(opts.Delimiter)
+			bw.WriteString(e.label)
+		}
+		bw.WriteByte('\n')
+		stats.Written++
+	}
+	return stats, bw.Flush()
+}
+
+// parseNormalizeLine parses a line of NormalizeIPList input.
+func parseNormalizeLine(line, delimiter string) (normalizedEntry, error) {
+	addr, label, _ := strings.Cut(line, delimiter)
+	addr = strings.TrimSpace(addr)
+	entry := normalizedEntry{label: strings.TrimSpace(label), bits: 32}
+	if !strings.Contains(addr, "/") {
+		ipNum, err := parseIP(addr)
+		if err != nil {
+			return entry, fmt.Errorf("invalid IP %q: %w", addr, err)
+		}
+		entry.start = ipNum
+		return entry, nil
+	}
+	prefix, err := netip.ParsePrefix(addr)
+	if err != nil {
+		return entry, fmt.Errorf("%w %q: %w", errInvalidNetwork, addr, err)
+	}
+	if !prefix.Addr().Is4() {
+		return entry, fmt.Errorf("not an IPv4 network: %s: %w", addr, ErrIPv6Unsupported)
+	}
+	start := prefix.Masked().Addr().As4()
+	entry.start = binary.BigEndian.Uint32(start[:])
+	entry.bits = prefix.Bits()
+	return entry, nil
+}
+
+// sortNormalizedEntries sorts entries by start address, then from the largest network
+// to the smallest and by label.
+func sortNormalizedEntries(entries []normalizedEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.bits != b.bits {
+			return a.bits < b.bits
+		}
+		return a.label < b.label
+	})
+}
+
+// aggregateEntries merges the sorted entries of each label into the fewest prefixes
+// covering the same addresses, and returns them sorted.
+func aggregateEntries(entries []normalizedEntry) []normalizedEntry {
+	byLabel := make(map[string][]normalizedEntry)
+	for _, e := range entries {
+		byLabel[e.label] = append(byLabel[e.label], e)
+	}
+	var out []normalizedEntry
+	var prefixes []netip.Prefix
+	for label, group := range byLabel {
+		for i := 0; i < len(group); {
+			start, end := group[i].start, group[i].end()
+			for i++; i < len(group) && uint64(group[i].start) <= uint64(end)+1; i++ {
+				end = max(end, group[i].end())
+			}
+			prefixes = appendRangePrefixes(prefixes[:0], start, end)
+			for _, p := range prefixes {
+				addr := p.Addr().As4()
+				out = append(out, normalizedEntry{label: label, start: binary.BigEndian.Uint32(addr[:]), bits: p.Bits()})
+			}
+		}
+	}
+	sortNormalizedEntries(out)
+	return out
+}
@@ -0,0 +1,168 @@
+package ip2country
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OriginResolverConfig holds configuration for an OriginResolver.
+// Fields are ordered for optimal memory alignment.
+type OriginResolverConfig struct {
+	// Hosts maps host names, in lowercase, to the address they are served from, e.g.
+	// the upstreams of a reverse proxy. They are answered without DNS.
+	Hosts map[string]string
+	// Resolver, if set, resolves host names missing from Hosts to their first IPv4
+	// address. If nil, no DNS lookups are made and such hosts are not resolved.
+	Resolver *net.Resolver
+	// Timeout bounds each DNS lookup. Defaults to 1s.
+	Timeout time.Duration
+	// TTL is how long DNS answers, including failures, are cached. Defaults to 1m.
+	TTL time.Duration
+	// CacheSize is the maximum number of host names whose DNS answers are cached.
+	// Defaults to 1024.
+	CacheSize int
+	// MaxConcurrentLookups is the maximum number of DNS lookups in flight; hosts arriving
+	// while it is reached are not resolved. Defaults to 16.
+	MaxConcurrentLookups int
+}
+
+// originAnswer is a cached DNS answer of an OriginResolver.
+type originAnswer struct {
+	expires time.Time
+	addr    string // Empty if the lookup failed.
+}
+
+// OriginResolver maps the Host header of requests to the destination address, for
+// WithOriginCountry. The Host header is client-controlled, so host names are only
+// resolved from a static map or, if configured, with DNS lookups that are cached,
+// time-limited and bounded in number. It is safe for concurrent use.
+type OriginResolver struct {
+	hosts    map[string]string
+	resolver *net.Resolver
+	answers  map[string]originAnswer
+	slots    chan struct{}
+	timeout  time.Duration
+	ttl      time.Duration
+	size     int
+	mu       sync.Mutex
+}
+
+// NewOriginResolver returns an OriginResolver. It accepts an optional
+// OriginResolverConfig; without one, only address literals are resolved.
+func NewOriginResolver(config ...OriginResolverConfig) *OriginResolver {
+	var cfg OriginResolverConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = 1024
+	}
+	if cfg.MaxConcurrentLookups <= 0 {
+		cfg.MaxConcurrentLookups = 16
+	}
+	return &OriginResolver{
+		hosts:    cfg.Hosts,
+		resolver: cfg.Resolver,
+		answers:  make(map[string]originAnswer),
+		slots:    make(chan struct{}, cfg.MaxConcurrentLookups),
+		timeout:  cfg.Timeout,
+		ttl:      cfg.TTL,
+		size:     cfg.CacheSize,
+	}
+}
+
+// OriginIP returns the destination address of r from its Host header. Host names that
+// cannot be resolved are returned as is, so their lookup fails.
+func (o *OriginResolver) OriginIP(r *http.Request) string {
+	host := originHost(r)
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if addr, ok := o.hosts[name]; ok {
+		return addr
+	}
+	if o.resolver == nil {
+		return host
+	}
+	if addr, ok := o.cached(name); ok {
+		if addr == "" {
+			return host
+		}
+		return addr
+	}
+
+	select {
+	case o.slots <- struct{}{}:
+		defer func() { <-o.slots }()
+	default:
+		return host
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), o.timeout)
+	defer cancel()
+	addrs, err := o.resolver.LookupNetIP(ctx, "ip4", name)
+	if r.Context().Err() != nil {
+		// The request was canceled, which says nothing about the host.
+		return host
+	}
+	if err != nil || len(addrs) == 0 {
+		o.store(name, "")
+		return host
+	}
+	addr := addrs[0].Unmap().String()
+	o.store(name, addr)
+	return addr
+}
+
+// cached returns the unexpired DNS answer for name, if any.
+func (o *OriginResolver) cached(name string) (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	a, ok := o.answers[name]
+	if !ok || time.Now().After(a.expires) {
+		return "", false
+	}
+	return a.addr, true
+}
+
+// store caches the DNS answer for name, making room by dropping expired answers or, if
+// there are none, an arbitrary one.
+func (o *OriginResolver) store(name, addr string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.answers[name]; !ok && len(o.answers) >= o.size {
+		now := time.Now()
+		for n, a := range o.answers {
+			if now.After(a.expires) {
+				delete(o.answers, n)
+			}
+		}
+		for n := range o.answers {
+			if len(o.answers) < o.size {
+				break
+			}
+			delete(o.answers, n)
+		}
+	}
+	o.answers[name] = originAnswer{expires: time.Now().Add(o.ttl), addr: addr}
+}
+
+// originHost returns the host of the Host header of r, without port or brackets.
+func originHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.Trim(r.Host, "[]")
+	}
+	return host
+}
@@ -0,0 +1,167 @@
+package ip2country
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// overrideSet is an immutable set of runtime overrides, replaced whole on every change.
+type overrideSet struct {
+	codes map[netip.Prefix]string
+	bits  []int // Distinct prefix lengths in codes, longest first.
+}
+
+// newOverrideSet indexes codes by prefix length.
+func newOverrideSet(codes map[netip.Prefix]string) *overrideSet {
+	seen := make(map[int]bool)
+	o := &overrideSet{codes: codes}
+	for p := range codes {
+		if !seen[p.Bits()] {
+			seen[p.Bits()] = true
+			o.bits = append(o.bits, p.Bits())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(o.bits)))
+	return o
+}
+
+// match returns the most specific override containing ipNum as a cache entry. It is
+// safe to call on a nil set.
+func (o *overrideSet) match(ipNum uint32) (cacheEntry, bool) {
+	if o == nil {
+		return cacheEntry{}, false
+	}
+	for _, bits := range o.bits {
+		mask := prefixMask(bits)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], ipNum&mask)
+		if code, ok := o.codes[netip.PrefixFrom(netip.AddrFrom4(b), bits)]; ok {
+			return cacheEntry{
+				ip: ipNum, country: code, code: code,
+				startIP: ipNum & mask, endIP: ipNum | ^mask, found: true,
+			}, true
+		}
+	}
+	return cacheEntry{}, false
+}
+
+// parseOverridePrefix parses an IPv4 address or CIDR block into a masked prefix.
+func parseOverridePrefix(cidrOrIP string) (netip.Prefix, error) {
+	cidrOrIP = strings.TrimSpace(cidrOrIP)
+	if !strings.Contains(cidrOrIP, "/") {
+		ipNum, err := parseIP(cidrOrIP)
+		if err != nil {
+			return netip.Prefix{}, newLookupError(CodeInvalidIP, fmt.Errorf("%w: %w", ErrInvalidIP, err))
+		}
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], ipNum)
+		return netip.PrefixFrom(netip.AddrFrom4(b), 32), nil
+	}
+	prefix, err := netip.ParsePrefix(cidrOrIP)
+	if err != nil {
+		return netip.Prefix{}, newLookupError(CodeInvalidIP, fmt.Errorf("%w: %w", ErrInvalidIP, err))
+	}
+	if addr := prefix.Addr(); addr.Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+	}
+	if !prefix.Addr().Is4() {
+		return netip.Prefix{}, newLookupError(CodeInvalidIP, fmt.Errorf("%w: %q is not an IPv4 block", ErrInvalidIP, cidrOrIP))
+	}
+	return prefix.Masked(), nil
+}
+
+// SetOverride makes lookups of the address or CIDR block cidrOrIP, e.g. "203.0.113.7"
+// or "198.51.100.0/24", return code regardless of the dataset, so known-wrong entries
+// such as corporate egress addresses or VPN endpoints can be patched at runtime.
+// Overrides take precedence over the dataset and the cache; among overlapping
+// overrides, the most specific block wins. They are kept across reloads until removed.
+func (db *IPCountryDB) SetOverride(cidrOrIP, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return newLookupError(CodeInvalidArgument, fmt.Errorf("country code cannot be empty"))
+	}
+	prefix, err := parseOverridePrefix(cidrOrIP)
+	if err != nil {
+		return err
+	}
+	db.updateOverrides(func(codes map[netip.Prefix]string) { codes[prefix] = code })
+	return nil
+}
+
+// RemoveOverride removes the override set for cidrOrIP with SetOverride, which must be
+// given in the same form. Removing an override that does not exist is not an error.
+func (db *IPCountryDB) RemoveOverride(cidrOrIP string) error {
+	prefix, err := parseOverridePrefix(cidrOrIP)
+	if err != nil {
+		return err
+	}
+	db.updateOverrides(func(codes map[netip.Prefix]string) { delete(codes, prefix) })
+	return nil
+}
+
+// Overrides returns the overrides set with SetOverride by block.
+func (db *IPCountryDB) Overrides() map[netip.Prefix]string {
+	codes := make(map[netip.Prefix]string)
+	if o := db.overrides.Load(); o != nil {
+		for p, code := range o.codes {
+			codes[p] = code
+		}
+	}
+	return codes
+}
+
+// updateOverrides applies fn to a copy of the overrides and publishes the result.
+func (db *IPCountryDB) updateOverrides(fn func(codes map[netip.Prefix]string)) {
+	db.overrideMu.Lock()
+	defer db.overrideMu.Unlock()
+	codes := db.Overrides()
+	fn(codes)
+	db.overrides.Store(newOverrideSet(codes))
+}
+
+// SuppressCountry makes lookups whose answer is the country code fail with CodeNotFound
+// immediately, without a reload, e.g. as an emergency lever during an abuse incident.
+// With Config.DefaultCountryCode, such lookups return the default code instead, which
+// makes it the policy value for suppressed countries. Suppression applies to overrides
+// as well and is kept across reloads until lifted with UnsuppressCountry.
+func (db *IPCountryDB) SuppressCountry(code string) {
+	db.updateSuppressed(func(codes map[string]bool) { codes[strings.ToUpper(strings.TrimSpace(code))] = true })
+}
+
+// UnsuppressCountry lifts the suppression of the country code set with SuppressCountry.
+func (db *IPCountryDB) UnsuppressCountry(code string) {
+	db.updateSuppressed(func(codes map[string]bool) { delete(codes, strings.ToUpper(strings.TrimSpace(code))) })
+}
+
+// SuppressedCountries returns the suppressed country codes in alphabetical order.
+func (db *IPCountryDB) SuppressedCountries() []string {
+	var codes []string
+	if p := db.suppressed.Load(); p != nil {
+		for code := range *p {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// isSuppressed reports whether lookups of code are suppressed.
+func (db *IPCountryDB) isSuppressed(code string) bool {
+	p := db.suppressed.Load()
+	return p != nil && len(*p) > 0 && (*p)[strings.ToUpper(code)]
+}
+
+// updateSuppressed applies fn to a copy of the suppressed codes and publishes the result.
+func (db *IPCountryDB) updateSuppressed(fn func(codes map[string]bool)) {
+	db.overrideMu.Lock()
+	defer db.overrideMu.Unlock()
+	codes := make(map[string]bool)
+	for _, code := range db.SuppressedCountries() {
+		codes[code] = true
+	}
+	fn(codes)
+	db.suppressed.Store(&codes)
+}
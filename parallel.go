@@ -0,0 +1,171 @@
+package ip2country
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// parseChunkLines is the number of lines handed to a parse worker at once.
+const parseChunkLines = 4096
+
+// parseChunk is a run of consecutive data lines, with blank, comment and header lines
+// already removed.
+type parseChunk struct {
+	lines []string
+	nums  []int // Line numbers of lines, for parse errors.
+	seq   int
+	read  int64 // Lines read for the chunk, including removed ones.
+	bytes int64 // Bytes read for the chunk, including line breaks.
+}
+
+// parsedLine is the outcome of parsing one line.
+type parsedLine struct {
+	err     error
+	line    string
+	ipRange IPRange
+	num     int
+}
+
+// parsedChunk is a parsed parseChunk. err is set if parsing panicked.
+type parsedChunk struct {
+	err   error
+	lines []parsedLine
+	seq   int
+	read  int64
+	bytes int64
+}
+
+// parseLinesParallel reads lines from reader like parseLines, but parses them on
+// Config.ParseWorkers goroutines: one goroutine splits the input into chunks, the
+// workers parse them, and the calling goroutine passes the outcomes to handle in file
+// order, so results, line numbers and limits are the same as with a single goroutine.
+func (db *IPCountryDB) parseLinesParallel(ctx context.Context, reader io.Reader, skipHeader bool, progress *progressReporter, parse func(string) (*IPRange, error), handle lineHandler) error {
+	workers := db.config.ParseWorkers
+	chunks := make(chan parseChunk, workers)
+	results := make(chan parsedChunk, workers)
+	stop := make(chan struct{})
+	split := make(chan struct{})
+	// The reader must not be used once this returns, so wait for the splitter.
+	defer func() {
+		close(stop)
+		<-split
+	}()
+
+	var scanErr error
+	go func() {
+		defer close(split)
+		defer close(chunks)
+		scanErr = db.splitChunks(reader, skipHeader, chunks, stop)
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				select {
+				case results <- db.parseChunk(chunk, parse):
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Chunks finish out of order; pending holds those that arrived early.
+	pending := make(map[int]parsedChunk)
+	next := 0
+	for result := range results {
+		pending[result.seq] = result
+		for {
+			chunk, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if chunk.err != nil {
+				return chunk.err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			progress.add(chunk.read, chunk.bytes)
+			for i := range chunk.lines {
+				l := &chunk.lines[i]
+				done, err := handle(l.num, l.line, &l.ipRange, l.err)
+				if err != nil || done {
+					return err
+				}
+			}
+		}
+	}
+
+	if scanErr != nil {
+		return fmt.Errorf("scanner error: %w", scanErr)
+	}
+	return nil
+}
+
+// splitChunks reads lines from reader and sends them to chunks until the input ends or
+// stop is closed. It returns the error of the scanner.
+func (db *IPCountryDB) splitChunks(reader io.Reader, skipHeader bool, chunks chan<- parseChunk, stop <-chan struct{}) error {
+	scanner, release := newLineScanner(reader)
+	defer release()
+
+	var chunk parseChunk
+	send := func() bool {
+		select {
+		case chunks <- chunk:
+			chunk = parseChunk{seq: chunk.seq + 1}
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		chunk.read++
+		chunk.bytes += int64(len(scanner.Bytes())) + 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || (skipHeader && lineNum == 1) || isComment(line, db.config.CommentPrefixes) {
+			continue
+		}
+		chunk.lines = append(chunk.lines, line)
+		chunk.nums = append(chunk.nums, lineNum)
+		if len(chunk.lines) == parseChunkLines && !send() {
+			return nil
+		}
+	}
+	if chunk.read > 0 {
+		send()
+	}
+	return scanner.Err()
+}
+
+// parseChunk parses the lines of chunk, converting a panic into the chunk's error.
+func (db *IPCountryDB) parseChunk(chunk parseChunk, parse func(string) (*IPRange, error)) (result parsedChunk) {
+	result = parsedChunk{seq: chunk.seq, read: chunk.read, bytes: chunk.bytes}
+	defer recoverPanic(db.logger, db.config.Name, "parse", &db.panics, &result.err)
+
+	result.lines = make([]parsedLine, len(chunk.lines))
+	for i, line := range chunk.lines {
+		ipRange, err := parse(line)
+		result.lines[i] = parsedLine{err: err, line: line, num: chunk.nums[i]}
+		if err == nil {
+			result.lines[i].ipRange = *ipRange
+		}
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+package ip2country
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseErrorKind classifies why a line of a data file was rejected.
+type ParseErrorKind string
+
+const (
+	// ParseErrorFieldCount means the line has the wrong number of fields.
+	ParseErrorFieldCount ParseErrorKind = "field_count"
+	// ParseErrorInvalidIP means an address or network could not be parsed.
+	ParseErrorInvalidIP ParseErrorKind = "invalid_ip"
+	// ParseErrorInvalidRange means the start address is greater than the end address.
+	ParseErrorInvalidRange ParseErrorKind = "invalid_range"
+	// ParseErrorEmptyCode means the country code is missing.
+	ParseErrorEmptyCode ParseErrorKind = "empty_code"
+	// ParseErrorUnknownLocation means a GeoLite2 geoname_id has no known country.
+	ParseErrorUnknownLocation ParseErrorKind = "unknown_location"
+	// ParseErrorQuoting means a quoted field is not terminated or contains a stray quote.
+	ParseErrorQuoting ParseErrorKind = "quoting"
+	// ParseErrorHostBits means a CIDR network has bits set after the prefix length, e.g.
+	// "1.0.1.5/24", so it does not name the network it would be loaded as.
+	ParseErrorHostBits ParseErrorKind = "host_bits"
+	// ParseErrorMultiCode means the line lists several country codes and
+	// Config.MultiCodePolicy is MultiCodeReject.
+	ParseErrorMultiCode ParseErrorKind = "multi_code"
+	// ParseErrorUnsorted means the range starts before the previous one. It is only
+	// reported by ValidateCSVStream, as loads sort the ranges.
+	ParseErrorUnsorted ParseErrorKind = "unsorted"
+	// ParseErrorOverlap means the range overlaps the previous one. It is only reported
+	// by ValidateCSVStream; loads fail on overlapping ranges as a whole.
+	ParseErrorOverlap ParseErrorKind = "overlap"
+	// ParseErrorOther covers all other errors.
+	ParseErrorOther ParseErrorKind = "other"
+)
+
+// Errors wrapped by the line parsers so that ParseErrors can be classified by kind.
+var (
+	errFieldCount      = errors.New("incorrect number of fields")
+	errInvalidNetwork  = errors.New("invalid network")
+	errHostBits        = errors.New("host bits set in network")
+	errInvalidRange    = errors.New("invalid range")
+	errEmptyCode       = errors.New("country code cannot be empty")
+	errUnknownLocation = errors.New("unknown geoname_id")
+	errQuoting         = errors.New("malformed quoted field")
+	errMultiCode       = errors.New("several country codes")
+	errUnsorted        = errors.New("range out of order")
+	errOverlap         = errors.New("overlapping range")
+)
+
+// parseErrorKinds maps the wrapped line parser errors to their kinds.
+var parseErrorKinds = []struct {
+	err  error
+	kind ParseErrorKind
+}{
+	{errFieldCount, ParseErrorFieldCount},
+	{errInvalidIPFormat, ParseErrorInvalidIP},
+	{errInvalidNetwork, ParseErrorInvalidIP},
+	{errHostBits, ParseErrorHostBits},
+	{ErrIPv6Unsupported, ParseErrorInvalidIP},
+	{ErrIntegerOutOfRange, ParseErrorInvalidIP},
+	{errInvalidRange, ParseErrorInvalidRange},
+	{errEmptyCode, ParseErrorEmptyCode},
+	{errUnknownLocation, ParseErrorUnknownLocation},
+	{errQuoting, ParseErrorQuoting},
+	{errMultiCode, ParseErrorMultiCode},
+	{errUnsorted, ParseErrorUnsorted},
+	{errOverlap, ParseErrorOverlap},
+}
+
+// classifyParseError returns the kind of a line parser error.
+func classifyParseError(err error) ParseErrorKind {
+	for _, k := range parseErrorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return ParseErrorOther
+}
+
+// ParseErrorSummary aggregates all parse errors of a load, including those that were
+// not stored because Config.MaxStoredParseErrors was reached.
+type ParseErrorSummary struct {
+	// ByKind counts the errors per kind.
+	ByKind map[ParseErrorKind]int `json:"by_kind,omitempty"`
+	// Total is the number of rejected lines.
+	Total int `json:"total"`
+	// Dropped is the number of errors counted but not stored.
+	Dropped int `json:"dropped"`
+}
+
+// String returns a one-line summary, e.g. "1523 parse errors (1000 stored):
+// invalid_ip=1500, field_count=23".
+func (s ParseErrorSummary) String() string {
+	if s.Total == 0 {
+		return "no parse errors"
+	}
+	kinds := make([]string, 0, len(s.ByKind))
+	for kind, n := range s.ByKind {
+		kinds = append(kinds, fmt.Sprintf("%s=%d", kind, n))
+	}
+	sort.Strings(kinds)
+	return fmt.Sprintf("%d parse errors (%d stored): %s", s.Total, s.Total-s.Dropped, strings.Join(kinds, ", "))
+}
+
+// ErrTooManyParseErrors is returned by a load aborted because of rejected lines, see
+// Config.StrictParsing and Config.MaxParseErrors.
+var ErrTooManyParseErrors = errors.New("too many parse errors")
+
+// parseErrorCollector stores up to max parse errors and counts all of them by kind.
+type parseErrorCollector struct {
+	errors  []ParseError
+	summary ParseErrorSummary
+	max     int
+	abortAt int // Number of errors that aborts the load; 0 means no limit.
+}
+
+// newParseErrorCollector returns a collector applying the parse error limits of cfg.
+func newParseErrorCollector(cfg *Config) parseErrorCollector {
+	c := parseErrorCollector{max: cfg.MaxStoredParseErrors, abortAt: max(cfg.MaxParseErrors, 0)}
+	if cfg.StrictParsing {
+		c.abortAt = 1
+	}
+	return c
+}
+
+// add records the error for a rejected line. A max of 0 or less stores every error.
+// It returns an error wrapping ErrTooManyParseErrors once the abort limit is reached.
+func (c *parseErrorCollector) add(lineNum int, content string, err error) error {
+	kind := classifyParseError(err)
+	if c.summary.ByKind == nil {
+		c.summary.ByKind = make(map[ParseErrorKind]int)
+	}
+	c.summary.ByKind[kind]++
+	c.summary.Total++
+
+	if c.max > 0 && len(c.errors) >= c.max {
+		c.summary.Dropped++
+	} else {
+		c.errors = append(c.errors, ParseError{Line: lineNum, Content: content, Err: err, Kind: kind})
+	}
+
+	if c.abortAt > 0 && c.summary.Total >= c.abortAt {
+		return fmt.Errorf("%w: %d lines rejected, last at line %d: %w", ErrTooManyParseErrors, c.summary.Total, lineNum, err)
+	}
+	return nil
+}
@@ -2,25 +2,185 @@ package ip2country
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math/big"
 	"net"
+	"net/netip"
 	"strconv"
+	"strings"
 )
 
+var (
+	// ErrIPv6Unsupported is returned for IPv6 addresses, in text or integer form, that do
+	// not embed an IPv4 address. Only IPv4 datasets are supported.
+	ErrIPv6Unsupported = errors.New("IPv6 addresses are not supported")
+	// ErrIntegerOutOfRange is returned for integer-form addresses larger than the IPv6
+	// address space.
+	ErrIntegerOutOfRange = errors.New("integer IP exceeds the 128-bit address space")
+
+	errInvalidIPFormat = errors.New("invalid IP format")
+)
+
+// ipv4MappedHigh is the value of the upper 96 bits of an IPv4-mapped IPv6 address
+// (::ffff:a.b.c.d).
+var ipv4MappedHigh = big.NewInt(0xffff)
+
 // parseIP converts an IP address string into a 32-bit unsigned integer.
 // It supports both standard IPv4 dot-decimal notation (e.g., "8.8.8.8")
-// and integer string representation (e.g., "134744072").
+// and integer string representation (e.g., "134744072"). Integers beyond the IPv4
+// range are accepted if they encode an IPv4-mapped IPv6 address (::ffff:a.b.c.d).
 func parseIP(ipStr string) (uint32, error) {
 	if ip := net.ParseIP(ipStr); ip != nil {
 		if ip4 := ip.To4(); ip4 != nil {
 			return binary.BigEndian.Uint32(ip4), nil
 		}
-		return 0, fmt.Errorf("not an IPv4 address: %s", ipStr)
+		return 0, fmt.Errorf("not an IPv4 address: %s: %w", ipStr, ErrIPv6Unsupported)
 	}
 
-	if num, err := strconv.ParseUint(ipStr, 10, 32); err == nil {
+	num, err := strconv.ParseUint(ipStr, 10, 32)
+	if err == nil {
 		return uint32(num), nil
 	}
+	if errors.Is(err, strconv.ErrRange) {
+		return parseLargeInteger(ipStr)
+	}
+
+	return 0, fmt.Errorf("%w: %s", errInvalidIPFormat, ipStr)
+}
+
+// parseLookupIP parses an address passed to a lookup like parseIP, but rejects the
+// integer form if Config.RejectIntegerIPs is set and accepts the non-canonical
+// dotted forms selected by Config.IPv4Parsing.
+func parseLookupIP(ipStr string, cfg *Config) (uint32, error) {
+	integer := isDecimal(ipStr)
+	if cfg.RejectIntegerIPs && integer {
+		return 0, fmt.Errorf("%w: %s: integer-form addresses are not allowed", errInvalidIPFormat, ipStr)
+	}
+	// inet_aton reads a single number with a leading zero as octal, not decimal.
+	if cfg.IPv4Parsing == IPv4ParseInetAton && integer && len(ipStr) > 1 && ipStr[0] == '0' {
+		if ipNum, ok := parseNonCanonicalIPv4(ipStr, cfg.IPv4Parsing, true); ok {
+			return ipNum, nil
+		}
+		return 0, fmt.Errorf("%w: %s", errInvalidIPFormat, ipStr)
+	}
+
+	ipNum, err := parseIP(ipStr)
+	if err == nil || cfg.IPv4Parsing == IPv4ParseStrict {
+		return ipNum, err
+	}
+	if ipNum, ok := parseNonCanonicalIPv4(ipStr, cfg.IPv4Parsing, !cfg.RejectIntegerIPs); ok {
+		return ipNum, nil
+	}
+	return 0, err
+}
+
+// parseNonCanonicalIPv4 parses the dotted forms rejected by net.ParseIP. With
+// IPv4ParseDecimal it accepts four decimal parts with leading zeros. With
+// IPv4ParseInetAton it follows inet_aton: parts are decimal, octal with a leading 0 or
+// hexadecimal with a leading 0x, and with fewer than four parts the last one fills the
+// remaining bytes, e.g. "127.1" is 127.0.0.1. A single part is only accepted if
+// allowInteger is set.
+func parseNonCanonicalIPv4(s string, mode IPv4Parsing, allowInteger bool) (uint32, bool) {
+	var parts [4]uint64
+	n := 0
+	for {
+		field, rest, more := strings.Cut(s, ".")
+		if n == len(parts) {
+			return 0, false
+		}
+		v, ok := parseIPv4Part(field, mode)
+		if !ok {
+			return 0, false
+		}
+		parts[n] = v
+		n++
+		if !more {
+			break
+		}
+		s = rest
+	}
+	if (mode == IPv4ParseDecimal && n != 4) || (n == 1 && !allowInteger) {
+		return 0, false
+	}
+
+	var ipNum uint64
+	for i := 0; i < n-1; i++ {
+		if parts[i] > 0xff {
+			return 0, false
+		}
+		ipNum |= parts[i] << (24 - 8*i)
+	}
+	if last := parts[n-1]; last <= 1<<(8*(5-n))-1 {
+		return uint32(ipNum | last), true
+	}
+	return 0, false
+}
+
+// parseIPv4Part parses one part of a non-canonical dotted address.
+func parseIPv4Part(field string, mode IPv4Parsing) (uint64, bool) {
+	if field == "" {
+		return 0, false
+	}
+	base := 10
+	if mode == IPv4ParseInetAton {
+		switch {
+		case len(field) > 2 && (field[:2] == "0x" || field[:2] == "0X"):
+			field, base = field[2:], 16
+		case len(field) > 1 && field[0] == '0':
+			field, base = field[1:], 8
+		}
+	}
+	v, err := strconv.ParseUint(field, base, 32)
+	return v, err == nil
+}
 
-	return 0, fmt.Errorf("invalid IP format: %s", ipStr)
+// isDecimal reports whether s is a non-empty string of ASCII digits.
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// addrToUint32 converts an IPv4 or IPv4-mapped IPv6 address into a 32-bit unsigned
+// integer.
+func addrToUint32(addr netip.Addr) (uint32, error) {
+	if !addr.IsValid() {
+		return 0, fmt.Errorf("%w: zero netip.Addr", errInvalidIPFormat)
+	}
+	if addr = addr.Unmap(); !addr.Is4() {
+		return 0, fmt.Errorf("not an IPv4 address: %s: %w", addr, ErrIPv6Unsupported)
+	}
+	b := addr.As4()
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// netIPToUint32 converts a 4-byte or IPv4-mapped 16-byte net.IP into a 32-bit unsigned
+// integer.
+func netIPToUint32(ip net.IP) (uint32, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return binary.BigEndian.Uint32(ip4), nil
+	}
+	if len(ip) == net.IPv6len {
+		return 0, fmt.Errorf("not an IPv4 address: %s: %w", ip, ErrIPv6Unsupported)
+	}
+	return 0, fmt.Errorf("%w: %d-byte net.IP", errInvalidIPFormat, len(ip))
+}
+
+// parseLargeInteger classifies a decimal integer that does not fit into 32 bits.
+func parseLargeInteger(ipStr string) (uint32, error) {
+	num, ok := new(big.Int).SetString(ipStr, 10)
+	if !ok || num.BitLen() > 128 {
+		return 0, fmt.Errorf("invalid IP %s: %w", ipStr, ErrIntegerOutOfRange)
+	}
+	if high := new(big.Int).Rsh(num, 32); high.Cmp(ipv4MappedHigh) == 0 {
+		return uint32(num.Uint64()), nil
+	}
+	return 0, fmt.Errorf("integer IP %s is an IPv6 address: %w", ipStr, ErrIPv6Unsupported)
 }
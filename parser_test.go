@@ -0,0 +1,64 @@
+package ip2country
+
+import "testing"
+
+func TestParseLookupIP(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          IPv4Parsing
+		rejectInteger bool
+		in            string
+		want          uint32
+		ok            bool
+	}{
+		{"strict canonical", IPv4ParseStrict, false, "8.8.8.8", 0x08080808, true},
+		{"strict leading zero", IPv4ParseStrict, false, "010.1.1.1", 0, false},
+		{"strict hex", IPv4ParseStrict, false, "0x7f.1", 0, false},
+		{"strict short", IPv4ParseStrict, false, "127.1", 0, false},
+		{"strict integer", IPv4ParseStrict, false, "3232235777", 0xc0a80101, true},
+		{"strict integer rejected", IPv4ParseStrict, true, "3232235777", 0, false},
+		{"strict octal integer", IPv4ParseStrict, false, "017700000001", 0, false},
+
+		{"decimal leading zeros", IPv4ParseDecimal, false, "010.001.001.001", 0x0a010101, true},
+		{"decimal canonical", IPv4ParseDecimal, false, "192.168.1.1", 0xc0a80101, true},
+		{"decimal short", IPv4ParseDecimal, false, "127.1", 0, false},
+		{"decimal hex", IPv4ParseDecimal, false, "0x7f.0.0.1", 0, false},
+		{"decimal part overflow", IPv4ParseDecimal, false, "010.1.1.0256", 0, false},
+		{"decimal first part overflow", IPv4ParseDecimal, false, "256.1.1.1", 0, false},
+		{"decimal five parts", IPv4ParseDecimal, false, "01.2.3.4.5", 0, false},
+
+		{"inet_aton octal", IPv4ParseInetAton, false, "010.1.1.1", 0x08010101, true},
+		{"inet_aton hex", IPv4ParseInetAton, false, "0x7f.1", 0x7f000001, true},
+		{"inet_aton upper hex", IPv4ParseInetAton, false, "0X7F.0.0.0x1", 0x7f000001, true},
+		{"inet_aton two parts", IPv4ParseInetAton, false, "127.1", 0x7f000001, true},
+		{"inet_aton three parts", IPv4ParseInetAton, false, "127.0.1", 0x7f000001, true},
+		{"inet_aton 24-bit last part", IPv4ParseInetAton, false, "1.0xffffff", 0x01ffffff, true},
+		{"inet_aton 24-bit overflow", IPv4ParseInetAton, false, "1.0x1000000", 0, false},
+		{"inet_aton 16-bit last part", IPv4ParseInetAton, false, "1.2.65535", 0x0102ffff, true},
+		{"inet_aton 16-bit overflow", IPv4ParseInetAton, false, "1.2.65536", 0, false},
+		{"inet_aton leading part overflow", IPv4ParseInetAton, false, "0x100.1.1.1", 0, false},
+		{"inet_aton octal part overflow", IPv4ParseInetAton, false, "0400.1.1.1", 0, false},
+		{"inet_aton invalid octal", IPv4ParseInetAton, false, "08.1.1.1", 0, false},
+		{"inet_aton empty hex", IPv4ParseInetAton, false, "0x.1.1.1", 0, false},
+		{"inet_aton empty part", IPv4ParseInetAton, false, "1..2.3", 0, false},
+		{"inet_aton five parts", IPv4ParseInetAton, false, "1.2.3.4.5", 0, false},
+		{"inet_aton octal integer", IPv4ParseInetAton, false, "017700000001", 0x7f000001, true},
+		{"inet_aton octal integer rejected", IPv4ParseInetAton, true, "017700000001", 0, false},
+		{"inet_aton hex integer", IPv4ParseInetAton, false, "0x7f000001", 0x7f000001, true},
+		{"inet_aton hex integer rejected", IPv4ParseInetAton, true, "0x7f000001", 0, false},
+		{"inet_aton hex integer overflow", IPv4ParseInetAton, false, "0x100000000", 0, false},
+		{"inet_aton decimal integer overflow", IPv4ParseInetAton, false, "4294967296", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{IPv4Parsing: tt.mode, RejectIntegerIPs: tt.rejectInteger}
+			got, err := parseLookupIP(tt.in, &cfg)
+			if ok := err == nil; ok != tt.ok {
+				t.Fatalf("parseLookupIP(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			}
+			if tt.ok && got != tt.want {
+				t.Errorf("parseLookupIP(%q) = %#08x, want %#08x", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package ip2country
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// PolicyAction is the decision of a Policy.
+type PolicyAction string
+
+const (
+	// PolicyAllow allows the request.
+	PolicyAllow PolicyAction = "allow"
+	// PolicyDeny denies the request.
+	PolicyDeny PolicyAction = "deny"
+)
+
+// PolicyRule applies Action to the countries it matches: those listed in Countries,
+// located on one of Continents, or members of one of Groups.
+// Fields are ordered for optimal memory alignment.
+type PolicyRule struct {
+	// Action is applied to matching countries.
+	Action PolicyAction `json:"action"`
+	// Countries lists country codes, e.g. "KP".
+	Countries []string `json:"countries,omitempty"`
+	// Continents lists continent codes, e.g. ContinentEurope.
+	Continents []string `json:"continents,omitempty"`
+	// Groups lists group names, e.g. SanctionedGroup or a group of Config.Groups.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// PolicySpec is the declarative form of a Policy. Rules are evaluated in order and the
+// first matching rule decides; countries matched by none get Default.
+// Fields are ordered for optimal memory alignment.
+type PolicySpec struct {
+	// Default is the decision for countries no rule matches. It is required.
+	Default PolicyAction `json:"default"`
+	// Unknown is the decision for lookups that found no country. Defaults to Default.
+	Unknown PolicyAction `json:"unknown,omitempty"`
+	// Rules are evaluated in order.
+	Rules []PolicyRule `json:"rules"`
+}
+
+// Policy decides whether to allow traffic based on the country of a lookup, replacing
+// chains of country checks in user code. Its decisions are precomputed per country when
+// it is compiled, so evaluating it is a single map lookup. Policy is safe for concurrent
+// use.
+type Policy struct {
+	decisions map[string]PolicyAction
+	fallback  PolicyAction
+	unknown   PolicyAction
+}
+
+// CompilePolicy compiles spec into a Policy. groups defines the groups that rules may
+// refer to in addition to the built-in ones, e.g. Config.Groups.
+func CompilePolicy(spec PolicySpec, groups map[string][]string) (*Policy, error) {
+	if err := checkPolicyAction(spec.Default); err != nil {
+		return nil, fmt.Errorf("invalid default: %w", err)
+	}
+	if spec.Unknown == "" {
+		spec.Unknown = spec.Default
+	}
+	if err := checkPolicyAction(spec.Unknown); err != nil {
+		return nil, fmt.Errorf("invalid unknown: %w", err)
+	}
+
+	type compiledRule struct {
+		codes      map[string]struct{}
+		continents map[string]struct{}
+		action     PolicyAction
+	}
+	set := newGroupSet(groups)
+	codes := make(map[string]struct{}, len(countryCodes))
+	for code := range countryCodes {
+		codes[code] = struct{}{}
+	}
+	rules := make([]compiledRule, len(spec.Rules))
+	for i, rule := range spec.Rules {
+		if err := checkPolicyAction(rule.Action); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		r := compiledRule{codes: make(map[string]struct{}), continents: make(map[string]struct{}), action: rule.Action}
+		for _, code := range rule.Countries {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			if code == "" {
+				return nil, fmt.Errorf("rule %d: empty country code", i+1)
+			}
+			r.codes[code] = struct{}{}
+			codes[code] = struct{}{}
+		}
+		for _, continent := range rule.Continents {
+			continent = strings.ToUpper(strings.TrimSpace(continent))
+			if !isContinentCode(continent) {
+				return nil, fmt.Errorf("rule %d: unknown continent %q", i+1, continent)
+			}
+			r.continents[continent] = struct{}{}
+		}
+		for _, group := range rule.Groups {
+			members, ok := (*set)[group]
+			if !ok {
+				return nil, fmt.Errorf("rule %d: unknown group %q", i+1, group)
+			}
+			for code := range members {
+				if code != "" {
+					r.codes[code] = struct{}{}
+					codes[code] = struct{}{}
+				}
+			}
+		}
+		rules[i] = r
+	}
+
+	p := &Policy{decisions: make(map[string]PolicyAction, len(codes)), fallback: spec.Default, unknown: spec.Unknown}
+	for code := range codes {
+		action := spec.Default
+		continent := ContinentForCountry(code)
+		for _, r := range rules {
+			_, byCode := r.codes[code]
+			_, byContinent := r.continents[continent]
+			if byCode || byContinent {
+				action = r.action
+				break
+			}
+		}
+		p.decisions[code] = action
+	}
+	return p, nil
+}
+
+// checkPolicyAction returns an error unless action is PolicyAllow or PolicyDeny.
+func checkPolicyAction(action PolicyAction) error {
+	if action != PolicyAllow && action != PolicyDeny {
+		return fmt.Errorf("action must be %q or %q, got %q", PolicyAllow, PolicyDeny, action)
+	}
+	return nil
+}
+
+// isContinentCode reports whether code is one of the continent codes.
+func isContinentCode(code string) bool {
+	switch code {
+	case ContinentAfrica, ContinentAntarctica, ContinentAsia, ContinentEurope,
+		ContinentNorthAmerica, ContinentOceania, ContinentSouthAmerica:
+		return true
+	}
+	return false
+}
+
+// ParsePolicy compiles a policy written as statements separated by semicolons or line
+// breaks, e.g.
+//
+//	deny country in (KP, IR); deny group SANCTIONED
+//	allow continent EU
+//	default deny
+//
+// A rule is "allow" or "deny", followed by "country", "continent" or "group" and one
+// value or a parenthesized list. "default" sets PolicySpec.Default and "unknown" sets
+// PolicySpec.Unknown. Lines starting with "#" are comments. groups is passed to
+// CompilePolicy.
+func ParsePolicy(text string, groups map[string][]string) (*Policy, error) {
+	var spec PolicySpec
+	for lineNum, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, stmt := range strings.Split(line, ";") {
+			fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ", ",", " ").Replace(stmt))
+			if len(fields) == 0 {
+				continue
+			}
+			if err := parsePolicyStatement(&spec, fields); err != nil {
+				return nil, fmt.Errorf("line %d: %q: %w", lineNum+1, strings.TrimSpace(stmt), err)
+			}
+		}
+	}
+	return CompilePolicy(spec, groups)
+}
+
+// parsePolicyStatement adds the statement split into fields to spec.
+func parsePolicyStatement(spec *PolicySpec, fields []string) error {
+	keyword := strings.ToLower(fields[0])
+	switch keyword {
+	case "default", "unknown":
+		if len(fields) != 2 {
+			return fmt.Errorf("expected %s allow or %s deny", keyword, keyword)
+		}
+		action := PolicyAction(strings.ToLower(fields[1]))
+		if keyword == "default" {
+			spec.Default = action
+		} else {
+			spec.Unknown = action
+		}
+		return nil
+	case string(PolicyAllow), string(PolicyDeny):
+	default:
+		return fmt.Errorf("unknown keyword %q", fields[0])
+	}
+
+	if len(fields) < 3 {
+		return fmt.Errorf("expected %s country|continent|group values", keyword)
+	}
+	values := fields[2:]
+	if strings.EqualFold(values[0], "in") {
+		values = values[1:]
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("expected values after %s", fields[1])
+	}
+	rule := PolicyRule{Action: PolicyAction(keyword)}
+	switch strings.ToLower(fields[1]) {
+	case "country":
+		rule.Countries = values
+	case "continent":
+		rule.Continents = values
+	case "group":
+		rule.Groups = values
+	default:
+		return fmt.Errorf("unknown selector %q", fields[1])
+	}
+	spec.Rules = append(spec.Rules, rule)
+	return nil
+}
+
+// ParsePolicyJSON decodes a PolicySpec from JSON of the form
+//
+//	{"rules": [{"action": "deny", "groups": ["SANCTIONED"]},
+//	           {"action": "allow", "continents": ["EU"]}],
+//	 "default": "deny"}
+//
+// and compiles it. groups is passed to CompilePolicy.
+func ParsePolicyJSON(r io.Reader, groups map[string][]string) (*Policy, error) {
+	var spec PolicySpec
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to decode policy: %w", err)
+	}
+	return CompilePolicy(spec, groups)
+}
+
+// LoadPolicy reads a policy from a file, in JSON if it starts with "{" (see
+// ParsePolicyJSON) and in the statement syntax of ParsePolicy otherwise.
+func LoadPolicy(filePath string, groups map[string][]string) (*Policy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return ParsePolicyJSON(bytes.NewReader(data), groups)
+	}
+	return ParsePolicy(string(data), groups)
+}
+
+// Decide returns the decision for a country code. An empty code, as for a lookup that
+// found no country, gets PolicySpec.Unknown.
+func (p *Policy) Decide(code string) PolicyAction {
+	if action, ok := p.decisions[code]; ok {
+		return action
+	}
+	if code == "" {
+		return p.unknown
+	}
+	if action, ok := p.decisions[strings.ToUpper(code)]; ok {
+		return action
+	}
+	return p.fallback
+}
+
+// Evaluate returns the decision for the country of a lookup result. A nil result gets
+// PolicySpec.Unknown.
+func (p *Policy) Evaluate(res *Result) PolicyAction {
+	if res == nil {
+		return p.unknown
+	}
+	return p.Decide(res.Code)
+}
@@ -0,0 +1,185 @@
+package ip2country
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// RangeStore holds the ranges of a dataset and finds the range containing an address.
+// Setting Config.NewRangeStore replaces the built-in storage and search indexes of
+// IPCountryDB with another structure, such as an ART or LC-trie or a B-tree, without
+// changes to the lookup API.
+//
+// A load fills a new store from a single goroutine as the dataset is parsed and only
+// then puts it into service; from then on Find, Len and Iterate are called concurrently
+// and the store is never modified again. The store is the only copy of the dataset a
+// load keeps, so features reading the whole dataset, such as Export and Coverage, use
+// Iterate. A store should reject overlapping ranges; the load checks for them anyway.
+type RangeStore interface {
+	// Insert adds a single range, in any order relative to the ranges already held.
+	Insert(r IPRange) error
+	// Bulk adds ranges sorted by start address, all starting after the ranges already
+	// held. Loads pass ranges that arrive in order to Bulk, in batches, and the others
+	// to Insert.
+	Bulk(ranges []IPRange) error
+	// Find returns the range containing ipNum.
+	Find(ipNum uint32) (IPRange, bool)
+	// Len returns the number of ranges held.
+	Len() int
+	// Iterate calls fn for every range in ascending order of start address, until fn
+	// returns false.
+	Iterate(fn func(IPRange) bool)
+}
+
+// rangeStoreBatch is the number of ranges a load passes to RangeStore.Bulk at once.
+const rangeStoreBatch = 4096
+
+// NewSortedRangeStore returns an empty store of the kind IPCountryDB uses when
+// Config.NewRangeStore is nil: ranges in sorted parallel arrays, with each distinct
+// country label stored once, found by binary search. It is safe for concurrent use
+// once filled.
+func NewSortedRangeStore() RangeStore {
+	return newRangeColumns(0)
+}
+
+// Insert adds r at its sorted position, failing if it overlaps a neighbouring range.
+func (c *rangeColumns) Insert(r IPRange) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if !c.sorted {
+		sort.Sort(c)
+		c.sorted = true
+	}
+	i := sort.Search(len(c.starts), func(i int) bool { return c.starts[i] > r.StartIP })
+	if i > 0 && c.ends[i-1] >= r.StartIP {
+		return fmt.Errorf("range [%d-%d] overlaps [%d-%d]", r.StartIP, r.EndIP, c.starts[i-1], c.ends[i-1])
+	}
+	if i < len(c.starts) && c.starts[i] <= r.EndIP {
+		return fmt.Errorf("range [%d-%d] overlaps [%d-%d]", r.StartIP, r.EndIP, c.starts[i], c.ends[i])
+	}
+	if err := c.add(r); err != nil {
+		return err
+	}
+	// add appended the range; rotate it into place.
+	last := len(c.starts) - 1
+	copy(c.starts[i+1:], c.starts[i:last])
+	copy(c.ends[i+1:], c.ends[i:last])
+	id := c.labelIDs[last]
+	copy(c.labelIDs[i+1:], c.labelIDs[i:last])
+	c.starts[i], c.ends[i], c.labelIDs[i] = r.StartIP, r.EndIP, id
+	c.sorted = true
+	return nil
+}
+
+// Bulk appends ranges, sorting the columns again if they were not added in order.
+func (c *rangeColumns) Bulk(ranges []IPRange) error {
+	for _, r := range ranges {
+		if err := c.add(r); err != nil {
+			return err
+		}
+	}
+	if !c.sorted {
+		sort.Sort(c)
+		c.sorted = true
+	}
+	return nil
+}
+
+// Find returns the range containing ipNum.
+func (c *rangeColumns) Find(ipNum uint32) (IPRange, bool) {
+	if i := branchlessUpperBound(c.starts, ipNum); i > 0 && c.ends[i-1] >= ipNum {
+		return c.at(i - 1), true
+	}
+	return IPRange{}, false
+}
+
+// Iterate calls fn for every range in order until fn returns false.
+func (c *rangeColumns) Iterate(fn func(IPRange) bool) {
+	for i := range c.starts {
+		if !fn(c.at(i)) {
+			return
+		}
+	}
+}
+
+// rangeStoreWriter fills a RangeStore with the ranges of a load as they are parsed,
+// passing runs of ranges sorted by start address to Bulk and the others to Insert.
+type rangeStoreWriter struct {
+	store RangeStore
+	batch []IPRange
+	last  uint32 // Highest start address passed to the store or batched.
+	count int
+}
+
+// newRangeStoreWriter returns a writer filling store.
+func newRangeStoreWriter(store RangeStore) *rangeStoreWriter {
+	return &rangeStoreWriter{store: store}
+}
+
+// add passes r to the store, batching it if it starts after every range so far.
+func (w *rangeStoreWriter) add(r IPRange) error {
+	w.count++
+	if w.count == 1 || r.StartIP > w.last {
+		w.last = r.StartIP
+		w.batch = append(w.batch, r)
+		if len(w.batch) < rangeStoreBatch {
+			return nil
+		}
+		return w.flush()
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.store.Insert(r); err != nil {
+		return fmt.Errorf("range store: %w", err)
+	}
+	return nil
+}
+
+// flush passes the batched ranges to the store.
+func (w *rangeStoreWriter) flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	err := w.store.Bulk(w.batch)
+	w.batch = w.batch[:0]
+	if err != nil {
+		return fmt.Errorf("range store: %w", err)
+	}
+	return nil
+}
+
+// finish passes the remaining ranges to the store and checks that it holds them all.
+func (w *rangeStoreWriter) finish() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if got := w.store.Len(); got != w.count {
+		return fmt.Errorf("range store holds %d of %d ranges", got, w.count)
+	}
+	return nil
+}
+
+// fillFromMapped copies the ranges of an index file into columns or, if it is nil,
+// store.
+func fillFromMapped(ctx context.Context, m *mappedRanges, columns *rangeColumns, store *rangeStoreWriter) error {
+	for i := range m.len() {
+		if i%rangeStoreBatch == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		var err error
+		if columns != nil {
+			err = columns.add(m.at(i))
+		} else {
+			err = store.add(m.at(i))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
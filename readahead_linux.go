@@ -0,0 +1,24 @@
+//go:build linux && (amd64 || arm64 || loong64 || riscv64)
+
+package ip2country
+
+import (
+	"os"
+	"syscall"
+)
+
+// Advice values of posix_fadvise.
+const (
+	fadviseSequential = 2
+	fadviseWillNeed   = 3
+)
+
+// adviseReadahead tells the kernel that file is about to be read sequentially from
+// start to end, so it starts reading ahead before the parser asks for the data. On
+// busy hosts this keeps reloads of large files from stalling on disk reads. Errors are
+// ignored; the advice is only a hint.
+func adviseReadahead(file *os.File) {
+	fd := file.Fd()
+	syscall.Syscall6(syscall.SYS_FADVISE64, fd, 0, 0, fadviseSequential, 0, 0)
+	syscall.Syscall6(syscall.SYS_FADVISE64, fd, 0, 0, fadviseWillNeed, 0, 0)
+}
@@ -0,0 +1,8 @@
+//go:build !(linux && (amd64 || arm64 || loong64 || riscv64))
+
+package ip2country
+
+import "os"
+
+// adviseReadahead is a no-op on platforms without posix_fadvise support here.
+func adviseReadahead(file *os.File) {}
@@ -0,0 +1,21 @@
+package ip2country
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// recoverPanic converts a panic raised during op into an error stored in *errp, so a
+// corrupt dataset or an index bug cannot take down the embedding process. The panic is
+// counted and logged with its stack trace. It must be called directly via defer.
+func recoverPanic(logger *slog.Logger, name, op string, counter *atomic.Int64, errp *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	counter.Add(1)
+	logger.Error("recovered panic", "op", op, "panic", v, "stack", string(debug.Stack()))
+	*errp = newLookupError(CodeInternal, namedError(name, fmt.Errorf("recovered panic in %s: %v", op, v)))
+}
@@ -0,0 +1,340 @@
+package ip2country
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Paths served by NewHTTPHandler and used by RemoteLookup.
+const (
+	lookupPath = "/lookup"
+	healthPath = "/healthz"
+	statsPath  = "/stats"
+)
+
+// lookupResponse is the JSON body returned by the lookup endpoint.
+type lookupResponse struct {
+	IP        string    `json:"ip"`
+	Code      string    `json:"code,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode ErrorCode `json:"error_code,omitempty"`
+}
+
+// NewHTTPHandler returns an http.Handler that serves lookups from the given database,
+// so it can run as a geo sidecar queried by RemoteLookup. It serves:
+//
+//	GET /lookup?ip=8.8.8.8  -> 200 {"ip":"8.8.8.8","code":"US"}; errors carry an
+//	                           "error_code" and the status given by HTTPStatus, e.g.
+//	                           404 if not found and 400 if invalid
+//	GET /healthz            -> 200 once the dataset is loaded, 503 otherwise
+//	GET /stats              -> 200 with the JSON-encoded Stats, including the live dataset's
+//	                           source, checksum and provider
+//
+// Lookups that fail because the dataset could not be loaded are answered with 503 so
+// that clients retry on another replica.
+func NewHTTPHandler(lookup IPCountryLookup) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(lookupPath, func(w http.ResponseWriter, r *http.Request) {
+		ip := r.URL.Query().Get("ip")
+		code, err := lookup.GetCountryCodeWithContext(r.Context(), ip)
+
+		resp := lookupResponse{IP: ip, Code: code}
+		if err != nil {
+			resp.Error = err.Error()
+			resp.ErrorCode = ErrorCodeOf(err)
+		}
+		status := HTTPStatus(resp.ErrorCode)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc(statsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lookup.Stats())
+	})
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
+		if lookup.Stats().LastUpdate.IsZero() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+// RemoteConfig holds configuration for a RemoteLookup client.
+// Fields are ordered for optimal memory alignment.
+type RemoteConfig struct {
+	// Client is the HTTP client used for requests. If nil, a client with a 2s timeout is used.
+	Client *http.Client
+	// Endpoints are the base URLs of the replicas, e.g. "http://geo-1:8080".
+	Endpoints []string
+	// HedgeDelay, if positive, sends a second request to another replica when the first
+	// has not answered within this duration; the first response wins.
+	HedgeDelay time.Duration
+	// HealthCheckInterval, if positive, probes every replica's /healthz endpoint at this
+	// interval. Replicas failing a probe or a request are skipped until a probe succeeds
+	// again. Without health checks, every replica stays in rotation and failed requests
+	// are only retried elsewhere, see Retries.
+	HealthCheckInterval time.Duration
+	// Retries is the number of additional replicas tried after a transport error or a
+	// server error. Not-found answers are never retried.
+	Retries int
+}
+
+// remoteEndpoint tracks the health of a single replica.
+type remoteEndpoint struct {
+	baseURL string
+	healthy atomic.Bool
+}
+
+// RemoteLookup implements the IPCountryLookup interface by querying one or more replicas
+// of a lookup service exposed with NewHTTPHandler. It balances requests round-robin over
+// healthy replicas, retries failed requests on other replicas and can hedge slow requests.
+type RemoteLookup struct {
+	client    *http.Client
+	endpoints []*remoteEndpoint
+	next      atomic.Uint64
+	config    RemoteConfig
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// errRemoteUnavailable is returned when a replica fails to produce an answer.
+var errRemoteUnavailable = errors.New("remote endpoint unavailable")
+
+// NewRemoteLookup creates a client for the given replicas and starts health checking
+// if configured. Call Close to stop the health checker.
+func NewRemoteLookup(config RemoteConfig) (*RemoteLookup, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("at least one endpoint is required")
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+
+	rl := &RemoteLookup{
+		client: client,
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	for _, raw := range config.Endpoints {
+		if _, err := url.Parse(raw); err != nil {
+			return nil, fmt.Errorf("invalid endpoint %q: %w", raw, err)
+		}
+		ep := &remoteEndpoint{baseURL: strings.TrimRight(raw, "/")}
+		ep.healthy.Store(true)
+		rl.endpoints = append(rl.endpoints, ep)
+	}
+
+	if config.HealthCheckInterval > 0 {
+		go rl.healthLoop(config.HealthCheckInterval)
+	}
+	return rl, nil
+}
+
+// Close stops background health checking. It is safe to call more than once.
+func (rl *RemoteLookup) Close() error {
+	rl.stopOnce.Do(func() { close(rl.stop) })
+	return nil
+}
+
+// healthLoop periodically probes every endpoint until Close is called.
+func (rl *RemoteLookup) healthLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rl.stop:
+			return
+		case <-ticker.C:
+			for _, ep := range rl.endpoints {
+				ep.healthy.Store(rl.probe(ep))
+			}
+		}
+	}
+}
+
+// probe reports whether the endpoint's health check succeeds.
+func (rl *RemoteLookup) probe(ep *remoteEndpoint) bool {
+	resp, err := rl.client.Get(ep.baseURL + healthPath)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// pick returns the next endpoint in round-robin order, preferring healthy ones.
+// If every endpoint is unhealthy, it falls back to plain round-robin.
+func (rl *RemoteLookup) pick() *remoteEndpoint {
+	n := len(rl.endpoints)
+	start := rl.next.Add(1)
+	for i := 0; i < n; i++ {
+		ep := rl.endpoints[(start+uint64(i))%uint64(n)]
+		if ep.healthy.Load() {
+			return ep
+		}
+	}
+	return rl.endpoints[start%uint64(n)]
+}
+
+// query performs a single lookup request against one endpoint.
+func (rl *RemoteLookup) query(ctx context.Context, ep *remoteEndpoint, ipStr string) (string, error) {
+	reqURL := ep.baseURL + lookupPath + "?ip=" + url.QueryEscape(ipStr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := rl.client.Do(req)
+	if err != nil {
+		// Only the health checker can bring a replica back into rotation.
+		if ctx.Err() == nil && rl.config.HealthCheckInterval > 0 {
+			ep.healthy.Store(false)
+		}
+		return "", newLookupError(failureCode(err, CodeUnavailable), fmt.Errorf("%w: %v", errRemoteUnavailable, err))
+	}
+	defer resp.Body.Close()
+
+	var body lookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", newLookupError(CodeUnavailable, fmt.Errorf("%w: invalid response: %v", errRemoteUnavailable, err))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return body.Code, nil
+	case resp.StatusCode == http.StatusNotFound:
+		msg := body.Error
+		if msg == "" {
+			msg = ErrNotFound.Error()
+		}
+		return "", newServerError(remoteErrorCode(body, CodeNotFound), msg)
+	case resp.StatusCode == http.StatusBadRequest:
+		return "", newServerError(remoteErrorCode(body, CodeInvalidIP), body.Error)
+	default:
+		return "", newLookupError(CodeUnavailable,
+			fmt.Errorf("%w: %s returned status %d", errRemoteUnavailable, ep.baseURL, resp.StatusCode))
+	}
+}
+
+// remoteErrorCode returns the error code reported by a replica, or fallback for
+// replicas that predate error codes.
+func remoteErrorCode(body lookupResponse, fallback ErrorCode) ErrorCode {
+	if body.ErrorCode != "" {
+		return body.ErrorCode
+	}
+	return fallback
+}
+
+// hedgedQuery sends a request and, if HedgeDelay elapses first, a second one to another
+// endpoint. It returns the first definitive answer.
+func (rl *RemoteLookup) hedgedQuery(ctx context.Context, ipStr string) (string, error) {
+	if rl.config.HedgeDelay <= 0 || len(rl.endpoints) < 2 {
+		return rl.query(ctx, rl.pick(), ipStr)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		code string
+		err  error
+	}
+	answers := make(chan answer, 2)
+	send := func() {
+		code, err := rl.query(ctx, rl.pick(), ipStr)
+		answers <- answer{code, err}
+	}
+
+	go send()
+	timer := time.NewTimer(rl.config.HedgeDelay)
+	defer timer.Stop()
+
+	inflight := 1
+	var last answer
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			inflight++
+			go send()
+		case a := <-answers:
+			inflight--
+			if !errors.Is(a.err, errRemoteUnavailable) {
+				return a.code, a.err
+			}
+			last = a
+		}
+	}
+	return last.code, last.err
+}
+
+// GetCountry retrieves the country code for a given IP address string.
+func (rl *RemoteLookup) GetCountry(ipStr string) (string, error) {
+	return rl.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryWithContext retrieves the country code, respecting the context.
+func (rl *RemoteLookup) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	return rl.GetCountryCodeWithContext(ctx, ipStr)
+}
+
+// GetCountryCode retrieves the country code for a given IP address string.
+func (rl *RemoteLookup) GetCountryCode(ipStr string) (string, error) {
+	return rl.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryCodeWithContext retrieves the country code from the remote replicas,
+// retrying on other replicas up to RemoteConfig.Retries times.
+func (rl *RemoteLookup) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
+	var err error
+	for attempt := 0; attempt <= rl.config.Retries; attempt++ {
+		var code string
+		code, err = rl.hedgedQuery(ctx, ipStr)
+		if !errors.Is(err, errRemoteUnavailable) || ctx.Err() != nil {
+			return code, err
+		}
+	}
+	return "", err
+}
+
+// Stats returns an empty Stats value; each replica reports its own dataset statistics.
+func (rl *RemoteLookup) Stats() Stats {
+	return Stats{}
+}
+
+// HealthyEndpoints returns the number of replicas currently considered healthy.
+func (rl *RemoteLookup) HealthyEndpoints() int {
+	healthy := 0
+	for _, ep := range rl.endpoints {
+		if ep.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// Reload is not supported by a remote lookup; the replicas manage their own datasets.
+func (rl *RemoteLookup) Reload() error {
+	return rl.ReloadWithContext(context.Background())
+}
+
+// ReloadWithContext is not supported by a remote lookup and always returns an error.
+func (rl *RemoteLookup) ReloadWithContext(ctx context.Context) error {
+	return newLookupError(CodeUnsupported, fmt.Errorf("reload is not supported by a remote lookup"))
+}
@@ -0,0 +1,47 @@
+package ip2country
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// SelfBenchmark reports the lookup performance measured right after a load with
+// Config.SelfBenchmarkLookups, so a deployment can check at startup whether the host and
+// dataset combination meets its latency targets.
+// Fields are ordered for optimal memory alignment.
+type SelfBenchmark struct {
+	// Lookups is the number of lookups performed.
+	Lookups int `json:"lookups"`
+	// QPS is the throughput achieved by a single goroutine.
+	QPS float64 `json:"qps"`
+	// P50 is the median lookup latency.
+	P50 time.Duration `json:"p50"`
+	// P99 is the 99th percentile lookup latency.
+	P99 time.Duration `json:"p99"`
+	// Max is the slowest lookup.
+	Max time.Duration `json:"max"`
+}
+
+// runSelfBenchmark times n lookups of uniformly random addresses against the dataset
+// index, bypassing the cache, on the calling goroutine.
+func runSelfBenchmark(n int, lookup func(ipNum uint32) bool) *SelfBenchmark {
+	latencies := make([]time.Duration, n)
+	began := time.Now()
+	for i := range latencies {
+		ipNum := rand.Uint32()
+		t := time.Now()
+		lookup(ipNum)
+		latencies[i] = time.Since(t)
+	}
+	elapsed := time.Since(began)
+
+	slices.Sort(latencies)
+	return &SelfBenchmark{
+		Lookups: n,
+		QPS:     float64(n) / elapsed.Seconds(),
+		P50:     latencies[n/2],
+		P99:     latencies[n*99/100],
+		Max:     latencies[n-1],
+	}
+}
@@ -0,0 +1,146 @@
+package ip2country
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// ShadowConfig holds configuration for a ShadowLookup.
+// Fields are ordered for optimal memory alignment.
+type ShadowConfig struct {
+	// OnMismatch is called with each sampled lookup on which the two datasets disagree.
+	// It runs on the lookup path, so it should return quickly, e.g. by logging or
+	// incrementing a metric.
+	OnMismatch func(ShadowMismatch)
+	// SampleRate is the fraction of lookups, between 0 and 1, also sent to the shadow
+	// dataset and compared. Defaults to 1, comparing every lookup.
+	SampleRate float64
+}
+
+// ShadowMismatch describes a lookup on which the primary and shadow datasets disagree.
+// Fields are ordered for optimal memory alignment.
+type ShadowMismatch struct {
+	// PrimaryErr is the error of the primary dataset, if any.
+	PrimaryErr error
+	// ShadowErr is the error of the shadow dataset, if any.
+	ShadowErr error
+	// IP is the address as looked up.
+	IP string
+	// Primary is the country code served from the primary dataset.
+	Primary string
+	// Shadow is the country code of the shadow dataset.
+	Shadow string
+}
+
+// ShadowStats reports how often the datasets of a ShadowLookup disagree.
+type ShadowStats struct {
+	// Lookups is the number of lookups served.
+	Lookups int64 `json:"lookups"`
+	// Compared is the number of sampled lookups also sent to the shadow dataset.
+	Compared int64 `json:"compared"`
+	// Mismatches is the number of compared lookups on which the datasets disagreed.
+	Mismatches int64 `json:"mismatches"`
+}
+
+// ShadowLookup serves lookups from a primary dataset while comparing a sample of them
+// against a shadow dataset, e.g. the data of a provider being migrated to. Callers
+// always get the primary answer; disagreements are reported to ShadowConfig.OnMismatch
+// and counted in Stats, so the new dataset can be evaluated on real traffic before it
+// is switched over. Two answers agree if they have the same country code, or fail with
+// the same error code. ShadowLookup implements CountryReader and is safe for concurrent
+// use if its datasets are.
+type ShadowLookup struct {
+	primary    CountryReader
+	shadow     CountryReader
+	onMismatch func(ShadowMismatch)
+	sampleRate float64
+	lookups    atomic.Int64
+	compared   atomic.Int64
+	mismatches atomic.Int64
+}
+
+// NewShadowLookup returns a ShadowLookup serving lookups from primary and comparing
+// them against shadow. It accepts an optional ShadowConfig.
+func NewShadowLookup(primary, shadow CountryReader, config ...ShadowConfig) *ShadowLookup {
+	var cfg ShadowConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	return &ShadowLookup{
+		primary:    primary,
+		shadow:     shadow,
+		onMismatch: cfg.OnMismatch,
+		sampleRate: cfg.SampleRate,
+	}
+}
+
+// GetCountry retrieves the country code for a given IP address string.
+func (s *ShadowLookup) GetCountry(ipStr string) (string, error) {
+	return s.GetCountryWithContext(context.Background(), ipStr)
+}
+
+// GetCountryWithContext retrieves the country code from the primary dataset.
+func (s *ShadowLookup) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	return s.lookup(ctx, ipStr, func(l CountryReader) (string, error) {
+		return l.GetCountryWithContext(ctx, ipStr)
+	})
+}
+
+// GetCountryCode retrieves the country code for a given IP address string.
+func (s *ShadowLookup) GetCountryCode(ipStr string) (string, error) {
+	return s.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryCodeWithContext retrieves the country code from the primary dataset.
+func (s *ShadowLookup) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
+	return s.lookup(ctx, ipStr, func(l CountryReader) (string, error) {
+		return l.GetCountryCodeWithContext(ctx, ipStr)
+	})
+}
+
+// Stats returns the number of lookups served, compared and found to disagree.
+func (s *ShadowLookup) Stats() ShadowStats {
+	return ShadowStats{
+		Lookups:    s.lookups.Load(),
+		Compared:   s.compared.Load(),
+		Mismatches: s.mismatches.Load(),
+	}
+}
+
+// lookup returns the answer of the primary dataset, comparing it with the shadow
+// dataset if the lookup is sampled. Canceled lookups are not compared, since the
+// datasets would only disagree on when they noticed.
+func (s *ShadowLookup) lookup(ctx context.Context, ipStr string, lookup func(CountryReader) (string, error)) (string, error) {
+	s.lookups.Add(1)
+	code, err := lookup(s.primary)
+	if s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return code, err
+	}
+	if ErrorCodeOf(err) == CodeCanceled || ctx.Err() != nil {
+		return code, err
+	}
+
+	shadowCode, shadowErr := lookup(s.shadow)
+	if ErrorCodeOf(shadowErr) == CodeCanceled {
+		return code, err
+	}
+	s.compared.Add(1)
+	if code == shadowCode && ErrorCodeOf(err) == ErrorCodeOf(shadowErr) {
+		return code, err
+	}
+	s.mismatches.Add(1)
+	if s.onMismatch != nil {
+		s.onMismatch(ShadowMismatch{
+			PrimaryErr: err,
+			ShadowErr:  shadowErr,
+			IP:         ipStr,
+			Primary:    code,
+			Shadow:     shadowCode,
+		})
+	}
+	return code, err
+}
@@ -0,0 +1,77 @@
+package ip2country
+
+import "sync"
+
+// mapShardBits is the log2 of the number of shards of a shardedMap.
+const mapShardBits = 6
+
+// mapShardCount is the number of shards of a shardedMap.
+const mapShardCount = 1 << mapShardBits
+
+// mapShard is a single lock-protected partition of a shardedMap.
+type mapShard struct {
+	mu sync.RWMutex
+	m  map[uint32]string
+}
+
+// shardedMap is a concurrent map from IPv4 addresses to country codes. Writes lock a
+// single shard, so frequent updates contend only with reads of the same shard.
+type shardedMap struct {
+	shards [mapShardCount]mapShard
+}
+
+// newShardedMap creates an empty shardedMap.
+func newShardedMap() *shardedMap {
+	s := &shardedMap{}
+	for i := range s.shards {
+		s.shards[i].m = make(map[uint32]string)
+	}
+	return s
+}
+
+// shard returns the shard for ip. Fibonacci hashing spreads adjacent addresses, which
+// often arrive together, across different shards.
+func (s *shardedMap) shard(ip uint32) *mapShard {
+	return &s.shards[(ip*2654435769)>>(32-mapShardBits)]
+}
+
+// get returns the code stored for ip. A nil map holds no entries.
+func (s *shardedMap) get(ip uint32) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	sh := s.shard(ip)
+	sh.mu.RLock()
+	code, ok := sh.m[ip]
+	sh.mu.RUnlock()
+	return code, ok
+}
+
+// set stores the code for ip.
+func (s *shardedMap) set(ip uint32, code string) {
+	sh := s.shard(ip)
+	sh.mu.Lock()
+	sh.m[ip] = code
+	sh.mu.Unlock()
+}
+
+// delete removes ip and reports whether it was present.
+func (s *shardedMap) delete(ip uint32) bool {
+	sh := s.shard(ip)
+	sh.mu.Lock()
+	_, ok := sh.m[ip]
+	delete(sh.m, ip)
+	sh.mu.Unlock()
+	return ok
+}
+
+// len returns the total number of entries.
+func (s *shardedMap) len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+		n += len(s.shards[i].m)
+		s.shards[i].mu.RUnlock()
+	}
+	return n
+}
@@ -0,0 +1,158 @@
+package ip2country
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// mappedRecordSize is the size of a range record in a snapshot file: the start and end
+// address and the index of the range's label, each a little-endian uint32.
+const mappedRecordSize = 12
+
+// rangeLabel is the country, code and alternate codes of a range, stored once per
+// distinct combination.
+type rangeLabel struct {
+	country    string
+	code       string
+	alternates []string
+}
+
+// labelKey identifies a distinct rangeLabel, with the alternate codes joined.
+type labelKey struct {
+	country    string
+	code       string
+	alternates string
+}
+
+// mappedRanges is a sorted range dataset in a memory-mapped snapshot file, used with
+// Config.SnapshotDir, or in a compiled index file written by WriteIndex. Only the labels live on the heap; the records are paged in from
+// the file by the kernel and can be evicted under memory pressure. The mapping is
+// released once the mappedRanges is unreachable.
+type mappedRanges struct {
+	data   []byte
+	labels []rangeLabel
+}
+
+func (m *mappedRanges) len() int {
+	return len(m.data) / mappedRecordSize
+}
+
+func (m *mappedRanges) startAt(i int) uint32 {
+	return binary.LittleEndian.Uint32(m.data[i*mappedRecordSize:])
+}
+
+func (m *mappedRanges) at(i int) IPRange {
+	rec := m.data[i*mappedRecordSize : (i+1)*mappedRecordSize]
+	label := m.labels[binary.LittleEndian.Uint32(rec[8:])]
+	return IPRange{
+		Country:        label.country,
+		Code:           label.code,
+		AlternateCodes: label.alternates,
+		StartIP:        binary.LittleEndian.Uint32(rec[0:]),
+		EndIP:          binary.LittleEndian.Uint32(rec[4:]),
+	}
+}
+
+// mappedOrder sorts the records of a snapshot file in place by start address.
+type mappedOrder []byte
+
+func (o mappedOrder) Len() int { return len(o) / mappedRecordSize }
+
+func (o mappedOrder) Less(i, j int) bool {
+	return binary.LittleEndian.Uint32(o[i*mappedRecordSize:]) < binary.LittleEndian.Uint32(o[j*mappedRecordSize:])
+}
+
+func (o mappedOrder) Swap(i, j int) {
+	var tmp [mappedRecordSize]byte
+	a := o[i*mappedRecordSize : (i+1)*mappedRecordSize]
+	b := o[j*mappedRecordSize : (j+1)*mappedRecordSize]
+	copy(tmp[:], a)
+	copy(a, b)
+	copy(b, tmp[:])
+}
+
+// snapshotWriter streams parsed ranges into a new snapshot file, so a load never holds
+// the whole dataset on the heap.
+type snapshotWriter struct {
+	file   *os.File
+	buf    *bufio.Writer
+	labels []rangeLabel
+	index  map[labelKey]uint32
+	count  int
+	last   uint32
+	sorted bool
+}
+
+// newSnapshotWriter creates a snapshot file in dir.
+func newSnapshotWriter(dir string) (*snapshotWriter, error) {
+	file, err := os.CreateTemp(dir, "ip2country-*.snapshot")
+	if err != nil {
+		return nil, fmt.Errorf("creating snapshot file: %w", err)
+	}
+	return &snapshotWriter{
+		file:   file,
+		buf:    bufio.NewWriter(file),
+		index:  make(map[labelKey]uint32),
+		sorted: true,
+	}, nil
+}
+
+// add appends r to the file.
+func (w *snapshotWriter) add(r IPRange) error {
+	key := labelKey{country: r.Country, code: r.Code, alternates: strings.Join(r.AlternateCodes, codeSeparator)}
+	idx, ok := w.index[key]
+	if !ok {
+		idx = uint32(len(w.labels))
+		w.labels = append(w.labels, rangeLabel{country: r.Country, code: r.Code, alternates: r.AlternateCodes})
+		w.index[key] = idx
+	}
+	if w.count > 0 && r.StartIP < w.last {
+		w.sorted = false
+	}
+	w.last = r.StartIP
+	w.count++
+
+	var rec [mappedRecordSize]byte
+	binary.LittleEndian.PutUint32(rec[0:], r.StartIP)
+	binary.LittleEndian.PutUint32(rec[4:], r.EndIP)
+	binary.LittleEndian.PutUint32(rec[8:], idx)
+	if _, err := w.buf.Write(rec[:]); err != nil {
+		return fmt.Errorf("writing snapshot file: %w", err)
+	}
+	return nil
+}
+
+// finish maps the written file, sorts its records by start address unless they were
+// added in order, and removes the file, which the mapping keeps alive until it is
+// released.
+func (w *snapshotWriter) finish() (*mappedRanges, error) {
+	defer w.discard()
+	if err := w.buf.Flush(); err != nil {
+		return nil, fmt.Errorf("writing snapshot file: %w", err)
+	}
+	m := &mappedRanges{labels: w.labels}
+	if w.count == 0 {
+		return m, nil
+	}
+	data, err := mapFile(w.file, w.count*mappedRecordSize)
+	if err != nil {
+		return nil, fmt.Errorf("mapping snapshot file: %w", err)
+	}
+	m.data = data
+	runtime.AddCleanup(m, unmapFile, data)
+	if !w.sorted {
+		sort.Sort(mappedOrder(data))
+	}
+	return m, nil
+}
+
+// discard closes and removes the file. It is safe to call after finish.
+func (w *snapshotWriter) discard() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
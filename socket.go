@@ -0,0 +1,376 @@
+package ip2country
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The socket protocol is a minimal length-prefixed binary protocol, so that clients in
+// any language can query a SocketServer over a Unix domain socket with a few lines of
+// code. A connection carries any number of requests, answered in order:
+//
+//	request:  uint16 length (big endian) | address, e.g. "8.8.8.8"
+//	response: uint8 status | uint16 length (big endian) | payload
+//
+// A status of 0 means success and the payload is the country code. A status of 1 means
+// the lookup failed and the payload is the ErrorCode, a space and the error message,
+// e.g. "ERR_NOT_FOUND country not found for IP".
+const (
+	socketStatusOK    = 0
+	socketStatusError = 1
+
+	// maxSocketFrame is the largest request payload a server accepts.
+	maxSocketFrame = 512
+)
+
+// SocketServer serves lookups over stream sockets using the socket protocol, typically
+// on a Unix domain socket so that non-Go processes on the same host can query the
+// in-memory dataset with microsecond latency.
+type SocketServer struct {
+	reader    CountryReader
+	life      lifecycle
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+}
+
+// NewSocketServer returns a server answering lookups from reader. Call Serve or
+// ListenAndServe to accept connections and Close to stop.
+func NewSocketServer(reader CountryReader) *SocketServer {
+	return &SocketServer{
+		reader:    reader,
+		life:      newLifecycle(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the Unix domain socket at path and serves connections until
+// Close is called. A stale socket file left behind by a previous process is removed.
+func (s *SocketServer) ListenAndServe(path string) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each on its own goroutine until Close is
+// called, after which it returns ErrClosed. The listener is closed on return.
+func (s *SocketServer) Serve(l net.Listener) error {
+	defer l.Close()
+	if !s.register(func() { s.listeners[l] = struct{}{} }) {
+		return ErrClosed
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.life.closed() {
+				return ErrClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !s.register(func() { s.conns[conn] = struct{}{} }) {
+			conn.Close()
+			return ErrClosed
+		}
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// register runs add with the server lock held, so that Close sees every listener and
+// connection. It reports false, without calling add, if the server is already closed.
+func (s *SocketServer) register(add func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.life.closed() {
+		return false
+	}
+	add()
+	return true
+}
+
+// serveConn answers the requests of a single connection until it is closed. Responses
+// are flushed once no further pipelined request is buffered.
+func (s *SocketServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var header [3]byte
+	for {
+		if _, err := io.ReadFull(r, header[:2]); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint16(header[:2])
+		if n == 0 || n > maxSocketFrame {
+			return
+		}
+		ip := make([]byte, n)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return
+		}
+
+		code, err := s.reader.GetCountryCodeWithContext(s.life.ctx, string(ip))
+		payload := code
+		header[0] = socketStatusOK
+		if err != nil {
+			payload = string(ErrorCodeOf(err)) + " " + err.Error()
+			header[0] = socketStatusError
+		}
+		if len(payload) > maxSocketFrame {
+			payload = payload[:maxSocketFrame]
+		}
+		binary.BigEndian.PutUint16(header[1:], uint16(len(payload)))
+		w.Write(header[:])
+		w.WriteString(payload)
+
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close stops all listeners, closes open connections and waits for their handlers to
+// return. It is safe to call more than once.
+func (s *SocketServer) Close() error {
+	s.mu.Lock()
+	s.life.close()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// SocketConfig holds configuration for a SocketLookup client.
+// Fields are ordered for optimal memory alignment.
+type SocketConfig struct {
+	// Timeout bounds each lookup, including dialing, unless the context has an earlier
+	// deadline. Defaults to 1s.
+	Timeout time.Duration
+	// MaxIdleConns is the number of connections kept open for reuse. Defaults to
+	// GOMAXPROCS.
+	MaxIdleConns int
+}
+
+// socketConn is a client connection with its read buffer.
+type socketConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// SocketLookup implements the IPCountryLookup interface by querying a SocketServer over
+// a Unix domain socket. It is safe for concurrent use; each in-flight lookup uses its
+// own connection, and idle connections are reused.
+type SocketLookup struct {
+	path   string
+	dialer net.Dialer
+	idle   chan *socketConn
+	config SocketConfig
+	closed atomic.Bool
+}
+
+// NewSocketLookup returns a client for the SocketServer listening on the Unix domain
+// socket at path. Connections are opened on demand. It accepts an optional SocketConfig.
+func NewSocketLookup(path string, config ...SocketConfig) *SocketLookup {
+	var cfg SocketConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = runtime.GOMAXPROCS(0)
+	}
+	return &SocketLookup{
+		path:   path,
+		idle:   make(chan *socketConn, cfg.MaxIdleConns),
+		config: cfg,
+	}
+}
+
+// errSocketUnavailable is returned when the server cannot be reached or the connection
+// fails mid-request.
+var errSocketUnavailable = errors.New("socket server unavailable")
+
+// conn returns an idle connection or dials a new one.
+func (sl *SocketLookup) conn(ctx context.Context) (*socketConn, error) {
+	select {
+	case c := <-sl.idle:
+		return c, nil
+	default:
+	}
+	c, err := sl.dialer.DialContext(ctx, "unix", sl.path)
+	if err != nil {
+		return nil, err
+	}
+	return &socketConn{Conn: c, r: bufio.NewReader(c)}, nil
+}
+
+// release returns a healthy connection to the idle pool, closing it if the pool is full
+// or the client was closed.
+func (sl *SocketLookup) release(c *socketConn) {
+	if !sl.closed.Load() {
+		select {
+		case sl.idle <- c:
+			return
+		default:
+		}
+	}
+	c.Close()
+}
+
+// query performs a single request and returns the response status and payload.
+func (sl *SocketLookup) query(ctx context.Context, ipStr string) (byte, string, error) {
+	if len(ipStr) == 0 || len(ipStr) > maxSocketFrame {
+		return 0, "", newLookupError(CodeInvalidIP, fmt.Errorf("%w: %q", ErrInvalidIP, ipStr))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sl.config.Timeout)
+	defer cancel()
+
+	c, err := sl.conn(ctx)
+	if err != nil {
+		return 0, "", newLookupError(failureCode(err, CodeUnavailable), fmt.Errorf("%w: %v", errSocketUnavailable, err))
+	}
+	deadline, _ := ctx.Deadline()
+	c.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { c.SetDeadline(time.Now()) })
+
+	status, payload, err := exchange(c, ipStr)
+	if stop() && err == nil {
+		sl.release(c)
+		return status, payload, nil
+	}
+
+	// The connection may hold a partial response, so it cannot be reused.
+	c.Close()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	return 0, "", newLookupError(failureCode(err, CodeUnavailable), fmt.Errorf("%w: %v", errSocketUnavailable, err))
+}
+
+// exchange writes a request frame to c and reads the response.
+func exchange(c *socketConn, ipStr string) (byte, string, error) {
+	req := make([]byte, 2+len(ipStr))
+	binary.BigEndian.PutUint16(req, uint16(len(ipStr)))
+	copy(req[2:], ipStr)
+	if _, err := c.Write(req); err != nil {
+		return 0, "", err
+	}
+
+	var header [3]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, "", err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[1:]))
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, "", err
+	}
+	return header[0], string(payload), nil
+}
+
+// GetCountry retrieves the country code for a given IP address string.
+func (sl *SocketLookup) GetCountry(ipStr string) (string, error) {
+	return sl.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryWithContext retrieves the country code, respecting the context.
+func (sl *SocketLookup) GetCountryWithContext(ctx context.Context, ipStr string) (string, error) {
+	return sl.GetCountryCodeWithContext(ctx, ipStr)
+}
+
+// GetCountryCode retrieves the country code for a given IP address string.
+func (sl *SocketLookup) GetCountryCode(ipStr string) (string, error) {
+	return sl.GetCountryCodeWithContext(context.Background(), ipStr)
+}
+
+// GetCountryCodeWithContext retrieves the country code from the socket server. Errors
+// reported by the server carry the server's ErrorCode.
+func (sl *SocketLookup) GetCountryCodeWithContext(ctx context.Context, ipStr string) (string, error) {
+	if sl.closed.Load() {
+		return "", newLookupError(CodeClosed, ErrClosed)
+	}
+	status, payload, err := sl.query(ctx, ipStr)
+	if err != nil {
+		return "", err
+	}
+	if status == socketStatusOK {
+		return payload, nil
+	}
+	code, msg, _ := strings.Cut(payload, " ")
+	return "", newServerError(ErrorCode(code), msg)
+}
+
+// Stats returns an empty Stats value; the server reports its own dataset statistics.
+func (sl *SocketLookup) Stats() Stats {
+	return Stats{}
+}
+
+// Reload is not supported by a socket lookup; the server manages its own dataset.
+func (sl *SocketLookup) Reload() error {
+	return sl.ReloadWithContext(context.Background())
+}
+
+// ReloadWithContext is not supported by a socket lookup and always returns an error.
+func (sl *SocketLookup) ReloadWithContext(ctx context.Context) error {
+	return newLookupError(CodeUnsupported, fmt.Errorf("reload is not supported by a socket lookup"))
+}
+
+// Close closes idle connections. Lookups after Close return ErrClosed. It is safe to
+// call more than once.
+func (sl *SocketLookup) Close() error {
+	sl.closed.Store(true)
+	for {
+		select {
+		case c := <-sl.idle:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
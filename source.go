@@ -0,0 +1,317 @@
+package ip2country
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Source provides the raw dataset to IPCountryDB and ExactIPCountryMap, e.g. from a
+// local file (FileSource), an fs.FS (FSSource), an HTTP server (HTTPSource), object
+// storage or memory. Open is called on every load and reload and returns the data
+// together with metadata describing it.
+type Source interface {
+	// Open returns a reader for the dataset. It returns an error wrapping ErrNotModified
+	// if the dataset has not changed since the last complete read, in which case a
+	// reload keeps serving the currently loaded data.
+	Open(ctx context.Context) (io.ReadCloser, Metadata, error)
+}
+
+// Metadata describes a dataset returned by a Source.
+// Fields are ordered for optimal memory alignment.
+type Metadata struct {
+	// ModTime is the modification time of the dataset, if known.
+	ModTime time.Time `json:"mod_time"`
+	// Name identifies the dataset, e.g. a file path or URL.
+	Name string `json:"name"`
+	// ETag is the entity tag reported by an HTTP server, if any.
+	ETag string `json:"etag,omitempty"`
+	// Size is the size of the data in bytes, or -1 if it is not known up front.
+	Size int64 `json:"size"`
+}
+
+// ErrNotModified is returned by Source.Open when the dataset has not changed.
+var ErrNotModified = errors.New("dataset not modified")
+
+// relatedOpener is implemented by sources that can open files shipped alongside the
+// dataset, such as the GeoLite2 locations file. name is used for a sibling file in the
+// same directory, pattern for an entry of the same archive.
+type relatedOpener interface {
+	openRelated(name, pattern string) (io.ReadCloser, error)
+}
+
+// sourceName returns a human-readable name of src for logs and errors.
+func sourceName(src Source) string {
+	if s, ok := src.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", src)
+}
+
+// FileSource is a Source that reads the dataset from the local filesystem. Compressed
+// files and archives are handled as described for NewIPCountryDB.
+type FileSource struct {
+	// Path is the path of the dataset file or archive.
+	Path string
+	// ArchivePattern selects the data file inside archives, see Config.ArchivePattern.
+	ArchivePattern string
+}
+
+// Open opens the file, retrying while the process is short of file descriptors.
+func (s *FileSource) Open(ctx context.Context) (io.ReadCloser, Metadata, error) {
+	meta := Metadata{Name: s.Path}
+	file, size, err := openWithRetry(ctx, func() (io.ReadCloser, int64, error) {
+		return openDataFile(s.Path, s.ArchivePattern)
+	})
+	if err != nil {
+		return nil, meta, err
+	}
+	meta.Size = size
+	if stat, err := os.Stat(s.Path); err == nil {
+		meta.ModTime = stat.ModTime()
+	}
+	return file, meta, nil
+}
+
+// String returns the path of the file.
+func (s *FileSource) String() string {
+	return s.Path
+}
+
+// openRelated opens a file next to the dataset, or an entry of the same archive.
+func (s *FileSource) openRelated(name, pattern string) (io.ReadCloser, error) {
+	if isArchive(s.Path) {
+		file, _, err := openDataFile(s.Path, pattern)
+		return file, err
+	}
+	file, _, err := openDataFile(filepath.Join(filepath.Dir(s.Path), name), "")
+	return file, err
+}
+
+// FSSource is a Source that reads the dataset from a file in an fs.FS, e.g. an embed.FS
+// populated with go:embed. Archives are not supported.
+type FSSource struct {
+	// FS is the file system holding the dataset.
+	FS fs.FS
+	// Path is the slash-separated path of the dataset within FS.
+	Path string
+}
+
+// Open opens the file in the file system.
+func (s *FSSource) Open(ctx context.Context) (io.ReadCloser, Metadata, error) {
+	meta := Metadata{Name: s.Path}
+	file, err := s.FS.Open(s.Path)
+	if err != nil {
+		return nil, meta, fmt.Errorf("failed to open file: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, meta, fmt.Errorf("failed to get file stats: %w", err)
+	}
+	meta.Size = stat.Size()
+	meta.ModTime = stat.ModTime()
+	return file, meta, nil
+}
+
+// String returns the path of the file within the file system.
+func (s *FSSource) String() string {
+	return s.Path
+}
+
+// openRelated opens a file next to the dataset in the same file system.
+func (s *FSSource) openRelated(name, _ string) (io.ReadCloser, error) {
+	return s.FS.Open(path.Join(path.Dir(s.Path), name))
+}
+
+// dryRunSource is implemented by sources whose Open changes their state, so that
+// ReloadDryRun can read the dataset without affecting the next reload. dryRunCopy
+// returns an independent copy of the source, or nil if the dataset cannot be read again.
+type dryRunSource interface {
+	dryRunCopy() Source
+}
+
+// loadCommitter is implemented by sources that remember what they served, such as the
+// validators of HTTPSource, and must only do so once the dataset read from them has
+// been put into service. Otherwise a load failing after the download would make the
+// next Open report ErrNotModified and hide the failure.
+type loadCommitter interface {
+	commitLoad()
+}
+
+// readerSource is a Source that yields a reader exactly once.
+type readerSource struct {
+	r    io.Reader
+	used atomic.Bool
+}
+
+// Open returns the reader on the first call and ErrNotModified afterwards, so reloads
+// keep the data that was read from it.
+func (s *readerSource) Open(ctx context.Context) (io.ReadCloser, Metadata, error) {
+	if s.used.Swap(true) {
+		return nil, Metadata{Name: s.String()}, fmt.Errorf("%w: stream has already been consumed", ErrNotModified)
+	}
+	return io.NopCloser(s.r), Metadata{Name: s.String(), Size: -1}, nil
+}
+
+// dryRunCopy returns nil, as the stream can only be read once.
+func (s *readerSource) dryRunCopy() Source {
+	return nil
+}
+
+// String describes the source.
+func (s *readerSource) String() string {
+	return "stream"
+}
+
+// limitedReader counts the bytes read from r and fails once more than limit bytes have
+// been read, enforcing MaxFileSize on data whose size is not known up front.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+// Read reads from the underlying reader, failing if the limit is exceeded.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.limit > 0 && l.n > l.limit {
+		return n, fmt.Errorf("%w %d", ErrFileTooLarge, l.limit)
+	}
+	return n, err
+}
+
+// scanBufferSize is the size of the line scanner buffers, which also bounds the length
+// of a line, as with the bufio.Scanner default.
+const scanBufferSize = bufio.MaxScanTokenSize
+
+// scanBuffers holds line scanner buffers for reuse across loads, so repeated reloads
+// do not allocate and grow a new buffer each time.
+var scanBuffers = sync.Pool{New: func() any { return new([scanBufferSize]byte) }}
+
+// newLineScanner returns a scanner over r using a pooled buffer, which is returned to
+// the pool by release once scanning is done.
+func newLineScanner(r io.Reader) (scanner *bufio.Scanner, release func()) {
+	buf := scanBuffers.Get().(*[scanBufferSize]byte)
+	scanner = bufio.NewScanner(r)
+	scanner.Buffer(buf[:], scanBufferSize)
+	return scanner, func() { scanBuffers.Put(buf) }
+}
+
+// progressInterval is the number of lines read between Config.ProgressFunc calls.
+const progressInterval = 1 << 16
+
+// progressReporter counts the lines and bytes read by a parse loop and reports them to
+// Config.ProgressFunc every progressInterval lines.
+type progressReporter struct {
+	fn    func(linesRead, bytesRead int64)
+	lines int64
+	bytes int64
+}
+
+// line records a line of n bytes, excluding its line break.
+func (p *progressReporter) line(n int) {
+	p.add(1, int64(n)+1)
+}
+
+// add records lines lines of bytes bytes in total, including line breaks.
+func (p *progressReporter) add(lines, bytes int64) {
+	before := p.lines / progressInterval
+	p.lines += lines
+	p.bytes += bytes
+	if p.fn != nil && p.lines/progressInterval != before {
+		p.fn(p.lines, p.bytes)
+	}
+}
+
+// done reports the final counts.
+func (p *progressReporter) done() {
+	if p.fn != nil {
+		p.fn(p.lines, p.bytes)
+	}
+}
+
+// Retry policy for opening dataset files under transient resource pressure.
+const (
+	openAttempts   = 4
+	openRetryDelay = 50 * time.Millisecond
+)
+
+// LoadError is returned when a dataset cannot be loaded. Transient reports whether the
+// failure is likely to resolve on its own, such as file descriptor exhaustion, so that
+// callers can retry later instead of treating the dataset as broken.
+type LoadError struct {
+	// Err is the underlying error.
+	Err error
+	// Transient is true for resource-pressure failures (EMFILE, ENFILE, EAGAIN, ...).
+	Transient bool
+}
+
+// Error returns a string representation of the LoadError.
+func (e *LoadError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the failure is transient.
+func (e *LoadError) Temporary() bool {
+	return e.Transient
+}
+
+// newLoadError classifies err as a LoadError. Context cancellation is passed through.
+func newLoadError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		return err
+	}
+	return &LoadError{Err: err, Transient: isTransient(err)}
+}
+
+// isTransient reports whether err is caused by temporary resource exhaustion.
+func isTransient(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EMFILE, syscall.ENFILE, syscall.EAGAIN, syscall.EINTR, syscall.ENOMEM, syscall.ENOBUFS} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// openWithRetry opens a dataset file, retrying with exponential backoff while the
+// failure is transient, e.g. while the process is out of file descriptors.
+func openWithRetry(ctx context.Context, open func() (io.ReadCloser, int64, error)) (io.ReadCloser, int64, error) {
+	delay := openRetryDelay
+	for attempt := 1; ; attempt++ {
+		file, size, err := open()
+		if err == nil || attempt == openAttempts || !isTransient(err) {
+			return file, size, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, 0, err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,60 @@
+package ip2country
+
+import "strings"
+
+// tldExceptions holds the country code top-level domains that differ from the
+// lowercased alpha-2 code, with "" for countries that have none in the root zone.
+var tldExceptions = map[string]string{
+	GB: "uk",
+	BL: "", // Not delegated; Saint Barthélemy uses .fr and .gp.
+	BQ: "", // Not delegated; Bonaire, Sint Eustatius and Saba use .nl.
+	EH: "", // Not delegated.
+	MF: "", // Not delegated; Saint Martin uses .fr and .gp.
+	UM: "", // Retired in 2008.
+}
+
+// tldCountries holds the country code top-level domains that differ from the alpha-2
+// code of their country.
+var tldCountries = map[string]string{
+	"uk": GB,
+}
+
+// CountryTLD returns the country code top-level domain of the country with the given
+// alpha-2 code, with its leading dot, e.g. ".de" for "DE" and ".uk" for "GB". It
+// returns false for codes that are not assigned and for the few countries without a
+// ccTLD of their own. The comparison is case-insensitive.
+func CountryTLD(code string) (string, bool) {
+	code = strings.ToUpper(code)
+	if !IsCountryCode(code) {
+		return "", false
+	}
+	tld, ok := tldExceptions[code]
+	if !ok {
+		tld = strings.ToLower(code)
+	} else if tld == "" {
+		return "", false
+	}
+	return "." + tld, true
+}
+
+// CountryForTLD returns the alpha-2 code of the country a country code top-level
+// domain belongs to, e.g. "DE" for ".de" and "GB" for ".uk". The domain may be given
+// with or without its leading dot, or as a full host name such as "www.example.co.uk",
+// of which the last label is used. Generic domains such as ".com" return false.
+func CountryForTLD(tld string) (string, bool) {
+	tld = strings.ToLower(strings.TrimSuffix(tld, "."))
+	if i := strings.LastIndexByte(tld, '.'); i >= 0 {
+		tld = tld[i+1:]
+	}
+	if code, ok := tldCountries[tld]; ok {
+		return code, true
+	}
+	code := strings.ToUpper(tld)
+	if len(code) != 2 || !IsCountryCode(code) {
+		return "", false
+	}
+	if _, ok := CountryTLD(code); !ok {
+		return "", false
+	}
+	return code, true
+}
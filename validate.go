@@ -0,0 +1,75 @@
+package ip2country
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ValidateCSVStream checks a range dataset read from r in a single pass, for pre-flight
+// validation of files too large to load, e.g. in CI. Besides the syntax of every line,
+// it checks that ranges are sorted by start address and do not overlap, remembering
+// only the previous range, so memory use does not grow with the dataset. Lines out of
+// order are reported as ParseErrorUnsorted and lines overlapping the previous range as
+// ParseErrorOverlap, alongside the syntax errors.
+//
+// Lines are parsed as cfg would parse them for a load, except that Config.MaxFileSize
+// and Config.MaxRanges are not applied and Config.ParseWorkers is ignored.
+// Config.StrictParsing and Config.MaxParseErrors stop validation early with an error
+// wrapping ErrTooManyParseErrors, and Config.MaxStoredParseErrors caps the errors
+// kept in the result, whose Ranges are always nil. The GeoLite2 format cannot be
+// validated from a single stream, as it needs the locations file.
+func ValidateCSVStream(ctx context.Context, r io.Reader, cfg Config) (*ParseResult, error) {
+	if cfg.Format == FormatGeoLite2 {
+		return nil, newLookupError(CodeUnsupported, errors.New("GeoLite2 datasets cannot be validated as a stream"))
+	}
+	db := &IPCountryDB{config: cfg}
+	hash := sha256.New()
+	counter := &limitedReader{r: io.TeeReader(r, hash)}
+	reader, layout := detectLayout(counter, &db.config)
+
+	count := 0
+	var prev IPRange
+	parseErrors := newParseErrorCollector(&db.config)
+	handle := func(lineNum int, line string, ipRange *IPRange, err error) (bool, error) {
+		if errors.Is(err, errSkipLine) {
+			return false, nil
+		}
+		if err == nil && count > 0 {
+			switch {
+			case ipRange.StartIP < prev.StartIP:
+				err = fmt.Errorf("%w: starts before the previous range [%d-%d]", errUnsorted, prev.StartIP, prev.EndIP)
+			case ipRange.StartIP <= prev.EndIP:
+				err = fmt.Errorf("%w: overlaps the previous range [%d-%d]", errOverlap, prev.StartIP, prev.EndIP)
+			}
+		}
+		if err != nil {
+			return false, parseErrors.add(lineNum, line, err)
+		}
+		prev = *ipRange
+		count++
+		return false, nil
+	}
+	progress := progressReporter{fn: cfg.ProgressFunc}
+	parse := func(line string) (*IPRange, error) {
+		return db.parseLine(line, layout.delimiter)
+	}
+	if err := db.parseLines(ctx, reader, layout.skipHeader, &progress, parse, handle); err != nil {
+		return nil, err
+	}
+	progress.done()
+
+	return &ParseResult{
+		Errors:       parseErrors.errors,
+		ErrorSummary: parseErrors.summary,
+		Stats: Stats{
+			TotalRanges: count,
+			ParseErrors: parseErrors.summary.Total,
+			FileSize:    counter.n,
+			Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		},
+	}, nil
+}